@@ -1,32 +1,19 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"strings"
 	"time"
-)
-
-type modelInfo struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Provider string `json:"provider"`
-}
 
-type polishRequest struct {
-	Text    string `json:"text"`
-	ModelID string `json:"model_id"`
-}
-
-type polishResponse struct {
-	Polished  string `json:"polished"`
-	Model     string `json:"model"`
-	ElapsedMs int64  `json:"elapsed_ms"`
-}
+	"github.com/mlorentedev/pollex/internal/diff"
+	"github.com/mlorentedev/pollex/internal/rules"
+	"github.com/mlorentedev/pollex/pkg/client"
+)
 
 type result struct {
 	Sample    string
@@ -47,19 +34,27 @@ func main() {
 	quality := flag.Bool("quality", false, "Quality mode: show input/output for each sample (1 run, no timing table)")
 	jsonOut := flag.String("json", "", "Write results to JSON file (e.g. results.json)")
 	warmup := flag.Bool("warmup", false, "Run one warmup request per sample before measuring")
+	baseline := flag.Bool("baseline", false, "Quality mode: also show the offline rules output and its similarity to the model's")
 	flag.Parse()
 
 	baseURL := strings.TrimRight(*url, "/")
-	client := &http.Client{Timeout: 180 * time.Second}
+	c := client.New(baseURL,
+		client.WithAPIKey(*apiKey),
+		client.WithHTTPClient(&http.Client{Timeout: 180 * time.Second}),
+	)
 
 	// Discover models
 	modelID := *model
 	if modelID == "" {
-		modelID = discoverModel(client, baseURL, *apiKey)
+		modelID = discoverModel(c)
 	}
 
 	if *quality {
-		runQualityMode(client, baseURL, *apiKey, modelID)
+		var base *rules.Set
+		if *baseline {
+			base = rules.Default()
+		}
+		runQualityMode(c, baseURL, modelID, base)
 		return
 	}
 
@@ -75,7 +70,7 @@ func main() {
 	for _, sample := range Samples {
 		if *warmup {
 			fmt.Printf("  Warming up %s...", sample.Name)
-			w := benchmark(client, baseURL, *apiKey, modelID, sample, 0)
+			w := benchmark(c, modelID, sample, 0)
 			if w.Error != "" {
 				fmt.Printf(" FAILED (%s)\n", w.Error)
 			} else {
@@ -84,7 +79,7 @@ func main() {
 		}
 		for run := 1; run <= *runs; run++ {
 			fmt.Printf("  Running %s (run %d/%d)...", sample.Name, run, *runs)
-			r := benchmark(client, baseURL, *apiKey, modelID, sample, run)
+			r := benchmark(c, modelID, sample, run)
 			results = append(results, r)
 			if r.Error != "" {
 				fmt.Printf(" FAILED (%s)\n", r.Error)
@@ -114,34 +109,12 @@ func main() {
 	}
 }
 
-func discoverModel(client *http.Client, baseURL, apiKey string) string {
-	req, err := http.NewRequest("GET", baseURL+"/api/models", nil)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating request: %v\n", err)
-		os.Exit(1)
-	}
-	if apiKey != "" {
-		req.Header.Set("X-API-Key", apiKey)
-	}
-
-	resp, err := client.Do(req)
+func discoverModel(c *client.Client) string {
+	models, err := c.Models(context.Background())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error fetching models: %v\n", err)
 		os.Exit(1)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		fmt.Fprintf(os.Stderr, "Models endpoint returned %d: %s\n", resp.StatusCode, body)
-		os.Exit(1)
-	}
-
-	var models []modelInfo
-	if err := json.NewDecoder(resp.Body).Decode(&models); err != nil {
-		fmt.Fprintf(os.Stderr, "Error decoding models: %v\n", err)
-		os.Exit(1)
-	}
 
 	if len(models) == 0 {
 		fmt.Fprintln(os.Stderr, "No models available")
@@ -151,43 +124,21 @@ func discoverModel(client *http.Client, baseURL, apiKey string) string {
 	return models[0].ID
 }
 
-func benchmark(client *http.Client, baseURL, apiKey, modelID string, sample Sample, run int) result {
+func benchmark(c *client.Client, modelID string, sample Sample, run int) result {
 	fail := func(err string) result {
 		return result{Sample: sample.Name, Chars: len(sample.Text), Run: run, Error: err}
 	}
 
-	payload, _ := json.Marshal(polishRequest{
+	start := time.Now()
+	pr, err := c.Polish(context.Background(), client.PolishRequest{
 		Text:    sample.Text,
 		ModelID: modelID,
 	})
-
-	req, err := http.NewRequest("POST", baseURL+"/api/polish", strings.NewReader(string(payload)))
-	if err != nil {
-		return fail(err.Error())
-	}
-	req.Header.Set("Content-Type", "application/json")
-	if apiKey != "" {
-		req.Header.Set("X-API-Key", apiKey)
-	}
-
-	start := time.Now()
-	resp, err := client.Do(req)
 	wallMs := time.Since(start).Milliseconds()
 
 	if err != nil {
 		return fail(err.Error())
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fail(fmt.Sprintf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body))))
-	}
-
-	var pr polishResponse
-	if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
-		return fail(err.Error())
-	}
 
 	return result{
 		Sample:    sample.Name,
@@ -215,7 +166,7 @@ func printTable(results []result) {
 	}
 }
 
-func runQualityMode(client *http.Client, baseURL, apiKey, modelID string) {
+func runQualityMode(c *client.Client, baseURL, modelID string, base *rules.Set) {
 	fmt.Printf("Quality test against %s using model: %s\n", baseURL, modelID)
 	fmt.Println(strings.Repeat("=", 72))
 
@@ -223,45 +174,24 @@ func runQualityMode(client *http.Client, baseURL, apiKey, modelID string) {
 	for i, sample := range QualitySamples {
 		fmt.Printf("\n--- %d/%d: %s (%d chars) ---\n", i+1, len(QualitySamples), sample.Name, len(sample.Text))
 		fmt.Printf("IN:  %s\n", sample.Text)
-
-		payload, _ := json.Marshal(polishRequest{Text: sample.Text, ModelID: modelID})
-		req, err := http.NewRequest("POST", baseURL+"/api/polish", strings.NewReader(string(payload)))
-		if err != nil {
-			fmt.Printf("ERR: %s\n", err)
-			failures++
-			continue
-		}
-		req.Header.Set("Content-Type", "application/json")
-		if apiKey != "" {
-			req.Header.Set("X-API-Key", apiKey)
+		var baseOut string
+		if base != nil {
+			baseOut = base.Apply(sample.Text)
+			fmt.Printf("BASE: %s\n", baseOut)
 		}
 
-		resp, err := client.Do(req)
+		pr, err := c.Polish(context.Background(), client.PolishRequest{Text: sample.Text, ModelID: modelID})
 		if err != nil {
 			fmt.Printf("ERR: %s\n", err)
 			failures++
 			continue
 		}
 
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			fmt.Printf("ERR: HTTP %d: %s\n", resp.StatusCode, strings.TrimSpace(string(body)))
-			failures++
-			continue
-		}
-
-		var pr polishResponse
-		if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
-			resp.Body.Close()
-			fmt.Printf("ERR: %s\n", err)
-			failures++
-			continue
-		}
-		resp.Body.Close()
-
 		fmt.Printf("OUT: %s\n", pr.Polished)
 		fmt.Printf("     [%dms, %d->%d chars]\n", pr.ElapsedMs, len(sample.Text), len(pr.Polished))
+		if base != nil {
+			fmt.Printf("     [%.2f similar to baseline]\n", diff.Similarity(baseOut, pr.Polished))
+		}
 	}
 
 	fmt.Printf("\n%s\n", strings.Repeat("=", 72))
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mlorentedev/pollex/pkg/client"
+)
+
+var version = "dev"
+
+// pollex-lsp speaks the Language Server Protocol over stdio and offers
+// "Polish selection" / "Polish paragraph" code actions backed by a Pollex
+// server. Logs go to stderr; stdout carries the protocol.
+func main() {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	url := flag.String("url", envOr("POLLEX_URL", "http://localhost:8090"), "server URL (env POLLEX_URL)")
+	apiKey := flag.String("api-key", os.Getenv("POLLEX_API_KEY"), "API key (env POLLEX_API_KEY)")
+	model := flag.String("model", os.Getenv("POLLEX_MODEL"), "model ID (env POLLEX_MODEL, default: first available)")
+	timeout := flag.Duration("timeout", 2*time.Minute, "per-polish timeout")
+	flag.Bool("stdio", true, "use stdio transport (the only one supported; accepted for editor compatibility)")
+	flag.Parse()
+
+	c := client.New(*url, client.WithAPIKey(*apiKey))
+	var mu sync.Mutex
+	modelID := *model
+	polish := func(ctx context.Context, text string) (string, error) {
+		ctx, cancel := context.WithTimeout(ctx, *timeout)
+		defer cancel()
+		mu.Lock()
+		defer mu.Unlock()
+		if modelID == "" {
+			models, err := c.Models(ctx)
+			if err != nil {
+				return "", err
+			}
+			if len(models) == 0 {
+				return "", errors.New("server has no models")
+			}
+			modelID = models[0].ID
+		}
+		resp, err := c.Polish(ctx, client.PolishRequest{Text: text, ModelID: modelID})
+		if err != nil {
+			return "", err
+		}
+		return resp.Polished, nil
+	}
+
+	slog.Info("pollex-lsp starting", "url", *url, "version", version)
+	if !newServer(os.Stdin, os.Stdout, polish).run() {
+		fmt.Fprintln(os.Stderr, "pollex-lsp: exit without shutdown")
+		os.Exit(1)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
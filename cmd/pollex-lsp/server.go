@@ -0,0 +1,428 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/mlorentedev/pollex/internal/diff"
+)
+
+const (
+	kindSelection = "selection"
+	kindParagraph = "paragraph"
+
+	severityHint = 4
+)
+
+type textEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+type versionedDocument struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+}
+
+type textDocumentEdit struct {
+	TextDocument versionedDocument `json:"textDocument"`
+	Edits        []textEdit        `json:"edits"`
+}
+
+// workspaceEdit carries versioned edits, so the client refuses to apply one
+// computed against text it no longer has.
+type workspaceEdit struct {
+	DocumentChanges []textDocumentEdit `json:"documentChanges"`
+}
+
+type diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+type codeAction struct {
+	Title string         `json:"title"`
+	Kind  string         `json:"kind"`
+	Data  actionData     `json:"data"`
+	Edit  *workspaceEdit `json:"edit,omitempty"`
+}
+
+// actionData travels from textDocument/codeAction to codeAction/resolve, so
+// the slow polish call only happens when the user picks the action. Range is
+// only valid in the document version it was computed for.
+type actionData struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+	Range   Range  `json:"range"`
+	Kind    string `json:"kind"`
+}
+
+// document is the latest text the client synced and its version.
+type document struct {
+	text    string
+	version int
+}
+
+// pendingHints are published once the client applies the edit they describe.
+type pendingHints struct {
+	text  string
+	diags []diagnostic
+}
+
+type polishFunc func(ctx context.Context, text string) (string, error)
+
+type server struct {
+	conn   *conn
+	polish polishFunc
+
+	mu      sync.Mutex
+	docs    map[string]document
+	pending map[string]pendingHints
+	hinted  map[string]bool
+	cancels map[string]context.CancelFunc // in-flight resolves by request ID
+}
+
+func newServer(r io.Reader, w io.Writer, polish polishFunc) *server {
+	return &server{
+		conn:    newConn(r, w),
+		polish:  polish,
+		docs:    make(map[string]document),
+		pending: make(map[string]pendingHints),
+		hinted:  make(map[string]bool),
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// run serves requests until the client sends exit. It reports whether a
+// shutdown request preceded the exit, as the spec requires for exit code 0.
+func (s *server) run() bool {
+	shutdown := false
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		req, err := s.conn.read()
+		if err != nil {
+			if err != io.EOF {
+				slog.Error("read message", "error", err)
+			}
+			return shutdown
+		}
+
+		switch req.Method {
+		case "exit":
+			return shutdown
+		case "shutdown":
+			shutdown = true
+			s.conn.reply(req.ID, nil)
+		case "codeAction/resolve":
+			// Polishing takes seconds; don't block document sync meanwhile,
+			// and let $/cancelRequest stop it.
+			ctx, cancel := context.WithCancel(context.Background())
+			s.mu.Lock()
+			s.cancels[string(req.ID)] = cancel
+			s.mu.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() {
+					s.mu.Lock()
+					delete(s.cancels, string(req.ID))
+					s.mu.Unlock()
+					cancel()
+				}()
+				s.handle(ctx, req)
+			}()
+		default:
+			s.handle(context.Background(), req)
+		}
+	}
+}
+
+func (s *server) handle(ctx context.Context, req *request) {
+	result, err := s.dispatch(ctx, req)
+	if req.ID == nil {
+		if err != nil {
+			slog.Error("notification failed", "method", req.Method, "error", err)
+		}
+		return
+	}
+	if err != nil {
+		code := codeInternalError
+		if re, ok := err.(*rpcError); ok {
+			code = re.Code
+		}
+		s.conn.replyError(req.ID, code, err.Error())
+		return
+	}
+	s.conn.reply(req.ID, result)
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+func (s *server) dispatch(ctx context.Context, req *request) (any, error) {
+	switch req.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync": 1, // full
+				"codeActionProvider": map[string]any{
+					"codeActionKinds": []string{"refactor.rewrite"},
+					"resolveProvider": true,
+				},
+			},
+			"serverInfo": map[string]string{"name": "pollex-lsp", "version": version},
+		}, nil
+	case "initialized", "$/setTrace":
+		return nil, nil
+	case "$/cancelRequest":
+		var p struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		if cancel, ok := s.cancels[string(p.ID)]; ok {
+			cancel()
+		}
+		s.mu.Unlock()
+		return nil, nil
+	case "textDocument/didOpen":
+		var p struct {
+			TextDocument struct {
+				URI     string `json:"uri"`
+				Version int    `json:"version"`
+				Text    string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, err
+		}
+		s.setText(p.TextDocument.URI, document{text: p.TextDocument.Text, version: p.TextDocument.Version})
+		return nil, nil
+	case "textDocument/didChange":
+		var p struct {
+			TextDocument struct {
+				URI     string `json:"uri"`
+				Version int    `json:"version"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, err
+		}
+		if n := len(p.ContentChanges); n > 0 {
+			s.setText(p.TextDocument.URI, document{text: p.ContentChanges[n-1].Text, version: p.TextDocument.Version})
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var p struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		delete(s.docs, p.TextDocument.URI)
+		delete(s.pending, p.TextDocument.URI)
+		delete(s.hinted, p.TextDocument.URI)
+		s.mu.Unlock()
+		return nil, nil
+	case "textDocument/codeAction":
+		return s.codeActions(req.Params)
+	case "codeAction/resolve":
+		return s.resolve(ctx, req.Params)
+	default:
+		if req.ID == nil {
+			return nil, nil
+		}
+		return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+}
+
+// setText stores a new document version and publishes or clears polish hints.
+func (s *server) setText(uri string, doc document) {
+	s.mu.Lock()
+	s.docs[uri] = doc
+	var publish []diagnostic
+	if p, ok := s.pending[uri]; ok && p.text == doc.text {
+		publish = p.diags
+		delete(s.pending, uri)
+		s.hinted[uri] = true
+	} else if s.hinted[uri] {
+		publish = []diagnostic{}
+		delete(s.hinted, uri)
+	}
+	s.mu.Unlock()
+
+	if publish != nil {
+		s.conn.notify("textDocument/publishDiagnostics", map[string]any{
+			"uri":         uri,
+			"diagnostics": publish,
+		})
+	}
+}
+
+func (s *server) codeActions(params json.RawMessage) (any, error) {
+	var p struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+		Range Range `json:"range"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	p.Range = p.Range.ordered()
+
+	s.mu.Lock()
+	doc, ok := s.docs[p.TextDocument.URI]
+	s.mu.Unlock()
+	if !ok {
+		return []codeAction{}, nil
+	}
+	text := doc.text
+
+	actions := []codeAction{}
+	start, end := offsetAt(text, p.Range.Start), offsetAt(text, p.Range.End)
+	if strings.TrimSpace(text[start:end]) != "" {
+		actions = append(actions, codeAction{
+			Title: "Polish selection",
+			Kind:  "refactor.rewrite",
+			Data:  actionData{URI: p.TextDocument.URI, Version: doc.version, Range: p.Range, Kind: kindSelection},
+		})
+	}
+	if ps, pe := paragraphAt(text, start); pe > ps {
+		actions = append(actions, codeAction{
+			Title: "Polish paragraph",
+			Kind:  "refactor.rewrite",
+			Data: actionData{
+				URI:     p.TextDocument.URI,
+				Version: doc.version,
+				Range:   Range{Start: positionAt(text, ps), End: positionAt(text, pe)},
+				Kind:    kindParagraph,
+			},
+		})
+	}
+	return actions, nil
+}
+
+func (s *server) resolve(ctx context.Context, params json.RawMessage) (any, error) {
+	var action codeAction
+	if err := json.Unmarshal(params, &action); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	uri := action.Data.URI
+	action.Data.Range = action.Data.Range.ordered()
+
+	doc, err := s.document(uri, action.Data.Version)
+	if err != nil {
+		return nil, err
+	}
+	text := doc.text
+
+	start, end := offsetAt(text, action.Data.Range.Start), offsetAt(text, action.Data.Range.End)
+	original := text[start:end]
+	core := strings.TrimSpace(original)
+	if core == "" {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "nothing to polish"}
+	}
+
+	polished, err := s.polish(ctx, core)
+	if ctx.Err() != nil {
+		return nil, &rpcError{Code: codeRequestCancelled, Message: "request cancelled"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("polish failed: %w", err)
+	}
+
+	// Keep the selection's surrounding whitespace; models trim it.
+	lead := original[:strings.Index(original, core)]
+	trail := original[len(lead)+len(core):]
+	replacement := lead + polished + trail
+
+	newText := text[:start] + replacement + text[end:]
+	hints := changeHints(newText, start, original, replacement)
+	s.mu.Lock()
+	if cur, ok := s.docs[uri]; !ok || cur.version != doc.version {
+		s.mu.Unlock()
+		return nil, &rpcError{Code: codeContentModified, Message: "document changed while polishing"}
+	}
+	s.pending[uri] = pendingHints{text: newText, diags: hints}
+	s.mu.Unlock()
+
+	action.Edit = &workspaceEdit{DocumentChanges: []textDocumentEdit{{
+		TextDocument: versionedDocument{URI: uri, Version: doc.version},
+		Edits:        []textEdit{{Range: action.Data.Range, NewText: replacement}},
+	}}}
+	return action, nil
+}
+
+// document returns the open document at uri if it is still at version.
+func (s *server) document(uri string, version int) (document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, ok := s.docs[uri]
+	if !ok {
+		return document{}, &rpcError{Code: codeInvalidParams, Message: "document not open: " + uri}
+	}
+	if doc.version != version {
+		return document{}, &rpcError{Code: codeContentModified, Message: "document changed since the action was offered"}
+	}
+	return doc, nil
+}
+
+// changeHints returns one hint per changed word run, positioned in newText
+// where the replacement starts at byte offset base.
+func changeHints(newText string, base int, before, after string) []diagnostic {
+	edits := diff.Compute(diff.SplitWords(before), diff.SplitWords(after))
+
+	diags := []diagnostic{}
+	off := base
+	for i := 0; i < len(edits); {
+		if edits[i].Op == diff.Equal {
+			off += len(edits[i].Text)
+			i++
+			continue
+		}
+		var removed, added strings.Builder
+		start := off
+		for ; i < len(edits) && edits[i].Op != diff.Equal; i++ {
+			if edits[i].Op == diff.Delete {
+				removed.WriteString(edits[i].Text)
+			} else {
+				added.WriteString(edits[i].Text)
+				off += len(edits[i].Text)
+			}
+		}
+
+		var msg string
+		switch {
+		case added.Len() == 0:
+			msg = fmt.Sprintf("Pollex removed %q", strings.TrimSpace(removed.String()))
+		case removed.Len() == 0:
+			msg = fmt.Sprintf("Pollex added %q", strings.TrimSpace(added.String()))
+		default:
+			msg = fmt.Sprintf("Pollex: %q → %q", strings.TrimSpace(removed.String()), strings.TrimSpace(added.String()))
+		}
+		diags = append(diags, diagnostic{
+			Range:    Range{Start: positionAt(newText, start), End: positionAt(newText, off)},
+			Severity: severityHint,
+			Source:   "pollex",
+			Message:  msg,
+		})
+	}
+	return diags
+}
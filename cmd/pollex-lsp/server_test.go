@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testClient drives a server over in-memory pipes.
+type testClient struct {
+	t    *testing.T
+	in   *io.PipeWriter
+	out  *conn
+	next int
+	done chan bool
+}
+
+func newTestClient(t *testing.T, polish polishFunc) *testClient {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	tc := &testClient{t: t, in: inW, out: newConn(outR, nil), done: make(chan bool, 1)}
+	go func() {
+		tc.done <- newServer(inR, outW, polish).run()
+		outW.Close()
+	}()
+	return tc
+}
+
+func (tc *testClient) send(method string, params any, withID bool) int {
+	tc.t.Helper()
+	msg := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+	if withID {
+		tc.next++
+		msg["id"] = tc.next
+	}
+	body, _ := json.Marshal(msg)
+	fmt.Fprintf(tc.in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return tc.next
+}
+
+// recv reads frames until one matches method (notifications) or has an id.
+func (tc *testClient) recv(method string) map[string]json.RawMessage {
+	tc.t.Helper()
+	for {
+		headers, err := readFrame(tc.out)
+		if err != nil {
+			tc.t.Fatalf("read: %v", err)
+		}
+		var msg map[string]json.RawMessage
+		json.Unmarshal(headers, &msg)
+		if method == "" && msg["id"] != nil {
+			return msg
+		}
+		if method != "" && string(msg["method"]) == `"`+method+`"` {
+			return msg
+		}
+	}
+}
+
+func readFrame(c *conn) ([]byte, error) {
+	var n int
+	if _, err := fmt.Fscanf(c.r, "Content-Length: %d\r\n\r\n", &n); err != nil {
+		return nil, err
+	}
+	body := make([]byte, n)
+	_, err := io.ReadFull(c.r, body)
+	return body, err
+}
+
+func TestServerPolishParagraph(t *testing.T) {
+	polish := func(ctx context.Context, text string) (string, error) {
+		return strings.ReplaceAll(text, "goes", "went"), nil
+	}
+	tc := newTestClient(t, polish)
+	uri := "file:///tmp/doc.md"
+	doc := "# Title\n\nI goes to the store.\nIt were closed.\n\nOther paragraph.\n"
+
+	tc.send("initialize", map[string]any{}, true)
+	tc.recv("")
+	tc.send("initialized", map[string]any{}, false)
+	tc.send("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "markdown", "version": 1, "text": doc},
+	}, false)
+
+	cursor := Position{Line: 2, Character: 3}
+	tc.send("textDocument/codeAction", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"range":        Range{Start: cursor, End: cursor},
+		"context":      map[string]any{"diagnostics": []any{}},
+	}, true)
+	var actions []codeAction
+	json.Unmarshal(tc.recv("")["result"], &actions)
+	if len(actions) != 1 || actions[0].Title != "Polish paragraph" {
+		t.Fatalf("actions: got %+v, want only Polish paragraph for an empty selection", actions)
+	}
+	wantRange := Range{Start: Position{Line: 2}, End: Position{Line: 3, Character: 15}}
+	if actions[0].Data.Range != wantRange {
+		t.Errorf("paragraph range: got %+v, want %+v", actions[0].Data.Range, wantRange)
+	}
+
+	tc.send("codeAction/resolve", actions[0], true)
+	var resolved codeAction
+	json.Unmarshal(tc.recv("")["result"], &resolved)
+	if resolved.Edit == nil {
+		t.Fatal("resolved action has no edit")
+	}
+	changes := resolved.Edit.DocumentChanges
+	if len(changes) != 1 || changes[0].TextDocument != (versionedDocument{URI: uri, Version: 1}) {
+		t.Fatalf("document changes: got %+v, want one for version 1", changes)
+	}
+	edits := changes[0].Edits
+	if len(edits) != 1 || edits[0].NewText != "I went to the store.\nIt were closed." {
+		t.Fatalf("edits: got %+v", edits)
+	}
+
+	// Applying the edit publishes hints for the changed span.
+	applied := "# Title\n\nI went to the store.\nIt were closed.\n\nOther paragraph.\n"
+	tc.send("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 2},
+		"contentChanges": []map[string]any{{"text": applied}},
+	}, false)
+	var diags struct {
+		Diagnostics []diagnostic `json:"diagnostics"`
+	}
+	json.Unmarshal(tc.recv("textDocument/publishDiagnostics")["params"], &diags)
+	if len(diags.Diagnostics) != 1 {
+		t.Fatalf("diagnostics: got %+v, want 1", diags.Diagnostics)
+	}
+	want := Range{Start: Position{Line: 2, Character: 2}, End: Position{Line: 2, Character: 6}}
+	if diags.Diagnostics[0].Range != want {
+		t.Errorf("hint range: got %+v, want %+v", diags.Diagnostics[0].Range, want)
+	}
+
+	tc.send("shutdown", nil, true)
+	tc.recv("")
+	tc.send("exit", nil, false)
+	if !<-tc.done {
+		t.Error("run: got false after shutdown+exit, want true")
+	}
+}
+
+func TestServerSelectionAction(t *testing.T) {
+	tc := newTestClient(t, func(ctx context.Context, text string) (string, error) { return text, nil })
+	uri := "file:///tmp/a.txt"
+
+	tc.send("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "text": "one two three"},
+	}, false)
+	tc.send("textDocument/codeAction", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"range":        Range{Start: Position{Character: 4}, End: Position{Character: 7}},
+	}, true)
+	var actions []codeAction
+	json.Unmarshal(tc.recv("")["result"], &actions)
+	if len(actions) != 2 || actions[0].Title != "Polish selection" {
+		t.Fatalf("actions: got %+v", actions)
+	}
+
+	tc.send("exit", nil, false)
+	if <-tc.done {
+		t.Error("run: got true without shutdown, want false")
+	}
+}
+
+func TestServerReversedRange(t *testing.T) {
+	tc := newTestClient(t, func(ctx context.Context, text string) (string, error) { return strings.ToUpper(text), nil })
+	uri := "file:///tmp/a.txt"
+
+	tc.send("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "text": "one two three"},
+	}, false)
+	reversed := Range{Start: Position{Character: 7}, End: Position{Character: 4}}
+	tc.send("textDocument/codeAction", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"range":        reversed,
+	}, true)
+	var actions []codeAction
+	json.Unmarshal(tc.recv("")["result"], &actions)
+	if len(actions) != 2 || actions[0].Title != "Polish selection" {
+		t.Fatalf("actions: got %+v", actions)
+	}
+
+	// A stale action can still carry a reversed range; resolve must not panic.
+	action := actions[0]
+	action.Data.Range = reversed
+	tc.send("codeAction/resolve", action, true)
+	var resolved codeAction
+	json.Unmarshal(tc.recv("")["result"], &resolved)
+	if resolved.Edit == nil {
+		t.Fatal("resolved action has no edit")
+	}
+	want := textEdit{Range: reversed.ordered(), NewText: "TWO"}
+	if changes := resolved.Edit.DocumentChanges; len(changes) != 1 || len(changes[0].Edits) != 1 || changes[0].Edits[0] != want {
+		t.Errorf("edits: got %+v, want %+v", changes, want)
+	}
+
+	tc.send("exit", nil, false)
+	<-tc.done
+}
+
+func TestServerResolveStale(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	polish := func(ctx context.Context, text string) (string, error) {
+		started <- struct{}{}
+		select {
+		case <-release:
+			return strings.ToUpper(text), nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	tc := newTestClient(t, polish)
+	uri := "file:///tmp/a.txt"
+	change := func(version int, text string) {
+		tc.send("textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": uri, "version": version},
+			"contentChanges": []map[string]any{{"text": text}},
+		}, false)
+	}
+	tc.send("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "version": 1, "text": "one two three"},
+	}, false)
+	tc.send("textDocument/codeAction", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"range":        Range{Start: Position{Character: 4}, End: Position{Character: 7}},
+	}, true)
+	var actions []codeAction
+	json.Unmarshal(tc.recv("")["result"], &actions)
+	if len(actions) == 0 || actions[0].Data.Version != 1 {
+		t.Fatalf("actions: got %+v, want version 1", actions)
+	}
+	action := actions[0]
+
+	errorCode := func() int {
+		t.Helper()
+		var e rpcError
+		json.Unmarshal(tc.recv("")["error"], &e)
+		return e.Code
+	}
+
+	// Edited while the polish runs.
+	tc.send("codeAction/resolve", action, true)
+	<-started
+	change(2, "zero one two three")
+	release <- struct{}{}
+	if code := errorCode(); code != codeContentModified {
+		t.Errorf("edited during polish: got code %d, want %d", code, codeContentModified)
+	}
+
+	// Edited between the two requests: no polish at all.
+	tc.send("codeAction/resolve", action, true)
+	if code := errorCode(); code != codeContentModified {
+		t.Errorf("edited before resolve: got code %d, want %d", code, codeContentModified)
+	}
+
+	// Cancelled by the client.
+	action.Data.Version = 2
+	id := tc.send("codeAction/resolve", action, true)
+	<-started
+	tc.send("$/cancelRequest", map[string]any{"id": id}, false)
+	if code := errorCode(); code != codeRequestCancelled {
+		t.Errorf("cancelled: got code %d, want %d", code, codeRequestCancelled)
+	}
+
+	tc.send("exit", nil, false)
+	<-tc.done
+}
+
+func TestPositionRoundTrip(t *testing.T) {
+	text := "héllo 😀 world\nsecond"
+
+	p := positionAt(text, strings.Index(text, "world"))
+	if p != (Position{Line: 0, Character: 9}) {
+		t.Errorf("positionAt: got %+v, want {0 9} (emoji is 2 UTF-16 units)", p)
+	}
+	if got := offsetAt(text, p); got != strings.Index(text, "world") {
+		t.Errorf("offsetAt: got %d, want %d", got, strings.Index(text, "world"))
+	}
+	if got := offsetAt(text, Position{Line: 1, Character: 99}); got != len(text) {
+		t.Errorf("offsetAt past end: got %d, want %d", got, len(text))
+	}
+}
+
+func TestParagraphAt(t *testing.T) {
+	text := "first para\nline two\n\nsecond\n"
+
+	if s, e := paragraphAt(text, 3); text[s:e] != "first para\nline two" {
+		t.Errorf("paragraph: got %q", text[s:e])
+	}
+	if s, e := paragraphAt(text, len("first para\nline two\n")); s != e {
+		t.Errorf("blank line: got %q, want empty", text[s:e])
+	}
+	if s, e := paragraphAt(text, len(text)-2); text[s:e] != "second" {
+		t.Errorf("last paragraph: got %q", text[s:e])
+	}
+}
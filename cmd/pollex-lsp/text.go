@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Position and Range follow the LSP spec: zero-based lines, and characters
+// counted in UTF-16 code units.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// ordered returns r with Start and End swapped if End comes first. Clients
+// may send a backwards selection, or a stale one after an edit.
+func (r Range) ordered() Range {
+	if r.End.Line < r.Start.Line || r.End.Line == r.Start.Line && r.End.Character < r.Start.Character {
+		r.Start, r.End = r.End, r.Start
+	}
+	return r
+}
+
+// offsetAt converts an LSP position to a byte offset in text, clamping
+// positions past the end of a line or of the document.
+func offsetAt(text string, p Position) int {
+	off := 0
+	for line := 0; line < p.Line; line++ {
+		i := strings.IndexByte(text[off:], '\n')
+		if i < 0 {
+			return len(text)
+		}
+		off += i + 1
+	}
+	units := 0
+	for off < len(text) && units < p.Character {
+		r, size := utf8.DecodeRuneInString(text[off:])
+		if r == '\n' {
+			break
+		}
+		units += utf16.RuneLen(r)
+		off += size
+	}
+	return off
+}
+
+// positionAt converts a byte offset in text to an LSP position.
+func positionAt(text string, off int) Position {
+	off = min(off, len(text))
+	line := strings.Count(text[:off], "\n")
+	lineStart := strings.LastIndexByte(text[:off], '\n') + 1
+	units := 0
+	for _, r := range text[lineStart:off] {
+		units += utf16.RuneLen(r)
+	}
+	return Position{Line: line, Character: units}
+}
+
+// paragraphAt returns the byte range of the paragraph (block of non-blank
+// lines) containing off, without the trailing newline. It returns an empty
+// range when off sits on a blank line.
+func paragraphAt(text string, off int) (start, end int) {
+	pos := 0
+	inPara := false
+	for _, line := range strings.SplitAfter(text, "\n") {
+		lineEnd := pos + len(line)
+		if strings.TrimSpace(line) == "" {
+			if inPara && off < pos {
+				return start, end
+			}
+			inPara = false
+		} else {
+			if !inPara {
+				start, inPara = pos, true
+			}
+			end = pos + len(strings.TrimRight(line, "\r\n"))
+		}
+		if !inPara && off < lineEnd {
+			return off, off
+		}
+		pos = lineEnd
+	}
+	if inPara && off >= start {
+		return start, end
+	}
+	return off, off
+}
@@ -13,8 +13,20 @@ import (
 
 	"github.com/mlorentedev/pollex/internal/adapter"
 	"github.com/mlorentedev/pollex/internal/config"
+	"github.com/mlorentedev/pollex/internal/experiment"
+	"github.com/mlorentedev/pollex/internal/glossary"
+	"github.com/mlorentedev/pollex/internal/history"
+	"github.com/mlorentedev/pollex/internal/injection"
+	"github.com/mlorentedev/pollex/internal/lint"
 	"github.com/mlorentedev/pollex/internal/metrics"
+	"github.com/mlorentedev/pollex/internal/prompt"
+	"github.com/mlorentedev/pollex/internal/rank"
+	"github.com/mlorentedev/pollex/internal/router"
+	"github.com/mlorentedev/pollex/internal/segment"
 	"github.com/mlorentedev/pollex/internal/server"
+	"github.com/mlorentedev/pollex/internal/shadow"
+	"github.com/mlorentedev/pollex/internal/supervisor"
+	"github.com/mlorentedev/pollex/internal/warmup"
 )
 
 var version = "dev"
@@ -36,28 +48,192 @@ func main() {
 		cfg.Port = *port
 	}
 
-	promptData, err := os.ReadFile(cfg.PromptPath)
+	prompts, err := prompt.Load(cfg)
 	if err != nil {
-		slog.Error("prompt read failed", "path", cfg.PromptPath, "error", err)
+		slog.Error("prompt load failed", "path", cfg.PromptPath, "error", err)
 		os.Exit(1)
 	}
-	systemPrompt := string(promptData)
+	base, err := prompts.Render("", prompt.Vars{})
+	if err != nil {
+		slog.Error("prompt render failed", "path", cfg.PromptPath, "error", err)
+		os.Exit(1)
+	}
+	systemPrompt := base.System
+	glossaries, err := glossary.Load(cfg)
+	if err != nil {
+		slog.Error("glossary load failed", "error", err)
+		os.Exit(1)
+	}
+	if glossaries != nil {
+		slog.Info("glossary enabled", "terms", len(glossaries.For("").Terms()), "key_glossaries", len(cfg.KeyGlossaries))
+	}
 
-	adapters, models := buildAdapters(cfg, *useMock)
-	handler := server.SetupMux(adapters, models, systemPrompt, cfg.APIKey, version)
+	adapters, models, err := adapter.Build(cfg, *useMock)
+	if err != nil {
+		slog.Error("adapter setup failed", "error", err)
+		os.Exit(1)
+	}
+	if *useMock && len(cfg.Routes) > 0 {
+		slog.Info("routes ignored in mock mode", "routes", len(cfg.Routes))
+		cfg.Routes = nil
+	}
+	rt, err := router.New(cfg.Routes, adapters, models, cfg.IsRemote, cfg.LocalOnlyKeys)
+	if err != nil {
+		slog.Error("router setup failed", "error", err)
+		os.Exit(1)
+	}
+	var experiments *experiment.Manager
+	if *useMock && len(cfg.Experiments) > 0 {
+		slog.Info("experiments ignored in mock mode", "experiments", len(cfg.Experiments))
+	} else if len(cfg.Experiments) > 0 {
+		experiments, err = experiment.New(cfg.Experiments, adapters)
+		if err != nil {
+			slog.Error("experiments setup failed", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("experiments enabled", "experiments", len(cfg.Experiments), "admin_keys", len(cfg.AdminKeys))
+	}
+	keys := cfg.Keys()
+
+	var store *history.Store
+	if cfg.HistoryPath != "" {
+		store, err = history.Open(cfg.HistoryPath, cfg.HistoryMaxEntries, cfg.HistoryMaxAge)
+		if err != nil {
+			slog.Error("history open failed", "path", cfg.HistoryPath, "error", err)
+			os.Exit(1)
+		}
+		defer store.Close()
+		slog.Info("history enabled", "path", cfg.HistoryPath, "max_entries", cfg.HistoryMaxEntries, "max_age", cfg.HistoryMaxAge)
+	}
+
+	var mirror *shadow.Mirror
+	if candidate := adapter.BuildShadow(cfg); candidate != nil && !*useMock {
+		var err error
+		mirror, err = shadow.New(shadow.Options{
+			Adapter:     candidate,
+			Model:       cfg.ShadowModel,
+			Percent:     cfg.ShadowPercent,
+			Concurrency: cfg.ShadowConcurrency,
+			Timeout:     cfg.RequestTimeout,
+			Path:        cfg.ShadowPath,
+			MaxEntries:  cfg.HistoryMaxEntries,
+			MaxAge:      cfg.HistoryMaxAge,
+		})
+		if err != nil {
+			slog.Error("shadow setup failed", "path", cfg.ShadowPath, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("shadow traffic enabled", "shadow", cfg.ShadowModel, "url", cfg.ShadowURL, "percent", cfg.ShadowPercent, "path", cfg.ShadowPath)
+	}
+
+	var sup *supervisor.Supervisor
+	supCtx, stopSupervisor := context.WithCancel(context.Background())
+	supDone := make(chan struct{})
+	if cfg.LlamaServerBin != "" && !*useMock {
+		sup = supervisor.New(supervisor.Options{
+			Bin:           cfg.LlamaServerBin,
+			Args:          cfg.LlamaServerArgs,
+			URL:           cfg.LlamaCppURL,
+			CheckInterval: cfg.SuperviseInterval,
+			HangTimeout:   cfg.SuperviseHangTimeout,
+			MaxFailures:   cfg.SuperviseFailures,
+			StartupGrace:  cfg.SuperviseStartupGrace,
+		})
+		if a, ok := adapters[cfg.LlamaCppModel]; ok {
+			adapters[cfg.LlamaCppModel] = sup.Wrap(a)
+		}
+		go func() {
+			sup.Run(supCtx)
+			close(supDone)
+		}()
+		slog.Info("supervising llama-server", "bin", cfg.LlamaServerBin, "url", cfg.LlamaCppURL)
+	} else {
+		close(supDone)
+	}
+
+	var warmer *warmup.Warmer
+	warmCtx, stopWarmer := context.WithCancel(context.Background())
+	defer stopWarmer()
+	if cfg.Warmup && !*useMock {
+		opts := warmup.Options{
+			Text:         cfg.WarmupText,
+			SystemPrompt: systemPrompt,
+			KeepWarm:     cfg.KeepWarmInterval,
+			Timeout:      cfg.RequestTimeout,
+		}
+		if sup != nil {
+			// A cold start can outlast the hang timeout; keep the probe off.
+			opts.WrapMember = sup.Wrap
+		}
+		warmer = warmup.New(opts)
+		for id, a := range adapters {
+			if !cfg.IsRemote(id) && id != adapter.RulesModel {
+				adapters[id] = warmer.Wrap(id, a)
+			}
+		}
+		go warmer.Run(warmCtx)
+		slog.Info("warmup enabled", "keep_warm_interval", cfg.KeepWarmInterval)
+	}
+
+	linter := lint.New(lint.Options{
+		BannedWords:      cfg.LintBannedWords,
+		MaxSentenceWords: cfg.LintMaxSentenceWords,
+	})
+
+	var detector *injection.Detector
+	if cfg.Injection.Action != injection.ActionOff {
+		detector, err = injection.New(cfg.Injection)
+		if err != nil {
+			slog.Error("injection setup failed", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var segments *segment.Cache
+	if cfg.SegmentCacheSize > 0 {
+		segments = segment.NewCache(cfg.SegmentCacheSize)
+	}
+
+	handler := server.SetupMux(server.Options{
+		Adapters:       adapters,
+		Models:         models,
+		SystemPrompt:   systemPrompt,
+		Prompts:        prompts,
+		Glossary:       glossaries,
+		Linter:         linter,
+		Ranker:         rank.New(cfg.Alternatives, linter),
+		Injection:      detector,
+		Segments:       segments,
+		APIKeys:        keys,
+		Version:        version,
+		RequestTimeout: cfg.RequestTimeout,
+		History:        store,
+		Router:         rt,
+		Experiments:    experiments,
+		AdminKeys:      cfg.AdminKeys,
+		Shadow:         mirror,
+		Supervisor:     sup,
+		Warmer:         warmer,
+	})
 
 	startAdapterProbe(adapters, 30*time.Second)
 
-	if cfg.APIKey != "" {
-		slog.Info("auth enabled", "mode", "X-API-Key header")
+	if len(keys) > 0 {
+		slog.Info("auth enabled", "mode", "X-API-Key header", "keys", len(keys))
 	} else {
 		slog.Info("auth disabled", "reason", "no api_key configured")
 	}
 
 	addr := fmt.Sprintf(":%d", cfg.Port)
 	srv := &http.Server{
-		Addr:    addr,
-		Handler: handler,
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		// Handlers give up at RequestTimeout; the margin lets them write
+		// the 504 before the connection is cut.
+		WriteTimeout: cfg.RequestTimeout + 10*time.Second,
+		IdleTimeout:  2 * time.Minute,
 	}
 
 	done := make(chan os.Signal, 1)
@@ -77,7 +253,13 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := srv.Shutdown(ctx); err != nil {
+	err = srv.Shutdown(ctx)
+	if mirror != nil {
+		mirror.Close()
+	}
+	stopSupervisor()
+	<-supDone
+	if err != nil {
 		slog.Error("shutdown failed", "error", err)
 		os.Exit(1)
 	}
@@ -104,58 +286,3 @@ func startAdapterProbe(adapters map[string]adapter.LLMAdapter, interval time.Dur
 		}
 	}()
 }
-
-func buildAdapters(cfg config.Config, useMock bool) (map[string]adapter.LLMAdapter, []adapter.ModelInfo) {
-	adapters := make(map[string]adapter.LLMAdapter)
-	var models []adapter.ModelInfo
-
-	if useMock {
-		adapters["mock"] = &adapter.MockAdapter{Delay: 500 * time.Millisecond}
-		models = append(models, adapter.ModelInfo{ID: "mock", Name: "Mock (dev)", Provider: "mock"})
-		slog.Info("adapter registered", "adapter", "mock")
-		return adapters, models
-	}
-
-	// 1. llama.cpp (Highest priority for local GPU)
-	if cfg.LlamaCppURL != "" {
-		model := cfg.LlamaCppModel
-		if model == "" {
-			model = "qwen2.5-1.5b-gpu"
-		}
-		llama := &adapter.LlamaCppAdapter{
-			BaseURL: cfg.LlamaCppURL,
-			Model:   model,
-			Client:  &http.Client{Timeout: 120 * time.Second},
-		}
-		adapters[model] = llama
-		models = append(models, adapter.ModelInfo{ID: model, Name: "llama.cpp (" + model + ")", Provider: "llamacpp"})
-		slog.Info("adapter registered", "adapter", "llamacpp", "url", cfg.LlamaCppURL, "model", model)
-	}
-
-	// 2. Claude (Optional cloud fallback)
-	if cfg.ClaudeAPIKey != "" {
-		claude := &adapter.ClaudeAdapter{
-			APIKey: cfg.ClaudeAPIKey,
-			Model:  cfg.ClaudeModel,
-			Client: &http.Client{Timeout: 60 * time.Second},
-		}
-		adapters[cfg.ClaudeModel] = claude
-		models = append(models, adapter.ModelInfo{ID: cfg.ClaudeModel, Name: "Claude (" + cfg.ClaudeModel + ")", Provider: "claude"})
-		slog.Info("adapter registered", "adapter", "claude", "model", cfg.ClaudeModel)
-	}
-
-	// 3. Ollama (Optional or legacy fallback)
-	if cfg.OllamaURL != "" {
-		model := "qwen2.5:1.5b"
-		ollama := &adapter.OllamaAdapter{
-			BaseURL: cfg.OllamaURL,
-			Model:   model,
-			Client:  &http.Client{Timeout: 60 * time.Second},
-		}
-		adapters[model] = ollama
-		models = append(models, adapter.ModelInfo{ID: model, Name: "Qwen 2.5 1.5B", Provider: "ollama"})
-		slog.Info("adapter registered", "adapter", "ollama", "url", cfg.OllamaURL)
-	}
-
-	return adapters, models
-}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// scissorsMark follows the comment character on the line that marks the
+// start of the diff appended by `git commit -v`.
+const scissorsMark = " ------------------------ >8 ------------------------"
+
+// autoCommentChars are the characters git picks from, in order, when
+// core.commentChar is "auto".
+const autoCommentChars = "#;@!$%^&|:"
+
+var trailerRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*: \S`)
+
+// commitMessage is a commit message split into the part we polish and the
+// parts that must be written back byte-for-byte.
+type commitMessage struct {
+	body     string // subject and body
+	trailers string // trailing "Key: value" block, e.g. Signed-off-by
+	tail     string // comment lines and anything after the scissors line
+}
+
+// parseCommitMessage splits msg, whose comment lines start with comment.
+func parseCommitMessage(msg, comment string) commitMessage {
+	var cm commitMessage
+
+	if i := strings.Index(msg, comment+scissorsMark); i >= 0 && (i == 0 || msg[i-1] == '\n') {
+		cm.tail = msg[i:]
+		msg = msg[:i]
+	}
+
+	// Git strips comment lines itself; keep them out of the model input.
+	var kept, comments []string
+	for _, line := range strings.SplitAfter(msg, "\n") {
+		if strings.HasPrefix(line, comment) {
+			comments = append(comments, line)
+		} else {
+			kept = append(kept, line)
+		}
+	}
+	cm.tail = strings.Join(comments, "") + cm.tail
+	text := strings.TrimRight(strings.Join(kept, ""), "\n")
+
+	// The trailer block is the last paragraph if every line in it is a trailer.
+	paragraphs := strings.Split(text, "\n\n")
+	if len(paragraphs) > 1 {
+		last := paragraphs[len(paragraphs)-1]
+		allTrailers := true
+		for _, line := range strings.Split(last, "\n") {
+			if !trailerRe.MatchString(line) {
+				allTrailers = false
+				break
+			}
+		}
+		if allTrailers {
+			cm.trailers = last
+			text = strings.TrimRight(strings.Join(paragraphs[:len(paragraphs)-1], "\n\n"), "\n")
+		}
+	}
+	cm.body = text
+	return cm
+}
+
+// commentPrefix returns the comment character git used in msg, given the
+// core.commentChar setting. For "auto", git picks a character none of the
+// message's lines start with and writes its comment block after them, so
+// the scissors line, or failing that the last line, tells which one it was.
+func commentPrefix(msg, setting string) string {
+	switch setting {
+	case "":
+		return "#"
+	case "auto":
+	default:
+		return setting
+	}
+	lines := strings.Split(strings.TrimRight(msg, "\n"), "\n")
+	for _, line := range lines {
+		if c, ok := strings.CutSuffix(line, scissorsMark); ok && len(c) == 1 && strings.Contains(autoCommentChars, c) {
+			return c
+		}
+	}
+	if last := lines[len(lines)-1]; last != "" && strings.ContainsRune(autoCommentChars, rune(last[0])) {
+		return last[:1]
+	}
+	return "#"
+}
+
+// gitConfig returns the value of key, or "" if it is unset or git fails.
+func gitConfig(key string) string {
+	out, err := exec.Command("git", "config", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (cm commitMessage) String() string {
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(cm.body, "\n"))
+	b.WriteString("\n")
+	if cm.trailers != "" {
+		b.WriteString("\n" + cm.trailers + "\n")
+	}
+	if cm.tail != "" {
+		b.WriteString(cm.tail)
+	}
+	return b.String()
+}
+
+// skipPolish reports whether git generated the subject, in which case the
+// message is left alone.
+func (cm commitMessage) skipPolish() bool {
+	if strings.TrimSpace(cm.body) == "" {
+		return true
+	}
+	for _, prefix := range []string{"fixup!", "squash!", "amend!", "Merge ", "Revert \""} {
+		if strings.HasPrefix(cm.body, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func runCommitMsg(args []string) error {
+	fs := flag.NewFlagSet("commit-msg", flag.ExitOnError)
+	var opts options
+	opts.register(fs)
+	strict := fs.Bool("strict", false, "abort the commit when polishing fails (default: warn and keep the original)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("usage: pollexctl commit-msg [flags] <message-file>")
+	}
+	file := fs.Arg(0)
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	cm := parseCommitMessage(string(data), commentPrefix(string(data), gitConfig("core.commentChar")))
+	if cm.skipPolish() {
+		return nil
+	}
+
+	err = func() error {
+		p, err := newPolisher(opts)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+		defer cancel()
+		polished, err := p.polish(ctx, cm.body)
+		if err != nil {
+			return err
+		}
+		cm.body = polished
+		return os.WriteFile(file, []byte(cm.String()), 0644)
+	}()
+	if err != nil && !*strict {
+		fmt.Fprintf(os.Stderr, "pollexctl: commit message not polished: %v\n", err)
+		return nil
+	}
+	return err
+}
+
+const hookScript = `#!/bin/sh
+# Installed by pollexctl install-hook.
+exec pollexctl commit-msg "$1"
+`
+
+func runInstallHook(args []string) error {
+	fs := flag.NewFlagSet("install-hook", flag.ExitOnError)
+	force := fs.Bool("force", false, "overwrite an existing commit-msg hook")
+	fs.Parse(args)
+
+	out, err := exec.Command("git", "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		return fmt.Errorf("not a git repository: %w", err)
+	}
+	dir := strings.TrimSpace(string(out))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, "commit-msg")
+	if _, err := os.Stat(path); err == nil && !*force {
+		return fmt.Errorf("%s already exists (use -force to overwrite)", path)
+	}
+	if err := os.WriteFile(path, []byte(hookScript), 0755); err != nil {
+		return err
+	}
+	fmt.Printf("installed %s\n", path)
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import "testing"
+
+func TestParseCommitMessage(t *testing.T) {
+	msg := `fix the thing wich was broke
+
+it were failing when input is empty.
+
+Signed-off-by: Jane Doe <jane@example.com>
+Co-authored-by: Sam Roe <sam@example.com>
+# Please enter the commit message for your changes.
+# On branch master
+`
+
+	cm := parseCommitMessage(msg, "#")
+
+	if cm.body != "fix the thing wich was broke\n\nit were failing when input is empty." {
+		t.Errorf("body: got %q", cm.body)
+	}
+	if cm.trailers != "Signed-off-by: Jane Doe <jane@example.com>\nCo-authored-by: Sam Roe <sam@example.com>" {
+		t.Errorf("trailers: got %q", cm.trailers)
+	}
+	if cm.tail != "# Please enter the commit message for your changes.\n# On branch master\n" {
+		t.Errorf("tail: got %q", cm.tail)
+	}
+	if got := cm.String(); got != msg {
+		t.Errorf("round trip:\ngot:  %q\nwant: %q", got, msg)
+	}
+}
+
+func TestParseCommitMessageScissors(t *testing.T) {
+	scissors := "#" + scissorsMark
+	msg := "add feature\n\n" + scissors + "\ndiff --git a/x b/x\n+Key: value\n"
+
+	cm := parseCommitMessage(msg, "#")
+
+	if cm.body != "add feature" {
+		t.Errorf("body: got %q", cm.body)
+	}
+	if cm.trailers != "" {
+		t.Errorf("trailers: got %q, want empty", cm.trailers)
+	}
+	if cm.tail != scissors+"\ndiff --git a/x b/x\n+Key: value\n" {
+		t.Errorf("tail: got %q", cm.tail)
+	}
+}
+
+func TestParseCommitMessageNoTrailers(t *testing.T) {
+	cm := parseCommitMessage("subject\n\nNote: this paragraph\nis prose, not trailers.\n", "#")
+
+	if cm.trailers != "" {
+		t.Errorf("trailers: got %q, want empty", cm.trailers)
+	}
+	if cm.body != "subject\n\nNote: this paragraph\nis prose, not trailers." {
+		t.Errorf("body: got %q", cm.body)
+	}
+}
+
+func TestCommitMessageSkipPolish(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want bool
+	}{
+		{"", true},
+		{"# only comments\n", true},
+		{"fixup! earlier commit\n", true},
+		{"Merge branch 'main' into feature\n", true},
+		{"add a feature\n", false},
+	}
+	for _, tt := range tests {
+		if got := parseCommitMessage(tt.msg, "#").skipPolish(); got != tt.want {
+			t.Errorf("skipPolish(%q): got %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestParseCommitMessageCommentChar(t *testing.T) {
+	msg := "fix teh parser\n\n#123 was the cause.\n; Please enter the commit message for your changes.\n; On branch master\n"
+
+	cm := parseCommitMessage(msg, commentPrefix(msg, ";"))
+	if cm.body != "fix teh parser\n\n#123 was the cause." {
+		t.Errorf("body: got %q", cm.body)
+	}
+	if cm.tail != "; Please enter the commit message for your changes.\n; On branch master\n" {
+		t.Errorf("tail: got %q", cm.tail)
+	}
+	if got := cm.String(); got != msg {
+		t.Errorf("round trip:\ngot:  %q\nwant: %q", got, msg)
+	}
+
+	verbose := "fix teh parser\n\n;" + scissorsMark + "\ndiff --git a/x b/x\n"
+	if cm := parseCommitMessage(verbose, ";"); cm.body != "fix teh parser" {
+		t.Errorf("scissors body: got %q", cm.body)
+	}
+}
+
+func TestCommentPrefix(t *testing.T) {
+	tests := []struct {
+		name, msg, setting, want string
+	}{
+		{"default", "subject\n# comment\n", "", "#"},
+		{"configured", "subject\n; comment\n", ";", ";"},
+		{"auto comment block", "#123 subject\n; Please enter the commit message\n;\n", "auto", ";"},
+		{"auto scissors", "#1 subject\n@" + scissorsMark + "\n+diff line\n", "auto", "@"},
+		{"auto without comments", "subject\n", "auto", "#"},
+	}
+	for _, tt := range tests {
+		if got := commentPrefix(tt.msg, tt.setting); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mlorentedev/pollex/internal/adapter"
+	"github.com/mlorentedev/pollex/internal/config"
+	"github.com/mlorentedev/pollex/internal/diff"
+	"github.com/mlorentedev/pollex/internal/glossary"
+	"github.com/mlorentedev/pollex/internal/prompt"
+	"github.com/mlorentedev/pollex/pkg/client"
+)
+
+var version = "dev"
+
+const usage = `pollexctl — polish text with a Pollex server from the terminal.
+
+Usage:
+  pollexctl [flags] [file]           polish file (or stdin) and print the result
+  pollexctl commit-msg [flags] file  git commit-msg hook: polish the message in place
+  pollexctl install-hook [-force]    install the commit-msg hook in the current repo
+  pollexctl version
+
+Flags:
+`
+
+// options are shared by every polishing subcommand.
+type options struct {
+	url     string
+	apiKey  string
+	model   string
+	local   bool
+	config  string
+	mock    bool
+	timeout time.Duration
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.url, "url", envOr("POLLEX_URL", "http://localhost:8090"), "server URL (env POLLEX_URL)")
+	fs.StringVar(&o.apiKey, "api-key", os.Getenv("POLLEX_API_KEY"), "API key (env POLLEX_API_KEY)")
+	fs.StringVar(&o.model, "model", os.Getenv("POLLEX_MODEL"), "model ID (env POLLEX_MODEL, default: first available)")
+	fs.BoolVar(&o.local, "local", false, "polish in-process with the adapters from -config instead of calling a server")
+	fs.StringVar(&o.config, "config", "", "config.yaml for -local")
+	fs.BoolVar(&o.mock, "mock", false, "with -local, use the mock adapter")
+	fs.DurationVar(&o.timeout, "timeout", 2*time.Minute, "per-request timeout")
+}
+
+func main() {
+	// Adapters log registration; keep stdout clean for the polished text.
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	args := os.Args[1:]
+	var err error
+	switch {
+	case len(args) > 0 && args[0] == "commit-msg":
+		err = runCommitMsg(args[1:])
+	case len(args) > 0 && args[0] == "install-hook":
+		err = runInstallHook(args[1:])
+	case len(args) > 0 && args[0] == "version":
+		fmt.Println(version)
+	default:
+		err = runPolish(args)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pollexctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runPolish(args []string) error {
+	fs := flag.NewFlagSet("pollexctl", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	var opts options
+	opts.register(fs)
+	write := fs.Bool("w", false, "write the result back to file instead of stdout")
+	showDiff := fs.Bool("diff", false, "print a unified diff instead of the result")
+	edit := fs.Bool("e", false, "compose or edit the text in $EDITOR before polishing")
+	fs.Parse(args)
+
+	if fs.NArg() > 1 {
+		return errors.New("at most one file argument")
+	}
+	file := fs.Arg(0)
+	if *write && file == "" {
+		return errors.New("-w requires a file argument")
+	}
+
+	text, err := readInput(file)
+	if err != nil {
+		return err
+	}
+	if *edit {
+		if text, err = editText(text); err != nil {
+			return err
+		}
+	}
+	if strings.TrimSpace(text) == "" {
+		return errors.New("no input text")
+	}
+
+	p, err := newPolisher(opts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+
+	polished, err := p.polish(ctx, text)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(text, "\n") && !strings.HasSuffix(polished, "\n") {
+		polished += "\n"
+	}
+
+	if *showDiff {
+		name := file
+		if name == "" {
+			name = "stdin"
+		}
+		fmt.Print(diff.Unified(name, name+" (polished)", text, polished))
+	}
+	if *write {
+		return os.WriteFile(file, []byte(polished), 0644)
+	}
+	if !*showDiff {
+		fmt.Print(polished)
+	}
+	return nil
+}
+
+func readInput(file string) (string, error) {
+	if file == "" || file == "-" {
+		if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+			return "", nil
+		}
+		data, err := io.ReadAll(os.Stdin)
+		return string(data), err
+	}
+	data, err := os.ReadFile(file)
+	return string(data), err
+}
+
+// editText opens initial in $VISUAL/$EDITOR and returns the saved content.
+func editText(initial string) (string, error) {
+	editor := envOr("VISUAL", envOr("EDITOR", "vi"))
+
+	f, err := os.CreateTemp("", "pollex-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(initial); err != nil {
+		f.Close()
+		return "", err
+	}
+	f.Close()
+
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		tty = os.Stdin
+	} else {
+		defer tty.Close()
+	}
+
+	parts := strings.Fields(editor)
+	cmd := exec.Command(parts[0], append(parts[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %q: %w", editor, err)
+	}
+
+	data, err := os.ReadFile(f.Name())
+	return string(data), err
+}
+
+// polisher abstracts over a remote server and an in-process adapter.
+type polisher interface {
+	polish(ctx context.Context, text string) (string, error)
+}
+
+type remotePolisher struct {
+	client *client.Client
+	model  string
+}
+
+func (p *remotePolisher) polish(ctx context.Context, text string) (string, error) {
+	if p.model == "" {
+		models, err := p.client.Models(ctx)
+		if err != nil {
+			return "", err
+		}
+		if len(models) == 0 {
+			return "", errors.New("server has no models")
+		}
+		p.model = models[0].ID
+	}
+	resp, err := p.client.Polish(ctx, client.PolishRequest{Text: text, ModelID: p.model})
+	if err != nil {
+		return "", err
+	}
+	return resp.Polished, nil
+}
+
+type localPolisher struct {
+	adapter  adapter.LLMAdapter
+	prompt   prompt.Rendered
+	glossary *glossary.Glossary
+}
+
+func (p *localPolisher) polish(ctx context.Context, text string) (string, error) {
+	ctx = adapter.WithExamples(ctx, p.prompt.Examples)
+	out, err := p.adapter.Polish(ctx, text, p.prompt.System)
+	if err != nil {
+		return "", err
+	}
+	out, violations := p.glossary.Enforce(text, out)
+	for _, v := range violations {
+		fmt.Fprintf(os.Stderr, "pollexctl: glossary term %q dropped (%d of %d kept)\n", v.Term, v.Found, v.Expected)
+	}
+	return out, nil
+}
+
+func newPolisher(opts options) (polisher, error) {
+	if !opts.local {
+		c := client.New(opts.url, client.WithAPIKey(opts.apiKey), client.WithRetries(1, time.Second))
+		return &remotePolisher{client: c, model: opts.model}, nil
+	}
+
+	cfg, err := config.Load(opts.config)
+	if err != nil {
+		return nil, err
+	}
+	prompts, err := prompt.Load(cfg)
+	if err != nil {
+		return nil, err
+	}
+	adapters, models, err := adapter.Build(cfg, opts.mock)
+	if err != nil {
+		return nil, err
+	}
+	if len(models) == 0 {
+		return nil, errors.New("no adapters configured; set llamacpp_url, claude_api_key or ollama_url")
+	}
+	model := opts.model
+	if model == "" {
+		model = models[0].ID
+	}
+	a, ok := adapters[model]
+	if !ok {
+		return nil, fmt.Errorf("unknown model: %s", model)
+	}
+	glossaries, err := glossary.Load(cfg)
+	if err != nil {
+		return nil, err
+	}
+	gloss := glossaries.For("")
+	rendered, err := prompts.Render(model, prompt.Vars{Glossary: gloss.Terms()})
+	if err != nil {
+		return nil, err
+	}
+	return &localPolisher{adapter: a, prompt: rendered, glossary: gloss}, nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
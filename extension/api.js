@@ -23,7 +23,7 @@ async function buildHeaders() {
 async function fetchHealth(signal) {
   const base = await getApiUrl();
   const opts = signal ? { signal } : {};
-  const resp = await fetch(`${base}/api/health`, opts);
+  const resp = await fetch(`${base}/api/v1/health`, opts);
   if (!resp.ok) throw new Error(`Health check failed: ${resp.status}`);
   return resp.json();
 }
@@ -31,7 +31,7 @@ async function fetchHealth(signal) {
 async function fetchModels() {
   const base = await getApiUrl();
   const headers = await buildHeaders();
-  const resp = await fetch(`${base}/api/models`, { headers });
+  const resp = await fetch(`${base}/api/v1/models`, { headers });
   if (!resp.ok) throw new Error(`Failed to load models: ${resp.status}`);
   return resp.json();
 }
@@ -49,7 +49,7 @@ async function fetchPolish(text, modelId, signal) {
   }
 
   try {
-    const resp = await fetch(`${base}/api/polish`, {
+    const resp = await fetch(`${base}/api/v1/polish`, {
       method: "POST",
       headers,
       body: JSON.stringify({ text, model_id: modelId }),
@@ -58,7 +58,10 @@ async function fetchPolish(text, modelId, signal) {
 
     if (!resp.ok) {
       const body = await resp.json().catch(() => ({}));
-      throw new Error(body.error || `Request failed: ${resp.status}`);
+      const err = new Error(body.message || body.error || `Request failed: ${resp.status}`);
+      err.code = body.code;
+      err.retryable = body.retryable === true;
+      throw err;
     }
 
     return resp.json();
@@ -488,7 +488,7 @@ btnTest.addEventListener("click", async () => {
     const timeout = setTimeout(() => controller.abort(), 5000);
 
     try {
-      const resp = await fetch(`${url}/api/health`, { signal: controller.signal });
+      const resp = await fetch(`${url}/api/v1/health`, { signal: controller.signal });
       clearTimeout(timeout);
       if (!resp.ok) throw new Error(`Status ${resp.status}`);
       const data = await resp.json();
@@ -1,6 +1,9 @@
 package adapter
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
 
 // LLMAdapter defines the contract for LLM backends.
 type LLMAdapter interface {
@@ -15,3 +18,39 @@ type ModelInfo struct {
 	Name     string `json:"name"`
 	Provider string `json:"provider"`
 }
+
+// Base returns the innermost adapter of a chain of wrappers (such as
+// RedactingAdapter), so callers can inspect the concrete backend type.
+func Base(a LLMAdapter) LLMAdapter {
+	for {
+		w, ok := a.(interface{ Unwrap() LLMAdapter })
+		if !ok {
+			return a
+		}
+		a = w.Unwrap()
+	}
+}
+
+// Parallelism returns how many polishes a's local backend serves at once:
+// the endpoint count for a pool, and 1 otherwise, since llama-server and
+// Ollama run one generation per slot by default.
+func Parallelism(a LLMAdapter) int {
+	if p, ok := Base(a).(*PoolAdapter); ok {
+		return len(p.Members())
+	}
+	return 1
+}
+
+// StatusError reports a non-200 response from an upstream backend so callers
+// can tell client-side rejections (4xx) from backend failures (5xx).
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("API error: %s", e.Message)
+	}
+	return fmt.Sprintf("unexpected status %d", e.StatusCode)
+}
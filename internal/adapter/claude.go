@@ -29,6 +29,8 @@ type claudeMessagesRequest struct {
 	System    string          `json:"system"`
 	Messages  []claudeMessage `json:"messages"`
 	MaxTokens int             `json:"max_tokens"`
+
+	Temperature *float64 `json:"temperature,omitempty"`
 }
 
 type claudeContentBlock struct {
@@ -52,13 +54,14 @@ func (c *ClaudeAdapter) Name() string {
 
 func (c *ClaudeAdapter) Polish(ctx context.Context, text, systemPrompt string) (string, error) {
 	reqBody := claudeMessagesRequest{
-		Model:  c.Model,
-		System: systemPrompt,
-		Messages: []claudeMessage{
-			{Role: "user", Content: text},
-		},
+		Model:     c.Model,
+		System:    systemPrompt,
+		Messages:  claudeMessages(ctx, text),
 		MaxTokens: 4096,
 	}
+	if s, ok := SamplingFromContext(ctx); ok {
+		reqBody.Temperature = &s.Temperature
+	}
 
 	body, err := json.Marshal(reqBody)
 	if err != nil {
@@ -88,9 +91,9 @@ func (c *ClaudeAdapter) Polish(ctx context.Context, text, systemPrompt string) (
 	if resp.StatusCode != http.StatusOK {
 		var errResp claudeErrorResponse
 		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Error.Message == "" {
-			return "", fmt.Errorf("claude: unexpected status %d", resp.StatusCode)
+			return "", fmt.Errorf("claude: %w", &StatusError{StatusCode: resp.StatusCode})
 		}
-		return "", fmt.Errorf("claude: API error: %s", errResp.Error.Message)
+		return "", fmt.Errorf("claude: %w", &StatusError{StatusCode: resp.StatusCode, Message: errResp.Error.Message})
 	}
 
 	var msgResp claudeMessagesResponse
@@ -115,3 +118,16 @@ func (c *ClaudeAdapter) Polish(ctx context.Context, text, systemPrompt string) (
 func (c *ClaudeAdapter) Available() bool {
 	return c.APIKey != ""
 }
+
+// claudeMessages builds the conversation: few-shot examples and reference
+// from ctx, then the text. The system prompt goes in its own field.
+func claudeMessages(ctx context.Context, text string) []claudeMessage {
+	var msgs []claudeMessage
+	for _, ex := range ExamplesFromContext(ctx) {
+		msgs = append(msgs, claudeMessage{Role: "user", Content: ex.Input}, claudeMessage{Role: "assistant", Content: ex.Output})
+	}
+	if ref := referenceTurn(ctx); ref != "" {
+		msgs = append(msgs, claudeMessage{Role: "user", Content: ref})
+	}
+	return append(msgs, claudeMessage{Role: "user", Content: text})
+}
@@ -3,6 +3,7 @@ package adapter
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -71,6 +72,49 @@ func TestClaudeAdapterPolish(t *testing.T) {
 	}
 }
 
+func TestClaudeAdapterPolishExamples(t *testing.T) {
+	var got claudeMessagesRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(claudeMessagesResponse{Content: []claudeContentBlock{{Type: "text", Text: "ok"}}})
+	}))
+	defer srv.Close()
+
+	a := &ClaudeAdapter{BaseURL: srv.URL, APIKey: "sk-test", Model: "m", Client: &http.Client{Timeout: 5 * time.Second}}
+	ctx := WithExamples(context.Background(), []Example{{Input: "he go", Output: "He goes."}})
+	if _, err := a.Polish(ctx, "i goes", "Fix grammar."); err != nil {
+		t.Fatalf("Polish: %v", err)
+	}
+
+	if got.System != "Fix grammar." {
+		t.Errorf("system: got %q", got.System)
+	}
+	roles := ""
+	for _, m := range got.Messages {
+		roles += m.Role + " "
+	}
+	if roles != "user assistant user " || got.Messages[2].Content != "i goes" {
+		t.Errorf("messages: got %+v", got.Messages)
+	}
+}
+
+func TestClaudeAdapterPolishSampling(t *testing.T) {
+	var got claudeMessagesRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(claudeMessagesResponse{Content: []claudeContentBlock{{Type: "text", Text: "ok"}}})
+	}))
+	defer srv.Close()
+
+	a := &ClaudeAdapter{BaseURL: srv.URL, APIKey: "sk-test", Model: "m", Client: &http.Client{Timeout: 5 * time.Second}}
+	if _, err := a.Polish(WithSampling(context.Background(), Sampling{Temperature: 0.8, Seed: 3}), "hi", "p"); err != nil {
+		t.Fatalf("Polish: %v", err)
+	}
+	if got.Temperature == nil || *got.Temperature != 0.8 {
+		t.Errorf("temperature: got %v", got.Temperature)
+	}
+}
+
 func TestClaudeAdapterPolishServerError(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -94,7 +138,17 @@ func TestClaudeAdapterPolishServerError(t *testing.T) {
 
 	_, err := a.Polish(context.Background(), "hello", "prompt")
 	if err == nil {
-		t.Error("expected error on 400 response, got nil")
+		t.Fatal("expected error on 400 response, got nil")
+	}
+	var se *StatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("error: got %T, want *StatusError", err)
+	}
+	if se.StatusCode != 400 {
+		t.Errorf("status: got %d, want 400", se.StatusCode)
+	}
+	if se.Message != "bad request" {
+		t.Errorf("message: got %q, want %q", se.Message, "bad request")
 	}
 }
 
@@ -0,0 +1,50 @@
+package adapter
+
+import "context"
+
+// Example is one few-shot demonstration: a text and its polished version.
+// Backends send examples as alternating user/assistant turns between the
+// system prompt and the real text.
+type Example struct {
+	Input  string `yaml:"input" json:"input"`
+	Output string `yaml:"output" json:"output"`
+}
+
+type examplesKey struct{}
+
+// WithExamples attaches few-shot examples to ctx for the next Polish call.
+// Passing them in the context keeps LLMAdapter's signature stable for the
+// wrappers (redaction, pooling, warmup) that only forward calls.
+func WithExamples(ctx context.Context, examples []Example) context.Context {
+	if len(examples) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, examplesKey{}, examples)
+}
+
+// ExamplesFromContext returns the examples attached by WithExamples.
+func ExamplesFromContext(ctx context.Context) []Example {
+	ex, _ := ctx.Value(examplesKey{}).([]Example)
+	return ex
+}
+
+// Sampling varies generation so that repeated calls give different
+// candidates. Backends without seeds (Claude) use only the temperature.
+type Sampling struct {
+	Temperature float64
+	Seed        int
+}
+
+type samplingKey struct{}
+
+// WithSampling attaches s to ctx for the next Polish call. Without it
+// backends sample greedily (temperature 0) where they can.
+func WithSampling(ctx context.Context, s Sampling) context.Context {
+	return context.WithValue(ctx, samplingKey{}, s)
+}
+
+// SamplingFromContext returns the sampling attached by WithSampling.
+func SamplingFromContext(ctx context.Context) (Sampling, bool) {
+	s, ok := ctx.Value(samplingKey{}).(Sampling)
+	return s, ok
+}
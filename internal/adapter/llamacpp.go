@@ -26,6 +26,7 @@ type llamaCppChatRequest struct {
 	Model       string            `json:"model"`
 	Messages    []llamaCppMessage `json:"messages"`
 	Temperature float32           `json:"temperature"`
+	Seed        *int              `json:"seed,omitempty"`
 	MaxTokens   int               `json:"max_tokens,omitempty"`
 }
 
@@ -43,13 +44,13 @@ func (l *LlamaCppAdapter) Name() string {
 
 func (l *LlamaCppAdapter) Polish(ctx context.Context, text, systemPrompt string) (string, error) {
 	reqBody := llamaCppChatRequest{
-		Model: l.Model,
-		Messages: []llamaCppMessage{
-			{Role: "system", Content: systemPrompt},
-			{Role: "user", Content: text},
-		},
+		Model:       l.Model,
+		Messages:    llamaCppMessages(ctx, systemPrompt, text),
 		Temperature: 0,
 	}
+	if s, ok := SamplingFromContext(ctx); ok {
+		reqBody.Temperature, reqBody.Seed = float32(s.Temperature), &s.Seed
+	}
 
 	body, err := json.Marshal(reqBody)
 	if err != nil {
@@ -70,7 +71,7 @@ func (l *LlamaCppAdapter) Polish(ctx context.Context, text, systemPrompt string)
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("llamacpp: unexpected status %d", resp.StatusCode)
+		return "", fmt.Errorf("llamacpp: %w", &StatusError{StatusCode: resp.StatusCode})
 	}
 
 	var chatResp llamaCppChatResponse
@@ -101,3 +102,16 @@ func (l *LlamaCppAdapter) Available() bool {
 	resp.Body.Close()
 	return resp.StatusCode == http.StatusOK
 }
+
+// llamaCppMessages builds the conversation: system prompt, few-shot examples
+// and reference from ctx, then the text.
+func llamaCppMessages(ctx context.Context, systemPrompt, text string) []llamaCppMessage {
+	msgs := []llamaCppMessage{{Role: "system", Content: systemPrompt}}
+	for _, ex := range ExamplesFromContext(ctx) {
+		msgs = append(msgs, llamaCppMessage{Role: "user", Content: ex.Input}, llamaCppMessage{Role: "assistant", Content: ex.Output})
+	}
+	if ref := referenceTurn(ctx); ref != "" {
+		msgs = append(msgs, llamaCppMessage{Role: "user", Content: ref})
+	}
+	return append(msgs, llamaCppMessage{Role: "user", Content: text})
+}
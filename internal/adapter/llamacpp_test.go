@@ -3,6 +3,7 @@ package adapter
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -61,6 +62,81 @@ func TestLlamaCppAdapterPolish(t *testing.T) {
 	}
 }
 
+func TestLlamaCppAdapterPolishExamples(t *testing.T) {
+	var got []llamaCppMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llamaCppChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		got = req.Messages
+		json.NewEncoder(w).Encode(llamaCppChatResponse{Choices: []llamaCppChoice{{Message: llamaCppMessage{Content: "ok"}}}})
+	}))
+	defer srv.Close()
+
+	a := &LlamaCppAdapter{BaseURL: srv.URL, Model: "m", Client: &http.Client{Timeout: 5 * time.Second}}
+	ctx := WithExamples(context.Background(), []Example{{Input: "he go", Output: "He goes."}})
+	if _, err := a.Polish(ctx, "i goes", "Fix grammar."); err != nil {
+		t.Fatalf("Polish: %v", err)
+	}
+
+	want := []llamaCppMessage{
+		{Role: "system", Content: "Fix grammar."},
+		{Role: "user", Content: "he go"},
+		{Role: "assistant", Content: "He goes."},
+		{Role: "user", Content: "i goes"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("messages: got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("message %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLlamaCppAdapterPolishReference(t *testing.T) {
+	var got []llamaCppMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llamaCppChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		got = req.Messages
+		json.NewEncoder(w).Encode(llamaCppChatResponse{Choices: []llamaCppChoice{{Message: llamaCppMessage{Content: "ok"}}}})
+	}))
+	defer srv.Close()
+
+	a := &LlamaCppAdapter{BaseURL: srv.URL, Model: "m", Client: &http.Client{Timeout: 5 * time.Second}}
+	ctx := WithReference(context.Background(), Reference{Audience: "customers"})
+	if _, err := a.Polish(ctx, "we fixed it", "Fix grammar."); err != nil {
+		t.Fatalf("Polish: %v", err)
+	}
+
+	if len(got) != 3 || got[1].Role != "user" || got[1].Content != referenceTurn(ctx) || got[2].Content != "we fixed it" {
+		t.Errorf("messages: got %+v", got)
+	}
+}
+
+func TestLlamaCppAdapterPolishSampling(t *testing.T) {
+	var got []llamaCppChatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llamaCppChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		got = append(got, req)
+		json.NewEncoder(w).Encode(llamaCppChatResponse{Choices: []llamaCppChoice{{Message: llamaCppMessage{Content: "ok"}}}})
+	}))
+	defer srv.Close()
+
+	a := &LlamaCppAdapter{BaseURL: srv.URL, Model: "m", Client: &http.Client{Timeout: 5 * time.Second}}
+	a.Polish(context.Background(), "hi", "p")
+	a.Polish(WithSampling(context.Background(), Sampling{Temperature: 0.8, Seed: 2}), "hi", "p")
+
+	if got[0].Temperature != 0 || got[0].Seed != nil {
+		t.Errorf("default: got temperature %v seed %v, want greedy", got[0].Temperature, got[0].Seed)
+	}
+	if got[1].Temperature != 0.8 || got[1].Seed == nil || *got[1].Seed != 2 {
+		t.Errorf("sampled: got temperature %v seed %v", got[1].Temperature, got[1].Seed)
+	}
+}
+
 func TestLlamaCppAdapterPolishServerError(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "internal error", http.StatusInternalServerError)
@@ -75,7 +151,14 @@ func TestLlamaCppAdapterPolishServerError(t *testing.T) {
 
 	_, err := a.Polish(context.Background(), "hello", "prompt")
 	if err == nil {
-		t.Error("expected error on 500 response, got nil")
+		t.Fatal("expected error on 500 response, got nil")
+	}
+	var se *StatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("error: got %T, want *StatusError", err)
+	}
+	if se.StatusCode != 500 {
+		t.Errorf("status: got %d, want 500", se.StatusCode)
 	}
 }
 
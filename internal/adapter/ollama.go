@@ -26,6 +26,12 @@ type ollamaChatRequest struct {
 	Model    string          `json:"model"`
 	Messages []ollamaMessage `json:"messages"`
 	Stream   bool            `json:"stream"`
+	Options  *ollamaOptions  `json:"options,omitempty"`
+}
+
+type ollamaOptions struct {
+	Temperature float64 `json:"temperature"`
+	Seed        int     `json:"seed"`
 }
 
 type ollamaChatResponse struct {
@@ -38,12 +44,12 @@ func (o *OllamaAdapter) Name() string {
 
 func (o *OllamaAdapter) Polish(ctx context.Context, text, systemPrompt string) (string, error) {
 	reqBody := ollamaChatRequest{
-		Model: o.Model,
-		Messages: []ollamaMessage{
-			{Role: "system", Content: systemPrompt},
-			{Role: "user", Content: text},
-		},
-		Stream: false,
+		Model:    o.Model,
+		Messages: ollamaMessages(ctx, systemPrompt, text),
+		Stream:   false,
+	}
+	if s, ok := SamplingFromContext(ctx); ok {
+		reqBody.Options = &ollamaOptions{Temperature: s.Temperature, Seed: s.Seed}
 	}
 
 	body, err := json.Marshal(reqBody)
@@ -65,7 +71,7 @@ func (o *OllamaAdapter) Polish(ctx context.Context, text, systemPrompt string) (
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("ollama: unexpected status %d", resp.StatusCode)
+		return "", fmt.Errorf("ollama: %w", &StatusError{StatusCode: resp.StatusCode})
 	}
 
 	var chatResp ollamaChatResponse
@@ -92,3 +98,16 @@ func (o *OllamaAdapter) Available() bool {
 	resp.Body.Close()
 	return resp.StatusCode == http.StatusOK
 }
+
+// ollamaMessages builds the conversation: system prompt, few-shot examples
+// and reference from ctx, then the text.
+func ollamaMessages(ctx context.Context, systemPrompt, text string) []ollamaMessage {
+	msgs := []ollamaMessage{{Role: "system", Content: systemPrompt}}
+	for _, ex := range ExamplesFromContext(ctx) {
+		msgs = append(msgs, ollamaMessage{Role: "user", Content: ex.Input}, ollamaMessage{Role: "assistant", Content: ex.Output})
+	}
+	if ref := referenceTurn(ctx); ref != "" {
+		msgs = append(msgs, ollamaMessage{Role: "user", Content: ref})
+	}
+	return append(msgs, ollamaMessage{Role: "user", Content: text})
+}
@@ -3,6 +3,7 @@ package adapter
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -62,6 +63,23 @@ func TestOllamaAdapterPolish(t *testing.T) {
 	}
 }
 
+func TestOllamaAdapterPolishSampling(t *testing.T) {
+	var got ollamaChatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(ollamaChatResponse{Message: ollamaMessage{Content: "ok"}})
+	}))
+	defer srv.Close()
+
+	a := &OllamaAdapter{BaseURL: srv.URL, Model: "m", Client: &http.Client{Timeout: 5 * time.Second}}
+	if _, err := a.Polish(WithSampling(context.Background(), Sampling{Temperature: 0.8, Seed: 3}), "hi", "p"); err != nil {
+		t.Fatalf("Polish: %v", err)
+	}
+	if got.Options == nil || *got.Options != (ollamaOptions{Temperature: 0.8, Seed: 3}) {
+		t.Errorf("options: got %+v", got.Options)
+	}
+}
+
 func TestOllamaAdapterPolishServerError(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "internal error", http.StatusInternalServerError)
@@ -76,7 +94,14 @@ func TestOllamaAdapterPolishServerError(t *testing.T) {
 
 	_, err := a.Polish(context.Background(), "hello", "prompt")
 	if err == nil {
-		t.Error("expected error on 500 response, got nil")
+		t.Fatal("expected error on 500 response, got nil")
+	}
+	var se *StatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("error: got %T, want *StatusError", err)
+	}
+	if se.StatusCode != 500 {
+		t.Errorf("status: got %d, want 500", se.StatusCode)
 	}
 }
 
@@ -0,0 +1,182 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mlorentedev/pollex/internal/metrics"
+)
+
+// Load-balancing strategies for PoolAdapter.
+const (
+	LeastInFlight = "least_in_flight"
+	RoundRobin    = "round_robin"
+)
+
+// Endpoint is one backend serving a pooled model.
+type Endpoint struct {
+	URL     string // metrics label
+	Adapter LLMAdapter
+
+	inFlight     int
+	failures     int // consecutive
+	healthy      bool
+	ejectedUntil time.Time
+}
+
+// PoolAdapter spreads one model across several identical backends. Endpoints
+// marked down by the last Available probe, or ejected after EjectAfter
+// consecutive backend errors, are skipped until they recover.
+type PoolAdapter struct {
+	Model      string
+	Strategy   string // LeastInFlight (default) or RoundRobin
+	EjectAfter int    // 0 disables passive ejection
+	EjectFor   time.Duration
+
+	mu        sync.Mutex
+	endpoints []*Endpoint
+	next      int // round-robin cursor and least-in-flight tie breaker
+	now       func() time.Time
+}
+
+// NewPool returns a pool over endpoints, all initially considered healthy.
+func NewPool(model, strategy string, ejectAfter int, ejectFor time.Duration, endpoints []*Endpoint) *PoolAdapter {
+	for _, e := range endpoints {
+		e.healthy = true
+	}
+	return &PoolAdapter{
+		Model:      model,
+		Strategy:   strategy,
+		EjectAfter: ejectAfter,
+		EjectFor:   ejectFor,
+		endpoints:  endpoints,
+		now:        time.Now,
+	}
+}
+
+// Members returns the endpoint adapters, for callers that must reach every
+// backend (such as warmup).
+func (p *PoolAdapter) Members() []LLMAdapter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]LLMAdapter, len(p.endpoints))
+	for i, e := range p.endpoints {
+		out[i] = e.Adapter
+	}
+	return out
+}
+
+func (p *PoolAdapter) Name() string {
+	return fmt.Sprintf("pool (%s, %d endpoints)", p.Model, len(p.endpoints))
+}
+
+func (p *PoolAdapter) Polish(ctx context.Context, text, systemPrompt string) (string, error) {
+	e := p.acquire()
+	out, err := e.Adapter.Polish(ctx, text, systemPrompt)
+	p.release(e, err)
+	return out, err
+}
+
+// acquire picks an endpoint and counts the request as in flight. If every
+// endpoint is down or ejected it still picks one rather than fail outright.
+func (p *PoolAdapter) acquire() *Endpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	n := len(p.endpoints)
+	var best *Endpoint
+	for i := range n {
+		e := p.endpoints[(p.next+i)%n]
+		if !e.healthy || now.Before(e.ejectedUntil) {
+			continue
+		}
+		if p.Strategy == RoundRobin {
+			best = e
+			break
+		}
+		if best == nil || e.inFlight < best.inFlight {
+			best = e
+		}
+	}
+	if best == nil {
+		best = p.endpoints[p.next%n]
+	}
+	p.next = (p.next + 1) % n
+
+	best.inFlight++
+	metrics.EndpointInFlight.WithLabelValues(p.Model, best.URL).Set(float64(best.inFlight))
+	return best
+}
+
+func (p *PoolAdapter) release(e *Endpoint, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e.inFlight--
+	metrics.EndpointInFlight.WithLabelValues(p.Model, e.URL).Set(float64(e.inFlight))
+
+	result := "ok"
+	switch {
+	case err == nil:
+		e.failures = 0
+	case !backendFault(err):
+		result = "client_error"
+	default:
+		result = "error"
+		e.failures++
+		if p.EjectAfter > 0 && e.failures >= p.EjectAfter {
+			e.failures = 0
+			e.ejectedUntil = p.now().Add(p.EjectFor)
+			metrics.EndpointEjections.WithLabelValues(p.Model, e.URL).Inc()
+			slog.Warn("endpoint ejected", "model", p.Model, "endpoint", e.URL, "for", p.EjectFor, "error", err)
+		}
+	}
+	metrics.EndpointRequests.WithLabelValues(p.Model, e.URL, result).Inc()
+}
+
+// backendFault reports whether err says something about the endpoint's
+// health, as opposed to the caller going away or sending a bad request.
+func backendFault(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var se *StatusError
+	if errors.As(err, &se) && se.StatusCode >= 400 && se.StatusCode < 500 {
+		return false
+	}
+	return true
+}
+
+// Available probes every endpoint, records the result for selection and
+// metrics, and reports whether any endpoint is up.
+func (p *PoolAdapter) Available() bool {
+	p.mu.Lock()
+	endpoints := append([]*Endpoint(nil), p.endpoints...)
+	p.mu.Unlock()
+
+	up := make([]bool, len(endpoints))
+	var wg sync.WaitGroup
+	for i, e := range endpoints {
+		wg.Go(func() { up[i] = e.Adapter.Available() })
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	anyUp := false
+	for i, e := range endpoints {
+		e.healthy = up[i]
+		anyUp = anyUp || up[i]
+		v := 0.0
+		if up[i] {
+			v = 1
+		}
+		metrics.EndpointAvailable.WithLabelValues(p.Model, e.URL).Set(v)
+	}
+	return anyUp
+}
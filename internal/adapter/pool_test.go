@@ -0,0 +1,184 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mlorentedev/pollex/internal/config"
+)
+
+// fakeEndpoint answers Polish with its own name, or err if set.
+type fakeEndpoint struct {
+	name  string
+	up    bool
+	err   error
+	block chan struct{} // if non-nil, Polish waits on it
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *fakeEndpoint) Name() string { return f.name }
+func (f *fakeEndpoint) Polish(ctx context.Context, text, systemPrompt string) (string, error) {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+	if f.block != nil {
+		<-f.block
+	}
+	if f.err != nil {
+		return "", f.err
+	}
+	return f.name, nil
+}
+func (f *fakeEndpoint) Available() bool { return f.up }
+
+func (f *fakeEndpoint) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func newTestPool(strategy string, ejectAfter int, fakes ...*fakeEndpoint) *PoolAdapter {
+	var eps []*Endpoint
+	for _, f := range fakes {
+		eps = append(eps, &Endpoint{URL: f.name, Adapter: f})
+	}
+	return NewPool("m", strategy, ejectAfter, time.Minute, eps)
+}
+
+func TestPoolRoundRobin(t *testing.T) {
+	a, b := &fakeEndpoint{name: "a", up: true}, &fakeEndpoint{name: "b", up: true}
+	p := newTestPool(RoundRobin, 0, a, b)
+
+	var got []string
+	for range 4 {
+		out, err := p.Polish(context.Background(), "x", "")
+		if err != nil {
+			t.Fatalf("Polish: %v", err)
+		}
+		got = append(got, out)
+	}
+	if fmt.Sprint(got) != "[a b a b]" {
+		t.Errorf("order: got %v, want [a b a b]", got)
+	}
+}
+
+func TestPoolLeastInFlight(t *testing.T) {
+	block := make(chan struct{})
+	a := &fakeEndpoint{name: "a", up: true, block: block}
+	b := &fakeEndpoint{name: "b", up: true}
+	p := newTestPool(LeastInFlight, 0, a, b)
+
+	// Occupy a; the next two requests must both go to b.
+	done := make(chan struct{})
+	go func() {
+		p.Polish(context.Background(), "x", "")
+		close(done)
+	}()
+	for a.callCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	for range 2 {
+		if out, _ := p.Polish(context.Background(), "x", ""); out != "b" {
+			t.Errorf("got %q, want b while a is busy", out)
+		}
+	}
+	close(block)
+	<-done
+}
+
+func TestPoolSkipsUnhealthyEndpoints(t *testing.T) {
+	a, b := &fakeEndpoint{name: "a", up: false}, &fakeEndpoint{name: "b", up: true}
+	p := newTestPool(RoundRobin, 0, a, b)
+
+	if !p.Available() {
+		t.Fatal("Available: got false, want true with one endpoint up")
+	}
+	for range 3 {
+		if out, _ := p.Polish(context.Background(), "x", ""); out != "b" {
+			t.Errorf("got %q, want b", out)
+		}
+	}
+
+	b.up = false
+	if p.Available() {
+		t.Error("Available: got true, want false with every endpoint down")
+	}
+	// With nothing healthy the pool still tries rather than failing.
+	if _, err := p.Polish(context.Background(), "x", ""); err != nil {
+		t.Errorf("Polish with all down: %v", err)
+	}
+}
+
+func TestPoolEjectsAfterConsecutiveErrors(t *testing.T) {
+	bad := &fakeEndpoint{name: "bad", up: true, err: errors.New("connection reset")}
+	good := &fakeEndpoint{name: "good", up: true}
+	p := newTestPool(RoundRobin, 2, bad, good)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	for range 4 {
+		p.Polish(context.Background(), "x", "")
+	}
+	if bad.calls != 2 {
+		t.Fatalf("bad calls before ejection: got %d, want 2", bad.calls)
+	}
+
+	for range 4 {
+		if out, _ := p.Polish(context.Background(), "x", ""); out != "good" {
+			t.Errorf("got %q, want good while bad is ejected", out)
+		}
+	}
+
+	now = now.Add(time.Minute)
+	p.Polish(context.Background(), "x", "")
+	p.Polish(context.Background(), "x", "")
+	if bad.calls != 3 {
+		t.Errorf("bad calls after ejection expired: got %d, want 3", bad.calls)
+	}
+}
+
+func TestPoolClientErrorsDoNotEject(t *testing.T) {
+	for _, err := range []error{
+		context.Canceled,
+		fmt.Errorf("llamacpp: %w", &StatusError{StatusCode: 400}),
+	} {
+		e := &fakeEndpoint{name: "a", up: true, err: err}
+		p := newTestPool(RoundRobin, 1, e, &fakeEndpoint{name: "b", up: true})
+		p.Polish(context.Background(), "x", "")
+		p.Polish(context.Background(), "x", "")
+		p.Polish(context.Background(), "x", "")
+		if e.calls != 2 {
+			t.Errorf("%v: calls got %d, want 2 (no ejection)", err, e.calls)
+		}
+	}
+}
+
+func TestBuildPoolsLlamaCppEndpoints(t *testing.T) {
+	cfg := config.Config{
+		LlamaCppURL:   "http://a:8080",
+		LlamaCppURLs:  []string{"http://b:8080"},
+		LlamaCppModel: "local",
+		LoadBalance:   RoundRobin,
+	}
+	adapters, models, err := Build(cfg, false)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	p, ok := adapters["local"].(*PoolAdapter)
+	if !ok {
+		t.Fatalf("local: got %T, want *PoolAdapter", adapters["local"])
+	}
+	if len(p.endpoints) != 2 || p.Strategy != RoundRobin {
+		t.Errorf("pool: got %d endpoints, strategy %q", len(p.endpoints), p.Strategy)
+	}
+	if len(models) != 1 {
+		t.Errorf("models: got %d, want 1 (one model ID for the pool)", len(models))
+	}
+}
@@ -0,0 +1,71 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/mlorentedev/pollex/internal/redact"
+)
+
+// RedactingAdapter strips PII from text, and from any Reference in the
+// context, before it reaches a remote backend and restores it in the result.
+// Only counts are logged, never values.
+type RedactingAdapter struct {
+	Inner    LLMAdapter
+	Redactor *redact.Redactor
+	Model    string // for logs
+}
+
+func (r *RedactingAdapter) Name() string { return r.Inner.Name() }
+
+func (r *RedactingAdapter) Polish(ctx context.Context, text, systemPrompt string) (string, error) {
+	redacted, m, err := r.Redactor.Redact(text)
+	if err == nil {
+		ctx, err = r.redactReference(ctx, m)
+	}
+	if err != nil {
+		slog.Warn("remote polish blocked", "model", r.Model, "reason", "confidential marker")
+		return "", fmt.Errorf("redact: %w", err)
+	}
+	if m.Len() > 0 {
+		attrs := []any{"model", r.Model}
+		for kind, n := range m.Counts() {
+			attrs = append(attrs, kind, n)
+		}
+		slog.Info("pii redacted", attrs...)
+	}
+
+	out, err := r.Inner.Polish(ctx, redacted, systemPrompt)
+	if err != nil {
+		return "", err
+	}
+
+	restored, lost := m.Restore(out)
+	if lost > 0 {
+		slog.Warn("pii placeholders lost in output", "model", r.Model, "lost", lost)
+	}
+	return restored, nil
+}
+
+// redactReference replaces the Reference in ctx, if any, with a redacted
+// copy that shares m's placeholders.
+func (r *RedactingAdapter) redactReference(ctx context.Context, m *redact.Mapping) (context.Context, error) {
+	ref, ok := ReferenceFromContext(ctx)
+	if !ok {
+		return ctx, nil
+	}
+	for _, f := range []*string{&ref.PreviousMessage, &ref.Audience, &ref.Purpose, &ref.Before, &ref.After} {
+		v, err := r.Redactor.Extend(m, *f)
+		if err != nil {
+			return ctx, err
+		}
+		*f = v
+	}
+	return WithReference(ctx, ref), nil
+}
+
+func (r *RedactingAdapter) Available() bool { return r.Inner.Available() }
+
+// Unwrap returns the wrapped adapter.
+func (r *RedactingAdapter) Unwrap() LLMAdapter { return r.Inner }
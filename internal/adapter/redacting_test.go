@@ -0,0 +1,143 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mlorentedev/pollex/internal/config"
+	"github.com/mlorentedev/pollex/internal/redact"
+)
+
+// recordingAdapter echoes its input and remembers what it was sent.
+type recordingAdapter struct {
+	got string
+}
+
+func (r *recordingAdapter) Name() string { return "recording" }
+func (r *recordingAdapter) Polish(ctx context.Context, text, systemPrompt string) (string, error) {
+	r.got = text
+	return "Polished: " + text, nil
+}
+func (r *recordingAdapter) Available() bool { return true }
+
+func TestRedactingAdapterPolish(t *testing.T) {
+	inner := &recordingAdapter{}
+	a := &RedactingAdapter{
+		Inner:    inner,
+		Redactor: redact.New(redact.Options{Terms: []string{"Acme"}}),
+		Model:    "remote",
+	}
+
+	out, err := a.Polish(context.Background(), "email bob@acme.io about Acme", "prompt")
+	if err != nil {
+		t.Fatalf("Polish: %v", err)
+	}
+	if strings.Contains(inner.got, "bob@acme.io") || strings.Contains(inner.got, "Acme") {
+		t.Errorf("backend received PII: %q", inner.got)
+	}
+	if want := "Polished: email bob@acme.io about Acme"; out != want {
+		t.Errorf("output: got %q, want %q", out, want)
+	}
+}
+
+func TestRedactingAdapterBlocksConfidential(t *testing.T) {
+	inner := &recordingAdapter{}
+	a := &RedactingAdapter{
+		Inner:    inner,
+		Redactor: redact.New(redact.Options{Markers: []string{"CONFIDENTIAL"}}),
+	}
+
+	_, err := a.Polish(context.Background(), "CONFIDENTIAL draft", "prompt")
+	if !errors.Is(err, redact.ErrConfidential) {
+		t.Fatalf("err: got %v, want ErrConfidential", err)
+	}
+	if inner.got != "" {
+		t.Errorf("backend was called with %q", inner.got)
+	}
+}
+
+func TestBuildWrapsRemoteAdapters(t *testing.T) {
+	cfg := config.Config{
+		ClaudeAPIKey:  "sk-test",
+		ClaudeModel:   "claude-test",
+		LlamaCppURL:   "http://localhost:8080",
+		LlamaCppModel: "local",
+		RedactRemote:  true,
+	}
+
+	adapters, _, err := Build(cfg, false)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if _, ok := adapters["claude-test"].(*RedactingAdapter); !ok {
+		t.Errorf("claude: got %T, want *RedactingAdapter", adapters["claude-test"])
+	}
+	if _, ok := Base(adapters["claude-test"]).(*ClaudeAdapter); !ok {
+		t.Errorf("claude base: got %T, want *ClaudeAdapter", Base(adapters["claude-test"]))
+	}
+	if _, ok := adapters["local"].(*LlamaCppAdapter); !ok {
+		t.Errorf("local: got %T, want *LlamaCppAdapter", adapters["local"])
+	}
+
+	cfg.RemoteModels = []string{"local"}
+	if adapters, _, err = Build(cfg, false); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if _, ok := adapters["local"].(*RedactingAdapter); !ok {
+		t.Errorf("local marked remote: got %T, want *RedactingAdapter", adapters["local"])
+	}
+
+	cfg.RedactRemote = false
+	if adapters, _, err = Build(cfg, false); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if _, ok := adapters["claude-test"].(*ClaudeAdapter); !ok {
+		t.Errorf("redaction disabled: got %T, want *ClaudeAdapter", adapters["claude-test"])
+	}
+}
+
+// refRecordingAdapter remembers the reference it was sent.
+type refRecordingAdapter struct {
+	recordingAdapter
+	ref Reference
+}
+
+func (r *refRecordingAdapter) Polish(ctx context.Context, text, systemPrompt string) (string, error) {
+	r.ref, _ = ReferenceFromContext(ctx)
+	return r.recordingAdapter.Polish(ctx, text, systemPrompt)
+}
+
+func TestRedactingAdapterReference(t *testing.T) {
+	inner := &refRecordingAdapter{}
+	a := &RedactingAdapter{
+		Inner:    inner,
+		Redactor: redact.New(redact.Options{Terms: []string{"Acme"}, Markers: []string{"CONFIDENTIAL"}}),
+	}
+
+	ctx := WithReference(context.Background(), Reference{
+		PreviousMessage: "Can Acme ship by Friday? Call bob@acme.io",
+		Audience:        "Acme buyers",
+	})
+	out, err := a.Polish(ctx, "Acme ships Friday.", "prompt")
+	if err != nil {
+		t.Fatalf("Polish: %v", err)
+	}
+	want := Reference{PreviousMessage: "Can [TERM_1] ship by Friday? Call [EMAIL_1]", Audience: "[TERM_1] buyers"}
+	if inner.ref != want {
+		t.Errorf("reference: got %+v, want %+v", inner.ref, want)
+	}
+	if out != "Polished: Acme ships Friday." {
+		t.Errorf("output: got %q", out)
+	}
+
+	inner.got = ""
+	ctx = WithReference(context.Background(), Reference{PreviousMessage: "CONFIDENTIAL numbers"})
+	if _, err := a.Polish(ctx, "Thanks.", "prompt"); !errors.Is(err, redact.ErrConfidential) {
+		t.Fatalf("err: got %v, want ErrConfidential", err)
+	}
+	if inner.got != "" {
+		t.Errorf("backend was called with %q", inner.got)
+	}
+}
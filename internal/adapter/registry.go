@@ -0,0 +1,132 @@
+package adapter
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mlorentedev/pollex/internal/config"
+	"github.com/mlorentedev/pollex/internal/redact"
+	"github.com/mlorentedev/pollex/internal/rules"
+)
+
+// RulesModel is the model ID of the offline RulesAdapter.
+const RulesModel = "rules"
+
+// Build constructs the adapters enabled in cfg, keyed by model ID, and the
+// matching model list in priority order. With useMock only the mock adapter
+// is registered. It fails only if the rules file can't be loaded.
+func Build(cfg config.Config, useMock bool) (map[string]LLMAdapter, []ModelInfo, error) {
+	adapters := make(map[string]LLMAdapter)
+	var models []ModelInfo
+
+	if useMock {
+		adapters["mock"] = &MockAdapter{Delay: 500 * time.Millisecond}
+		models = append(models, ModelInfo{ID: "mock", Name: "Mock (dev)", Provider: "mock"})
+		slog.Info("adapter registered", "adapter", "mock")
+		return adapters, models, nil
+	}
+
+	// 1. llama.cpp (Highest priority for local GPU)
+	if urls := cfg.LlamaCppEndpoints(); len(urls) > 0 {
+		model := cfg.LlamaCppModel
+		if model == "" {
+			model = "qwen2.5-1.5b-gpu"
+		}
+		var endpoints []*Endpoint
+		for _, u := range urls {
+			endpoints = append(endpoints, &Endpoint{URL: u, Adapter: &LlamaCppAdapter{
+				BaseURL: u,
+				Model:   model,
+				Client:  &http.Client{Timeout: 120 * time.Second},
+			}})
+		}
+		if len(endpoints) == 1 {
+			adapters[model] = endpoints[0].Adapter
+		} else {
+			adapters[model] = NewPool(model, cfg.LoadBalance, cfg.EjectAfter, cfg.EjectFor, endpoints)
+		}
+		models = append(models, ModelInfo{ID: model, Name: "llama.cpp (" + model + ")", Provider: "llamacpp"})
+		slog.Info("adapter registered", "adapter", "llamacpp", "urls", urls, "model", model, "load_balance", cfg.LoadBalance)
+	}
+
+	// 2. Claude (Optional cloud fallback)
+	if cfg.ClaudeAPIKey != "" {
+		claude := &ClaudeAdapter{
+			APIKey: cfg.ClaudeAPIKey,
+			Model:  cfg.ClaudeModel,
+			Client: &http.Client{Timeout: 60 * time.Second},
+		}
+		adapters[cfg.ClaudeModel] = claude
+		models = append(models, ModelInfo{ID: cfg.ClaudeModel, Name: "Claude (" + cfg.ClaudeModel + ")", Provider: "claude"})
+		slog.Info("adapter registered", "adapter", "claude", "model", cfg.ClaudeModel)
+	}
+
+	// 3. Ollama (Optional or legacy fallback)
+	if cfg.OllamaURL != "" {
+		model := "qwen2.5:1.5b"
+		ollama := &OllamaAdapter{
+			BaseURL: cfg.OllamaURL,
+			Model:   model,
+			Client:  &http.Client{Timeout: 60 * time.Second},
+		}
+		adapters[model] = ollama
+		models = append(models, ModelInfo{ID: model, Name: "Qwen 2.5 1.5B", Provider: "ollama"})
+		slog.Info("adapter registered", "adapter", "ollama", "url", cfg.OllamaURL)
+	}
+
+	// 4. Rules (Offline last resort, always available)
+	if cfg.RulesAdapter {
+		set, err := rules.Load(cfg.RulesPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		adapters[RulesModel] = &RulesAdapter{Rules: set}
+		models = append(models, ModelInfo{ID: RulesModel, Name: "Rules (offline)", Provider: "rules"})
+		slog.Info("adapter registered", "adapter", "rules", "path", cfg.RulesPath)
+	}
+
+	wrapRemote(cfg, adapters)
+
+	return adapters, models, nil
+}
+
+// BuildShadow returns the candidate adapter for shadow traffic, or nil when
+// shadow_url is not set. It is kept out of Build's map so clients can't
+// select it.
+func BuildShadow(cfg config.Config) LLMAdapter {
+	if cfg.ShadowURL == "" {
+		return nil
+	}
+	return &LlamaCppAdapter{
+		BaseURL: cfg.ShadowURL,
+		Model:   cfg.ShadowModel,
+		Client:  &http.Client{Timeout: 120 * time.Second},
+	}
+}
+
+// wrapRemote puts a RedactingAdapter in front of every remote backend.
+func wrapRemote(cfg config.Config, adapters map[string]LLMAdapter) {
+	if !cfg.RedactRemote {
+		return
+	}
+
+	for _, id := range cfg.RemoteModels {
+		if _, ok := adapters[id]; !ok {
+			slog.Warn("remote model not registered", "model", id)
+		}
+	}
+
+	r := redact.New(redact.Options{
+		Terms:           cfg.RedactTerms,
+		InternalDomains: cfg.RedactDomains,
+		Markers:         cfg.ConfidentialMarkers,
+	})
+	for id, a := range adapters {
+		if !cfg.IsRemote(id) {
+			continue
+		}
+		adapters[id] = &RedactingAdapter{Inner: a, Redactor: r, Model: id}
+		slog.Info("redaction enabled", "model", id, "terms", len(cfg.RedactTerms), "domains", len(cfg.RedactDomains))
+	}
+}
@@ -0,0 +1,32 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mlorentedev/pollex/internal/lang"
+	"github.com/mlorentedev/pollex/internal/rules"
+)
+
+// RulesAdapter corrects text with deterministic rules instead of a model.
+// It needs no network and is always available, so it serves as the last
+// fallback and as a baseline in benchmarks. The system prompt is ignored.
+// The rules are English-only, so text not detected as English is returned
+// unchanged.
+type RulesAdapter struct {
+	Rules *rules.Set
+}
+
+func (r *RulesAdapter) Name() string { return "Rules" }
+
+func (r *RulesAdapter) Polish(ctx context.Context, text, systemPrompt string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("rules: %w", err)
+	}
+	if lang.Detect(text) != lang.English {
+		return text, nil
+	}
+	return r.Rules.Apply(text), nil
+}
+
+func (r *RulesAdapter) Available() bool { return true }
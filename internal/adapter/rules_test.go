@@ -0,0 +1,60 @@
+package adapter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mlorentedev/pollex/internal/config"
+	"github.com/mlorentedev/pollex/internal/rules"
+)
+
+func TestRulesAdapterPolish(t *testing.T) {
+	r := &RulesAdapter{Rules: rules.Default()}
+	got, err := r.Polish(context.Background(), "yesterday i didn't saw the the email", "ignored")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "Yesterday I didn't see the email"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Rules are English-only; other text passes through untouched.
+	for _, in := range []string{"Voy a ir a la oficina mañana por la tarde.", "ok  ."} {
+		if got, _ := r.Polish(context.Background(), in, ""); got != in {
+			t.Errorf("Polish(%q): got %q, want unchanged", in, got)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.Polish(ctx, "hello", ""); err == nil {
+		t.Error("expected error on cancelled context, got nil")
+	}
+}
+
+func TestBuildRegistersRulesLast(t *testing.T) {
+	cfg := config.Config{OllamaURL: "http://ollama:11434", RulesAdapter: true}
+	adapters, models, err := Build(cfg, false)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if len(models) != 2 || models[1].ID != RulesModel {
+		t.Fatalf("models: got %+v, want rules last", models)
+	}
+	if _, ok := adapters[RulesModel].(*RulesAdapter); !ok {
+		t.Errorf("rules: got %T", adapters[RulesModel])
+	}
+
+	if _, models, _ := Build(cfg, true); len(models) != 1 || models[0].ID != "mock" {
+		t.Errorf("mock mode: got %+v", models)
+	}
+
+	bad := filepath.Join(t.TempDir(), "rules.yaml")
+	os.WriteFile(bad, []byte("- pattern: \"(\"\n"), 0600)
+	cfg.RulesPath = bad
+	if _, _, err := Build(cfg, false); err == nil {
+		t.Error("expected error for invalid rules file")
+	}
+}
@@ -0,0 +1,76 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Code is a stable, machine-readable error identifier. Clients should
+// branch on Code, never on Message.
+type Code string
+
+const (
+	CodeMethodNotAllowed    Code = "method_not_allowed"
+	CodeInvalidJSON         Code = "invalid_json"
+	CodeBodyTooLarge        Code = "body_too_large"
+	CodeTextRequired        Code = "text_required"
+	CodeTextTooLong         Code = "text_too_long"
+	CodeContextTooLong      Code = "context_too_long"
+	CodeInvalidOption       Code = "invalid_option"
+	CodeModelRequired       Code = "model_required"
+	CodeUnknownModel        Code = "unknown_model"
+	CodeConfidential        Code = "confidential"
+	CodePromptInjection     Code = "prompt_injection"
+	CodeModelForbidden      Code = "model_forbidden"
+	CodeInvalidQuery        Code = "invalid_query"
+	CodeInvalidFeedback     Code = "invalid_feedback"
+	CodeNotFound            Code = "not_found"
+	CodeForbidden           Code = "forbidden"
+	CodeExperimentConflict  Code = "experiment_conflict"
+	CodeUnauthorized        Code = "unauthorized"
+	CodeRateLimited         Code = "rate_limited"
+	CodeUpstreamTimeout     Code = "upstream_timeout"
+	CodeUpstreamUnavailable Code = "upstream_unavailable"
+	CodeUpstreamRejected    Code = "upstream_rejected"
+	CodeUpstreamError       Code = "upstream_error"
+	CodeInternal            Code = "internal"
+)
+
+// Error is the JSON body of every non-2xx API response.
+// Legacy mirrors Message under the old "error" key so that clients built
+// against the free-form schema keep working.
+type Error struct {
+	Code      Code           `json:"code"`
+	Message   string         `json:"message"`
+	RequestID string         `json:"request_id,omitempty"`
+	Retryable bool           `json:"retryable"`
+	Details   map[string]any `json:"details,omitempty"`
+	Legacy    string         `json:"error"`
+}
+
+// New builds an Error with Retryable derived from the code.
+func New(code Code, msg string) Error {
+	return Error{
+		Code:      code,
+		Message:   msg,
+		Retryable: Retryable(code),
+		Legacy:    msg,
+	}
+}
+
+// Retryable reports whether a client may retry the same request unchanged.
+func Retryable(code Code) bool {
+	switch code {
+	case CodeRateLimited, CodeUpstreamTimeout, CodeUpstreamUnavailable, CodeUpstreamError:
+		return true
+	default:
+		return false
+	}
+}
+
+// Write encodes e as the response body with the given status code.
+func Write(w http.ResponseWriter, status int, e Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(e)
+}
@@ -0,0 +1,66 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewSetsRetryable(t *testing.T) {
+	tests := []struct {
+		code Code
+		want bool
+	}{
+		{CodeRateLimited, true},
+		{CodeUpstreamTimeout, true},
+		{CodeUpstreamUnavailable, true},
+		{CodeUpstreamError, true},
+		{CodeUpstreamRejected, false},
+		{CodeTextTooLong, false},
+		{CodeUnauthorized, false},
+		{CodeUnknownModel, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.code), func(t *testing.T) {
+			if got := New(tt.code, "msg").Retryable; got != tt.want {
+				t.Errorf("retryable: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	w := httptest.NewRecorder()
+	e := New(CodeUnknownModel, "unknown model: x")
+	e.RequestID = "abc123"
+	Write(w, http.StatusBadRequest, e)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status: got %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("content-type: got %q, want application/json", got)
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body["code"] != "unknown_model" {
+		t.Errorf("code: got %v, want unknown_model", body["code"])
+	}
+	if body["message"] != "unknown model: x" {
+		t.Errorf("message: got %v, want %q", body["message"], "unknown model: x")
+	}
+	if body["error"] != "unknown model: x" {
+		t.Errorf("error (legacy): got %v, want %q", body["error"], "unknown model: x")
+	}
+	if body["request_id"] != "abc123" {
+		t.Errorf("request_id: got %v, want abc123", body["request_id"])
+	}
+	if _, ok := body["details"]; ok {
+		t.Error("details: should be omitted when empty")
+	}
+}
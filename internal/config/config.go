@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -17,13 +19,272 @@ type Config struct {
 	LlamaCppModel string `yaml:"llamacpp_model"`
 	PromptPath    string `yaml:"prompt_path"`
 	APIKey        string `yaml:"api_key"`
+
+	// PromptPath is a text/template; PromptVars are its defaults. Requests
+	// may override tone and audience.
+	PromptVars PromptVars `yaml:"prompt_vars"`
+
+	// GlossaryPath is a YAML list of terms to keep verbatim (or normalise to
+	// a canonical spelling); KeyGlossaries adds terms per API key name.
+	GlossaryPath  string            `yaml:"glossary_path"`
+	KeyGlossaries map[string]string `yaml:"key_glossaries"`
+
+	// LintBannedWords are added to the linter's built-in AI-isms; sentences
+	// longer than LintMaxSentenceWords get a hint (0 uses the default).
+	LintBannedWords      []string `yaml:"lint_banned_words"`
+	LintMaxSentenceWords int      `yaml:"lint_max_sentence_words"`
+
+	// Alternatives configures polish requests with n > 1; see Alternatives.
+	Alternatives Alternatives `yaml:"alternatives"`
+
+	// RulesAdapter registers the offline "rules" model, tried last when every
+	// other backend is down. RulesPath replaces its built-in rules.
+	RulesAdapter bool   `yaml:"rules_adapter"`
+	RulesPath    string `yaml:"rules_path"`
+
+	// LanguagePrompts maps a language code (en, es) to the prompt used when
+	// the output is in that language; ModelPrompts still take precedence.
+	LanguagePrompts map[string]string `yaml:"language_prompts"`
+
+	// SegmentCacheSize bounds the sentence cache behind incremental polish
+	// requests, in sentences; 0 disables incremental polishing.
+	SegmentCacheSize int `yaml:"segment_cache_size"`
+
+	// Injection configures prompt injection checks; see Injection.
+	Injection Injection `yaml:"injection"`
+
+	// ModelPrompts replaces the prompt and/or adds few-shot examples for
+	// specific model IDs.
+	ModelPrompts map[string]ModelPrompt `yaml:"model_prompts"`
+
+	// LlamaCppURLs adds llama-server endpoints serving the same model as
+	// LlamaCppURL; with more than one endpoint requests are load balanced.
+	LlamaCppURLs []string      `yaml:"llamacpp_urls"`
+	LoadBalance  string        `yaml:"load_balance"` // least_in_flight or round_robin
+	EjectAfter   int           `yaml:"eject_after"`  // consecutive errors; 0 disables
+	EjectFor     time.Duration `yaml:"eject_for"`
+
+	// LlamaServerBin, when set, makes Pollex launch llama-server itself
+	// (with LlamaServerArgs) and restart it on crash or hang. Checks go to
+	// LlamaCppURL, so the args must make it listen there.
+	LlamaServerBin        string        `yaml:"llamaserver_bin"`
+	LlamaServerArgs       []string      `yaml:"llamaserver_args"`
+	SuperviseInterval     time.Duration `yaml:"supervise_interval"`
+	SuperviseHangTimeout  time.Duration `yaml:"supervise_hang_timeout"`
+	SuperviseFailures     int           `yaml:"supervise_failures"`
+	SuperviseStartupGrace time.Duration `yaml:"supervise_startup_grace"`
+
+	// Warmup sends WarmupText through each local model at startup and
+	// whenever it comes back up; /api/ready reports 503 until it succeeds.
+	// KeepWarmInterval, if set, repeats the warmup after that much idle time.
+	Warmup           bool          `yaml:"warmup"`
+	WarmupText       string        `yaml:"warmup_text"`
+	KeepWarmInterval time.Duration `yaml:"keep_warm_interval"`
+
+	// ShadowURL points at a llama-server running a candidate model. When set,
+	// ShadowPercent of successful polishes are replayed against it in the
+	// background and both outputs are appended to ShadowPath for review.
+	ShadowURL         string  `yaml:"shadow_url"`
+	ShadowModel       string  `yaml:"shadow_model"` // label in records and metrics
+	ShadowPercent     float64 `yaml:"shadow_percent"`
+	ShadowPath        string  `yaml:"shadow_path"`
+	ShadowConcurrency int     `yaml:"shadow_concurrency"`
+
+	// APIKeys maps a key name to its secret. Names identify callers in the
+	// history store; api_key, if set, is added under the name "default".
+	APIKeys map[string]string `yaml:"api_keys"`
+
+	// RequestTimeout is the per-request deadline propagated to adapters.
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+
+	// HistoryPath enables the server-side history store (JSONL) when set.
+	HistoryPath       string        `yaml:"history_path"`
+	HistoryMaxEntries int           `yaml:"history_max_entries"`
+	HistoryMaxAge     time.Duration `yaml:"history_max_age"`
+
+	// RemoteModels lists model IDs whose backend is outside the network, in
+	// addition to Claude, which is always remote. Text sent to them is
+	// redacted unless RedactRemote is false.
+	RemoteModels        []string `yaml:"remote_models"`
+	RedactRemote        bool     `yaml:"redact_remote"`
+	RedactTerms         []string `yaml:"redact_terms"`
+	RedactDomains       []string `yaml:"redact_domains"`
+	ConfidentialMarkers []string `yaml:"confidential_markers"`
+
+	// Routes choose a model when a request omits model_id (or sends "auto").
+	// Rules are tried in order; see RouteRule.
+	Routes []RouteRule `yaml:"routes"`
+
+	// Experiments split callers between prompt and/or model variants; see
+	// Experiment. At most one may be running at a time.
+	Experiments []Experiment `yaml:"experiments"`
+
+	// AdminKeys names API keys allowed to use admin endpoints. With auth
+	// disabled every caller is an admin.
+	AdminKeys []string `yaml:"admin_keys"`
+
+	// LocalOnlyKeys names API keys whose text must never reach a remote model,
+	// whether routed or requested explicitly.
+	LocalOnlyKeys []string `yaml:"local_only_keys"`
+}
+
+// RouteRule matches when every condition it sets holds. Zero-valued
+// conditions match anything. Models are candidates in preference order;
+// the first available one is used.
+type RouteRule struct {
+	Name     string   `yaml:"name"`
+	Models   []string `yaml:"models"`
+	MinChars int      `yaml:"min_chars"`
+	MaxChars int      `yaml:"max_chars"`
+	Modes    []string `yaml:"modes"`
+	Keys     []string `yaml:"keys"`
+	Tiers    []string `yaml:"tiers"`
+	Hours    string   `yaml:"hours"` // "HH:MM-HH:MM" server local time, may wrap midnight
+}
+
+// PromptVars are the variables available to prompt templates. Tones and
+// Audiences are the values a request may choose; the defaults above are
+// not checked against them.
+type PromptVars struct {
+	Tone     string `yaml:"tone"`
+	Audience string `yaml:"audience"`
+	Language string `yaml:"language"`
+
+	Tones     []string `yaml:"tones"`
+	Audiences []string `yaml:"audiences"`
+}
+
+// Alternatives bounds and ranks multi-candidate polishes. Candidates after
+// the first are sampled at Temperature with distinct seeds. Zero values use
+// the rank package's defaults.
+type Alternatives struct {
+	Max         int          `yaml:"max"`
+	Temperature float64      `yaml:"temperature"`
+	Weights     ScoreWeights `yaml:"weights"`
+}
+
+// ScoreWeights weighs the components of a candidate's score. All zero
+// means equal weights.
+type ScoreWeights struct {
+	Similarity  float64 `yaml:"similarity"`   // word similarity to the input
+	LengthRatio float64 `yaml:"length_ratio"` // closeness of output to input length
+	Lint        float64 `yaml:"lint"`         // few style lint findings
+}
+
+// Injection flags text that tries to instruct the model, and outputs that
+// leak the system prompt. Action decides what happens to such requests:
+// flag (serve and report), sanitize (remove the offending parts), reject,
+// or off. Patterns are case-insensitive regular expressions added to the
+// built-in ones.
+type Injection struct {
+	Action   string   `yaml:"action"`
+	Patterns []string `yaml:"patterns"`
+}
+
+// ModelPrompt overrides prompting for one model. ExamplesPath is a YAML
+// list of {input, output} pairs sent as few-shot turns.
+type ModelPrompt struct {
+	PromptPath   string `yaml:"prompt_path"`
+	ExamplesPath string `yaml:"examples_path"`
+}
+
+// Experiment assigns each API key to one of its variants, sticky for the
+// experiment's lifetime, in proportion to the variants' weights. Running
+// sets the state at startup; admins can start and stop it later.
+type Experiment struct {
+	Name     string              `yaml:"name"`
+	Running  bool                `yaml:"running"`
+	Variants []ExperimentVariant `yaml:"variants"`
+}
+
+// ExperimentVariant overrides the system prompt and/or the model. A variant
+// with neither is a control group.
+type ExperimentVariant struct {
+	Name       string `yaml:"name"`
+	Weight     int    `yaml:"weight"` // relative; 0 means 1
+	PromptPath string `yaml:"prompt_path"`
+	Model      string `yaml:"model"` // used only when the request leaves model_id to the router
+}
+
+// LlamaCppEndpoints returns LlamaCppURL followed by LlamaCppURLs, without
+// duplicates.
+func (c Config) LlamaCppEndpoints() []string {
+	var urls []string
+	seen := map[string]bool{}
+	for _, u := range append([]string{c.LlamaCppURL}, c.LlamaCppURLs...) {
+		if u != "" && !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// IsRemote reports whether model runs outside the network: Claude, or any
+// model listed in remote_models.
+func (c Config) IsRemote(model string) bool {
+	if c.ClaudeAPIKey != "" && model == c.ClaudeModel {
+		return true
+	}
+	for _, id := range c.RemoteModels {
+		if id == model {
+			return true
+		}
+	}
+	return false
+}
+
+// Keys returns every configured API key by name, including the legacy
+// single api_key under "default". An empty map means auth is disabled.
+func (c Config) Keys() map[string]string {
+	keys := make(map[string]string, len(c.APIKeys)+1)
+	for name, key := range c.APIKeys {
+		keys[name] = key
+	}
+	if c.APIKey != "" {
+		keys["default"] = c.APIKey
+	}
+	return keys
 }
 
 func defaults() Config {
 	return Config{
-		Port:        8090,
-		ClaudeModel: "claude-sonnet-4-5-20250929",
-		PromptPath:  "prompts/polish.txt",
+		Port:           8090,
+		ClaudeModel:    "claude-sonnet-4-5-20250929",
+		PromptPath:     "prompts/polish.txt",
+		RequestTimeout: 120 * time.Second,
+
+		PromptVars: PromptVars{
+			Tones:     []string{"neutral", "formal", "friendly", "casual", "confident", "diplomatic"},
+			Audiences: []string{"general", "technical", "executives", "customers", "colleagues"},
+		},
+
+		LoadBalance: "least_in_flight",
+		EjectAfter:  3,
+		EjectFor:    30 * time.Second,
+
+		SuperviseInterval:     10 * time.Second,
+		SuperviseHangTimeout:  30 * time.Second,
+		SuperviseFailures:     3,
+		SuperviseStartupGrace: 2 * time.Minute,
+
+		Warmup:     true,
+		WarmupText: "Hello.",
+
+		RulesAdapter: true,
+
+		Injection: Injection{Action: "flag"},
+
+		SegmentCacheSize: 5000,
+
+		ShadowModel:       "shadow",
+		ShadowPercent:     10,
+		ShadowConcurrency: 1,
+
+		HistoryMaxEntries: 10000,
+		HistoryMaxAge:     30 * 24 * time.Hour,
+
+		RedactRemote: true,
 	}
 }
 
@@ -60,15 +321,147 @@ func Load(path string) (Config, error) {
 	if v := os.Getenv("POLLEX_LLAMACPP_URL"); v != "" {
 		cfg.LlamaCppURL = v
 	}
+	if v := os.Getenv("POLLEX_LLAMACPP_URLS"); v != "" {
+		cfg.LlamaCppURLs = splitList(v)
+	}
+	if v := os.Getenv("POLLEX_LLAMASERVER_BIN"); v != "" {
+		cfg.LlamaServerBin = v
+	}
 	if v := os.Getenv("POLLEX_LLAMACPP_MODEL"); v != "" {
 		cfg.LlamaCppModel = v
 	}
 	if v := os.Getenv("POLLEX_PROMPT_PATH"); v != "" {
 		cfg.PromptPath = v
 	}
+	if v := os.Getenv("POLLEX_GLOSSARY_PATH"); v != "" {
+		cfg.GlossaryPath = v
+	}
+	if v := os.Getenv("POLLEX_RULES_ADAPTER"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("config: invalid POLLEX_RULES_ADAPTER %q: %w", v, err)
+		}
+		cfg.RulesAdapter = b
+	}
+	if v := os.Getenv("POLLEX_RULES_PATH"); v != "" {
+		cfg.RulesPath = v
+	}
+	if v := os.Getenv("POLLEX_INJECTION_ACTION"); v != "" {
+		cfg.Injection.Action = v
+	}
+	if v := os.Getenv("POLLEX_SEGMENT_CACHE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("config: invalid POLLEX_SEGMENT_CACHE_SIZE %q: %w", v, err)
+		}
+		cfg.SegmentCacheSize = n
+	}
 	if v := os.Getenv("POLLEX_API_KEY"); v != "" {
 		cfg.APIKey = v
 	}
+	if v := os.Getenv("POLLEX_REQUEST_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("config: invalid POLLEX_REQUEST_TIMEOUT %q: %w", v, err)
+		}
+		cfg.RequestTimeout = d
+	}
+	if v := os.Getenv("POLLEX_API_KEYS"); v != "" {
+		keys, err := parseKeyList(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("config: invalid POLLEX_API_KEYS: %w", err)
+		}
+		cfg.APIKeys = keys
+	}
+	if v := os.Getenv("POLLEX_SHADOW_URL"); v != "" {
+		cfg.ShadowURL = v
+	}
+	if v := os.Getenv("POLLEX_SHADOW_PERCENT"); v != "" {
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("config: invalid POLLEX_SHADOW_PERCENT %q: %w", v, err)
+		}
+		cfg.ShadowPercent = p
+	}
+	if v := os.Getenv("POLLEX_WARMUP"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("config: invalid POLLEX_WARMUP %q: %w", v, err)
+		}
+		cfg.Warmup = b
+	}
+	if v := os.Getenv("POLLEX_KEEP_WARM_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("config: invalid POLLEX_KEEP_WARM_INTERVAL %q: %w", v, err)
+		}
+		cfg.KeepWarmInterval = d
+	}
+	if v := os.Getenv("POLLEX_HISTORY_PATH"); v != "" {
+		cfg.HistoryPath = v
+	}
+	if v := os.Getenv("POLLEX_REMOTE_MODELS"); v != "" {
+		cfg.RemoteModels = splitList(v)
+	}
+	if v := os.Getenv("POLLEX_REDACT_REMOTE"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("config: invalid POLLEX_REDACT_REMOTE %q: %w", v, err)
+		}
+		cfg.RedactRemote = b
+	}
+	if v := os.Getenv("POLLEX_REDACT_TERMS"); v != "" {
+		cfg.RedactTerms = splitList(v)
+	}
+
+	if cfg.RequestTimeout <= 0 {
+		return Config{}, fmt.Errorf("config: request_timeout must be positive, got %v", cfg.RequestTimeout)
+	}
+
+	if cfg.LoadBalance != "least_in_flight" && cfg.LoadBalance != "round_robin" {
+		return Config{}, fmt.Errorf("config: invalid load_balance %q (want least_in_flight or round_robin)", cfg.LoadBalance)
+	}
+
+	switch cfg.Injection.Action {
+	case "flag", "sanitize", "reject", "off":
+	default:
+		return Config{}, fmt.Errorf("config: invalid injection.action %q (want flag, sanitize, reject or off)", cfg.Injection.Action)
+	}
+
+	if cfg.LlamaServerBin != "" && cfg.LlamaCppURL == "" {
+		return Config{}, fmt.Errorf("config: llamaserver_bin requires llamacpp_url")
+	}
+
+	if cfg.ShadowPercent < 0 || cfg.ShadowPercent > 100 {
+		return Config{}, fmt.Errorf("config: shadow_percent %v out of range 0-100", cfg.ShadowPercent)
+	}
+	if cfg.ShadowURL != "" && cfg.ShadowPath == "" {
+		return Config{}, fmt.Errorf("config: shadow_url requires shadow_path")
+	}
 
 	return cfg, nil
 }
+
+// parseKeyList parses "name:key,name2:key2".
+func parseKeyList(v string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, pair := range strings.Split(v, ",") {
+		name, key, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || name == "" || key == "" {
+			return nil, fmt.Errorf("entry %q is not name:key", pair)
+		}
+		keys[name] = key
+	}
+	return keys, nil
+}
+
+// splitList parses a comma-separated list, dropping empty items.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestLoadDefaults(t *testing.T) {
@@ -38,6 +39,9 @@ func TestLoadDefaults(t *testing.T) {
 	if cfg.APIKey != "" {
 		t.Errorf("default api_key: got %q, want empty", cfg.APIKey)
 	}
+	if cfg.RequestTimeout != 120*time.Second {
+		t.Errorf("default request_timeout: got %v, want %v", cfg.RequestTimeout, 120*time.Second)
+	}
 }
 
 func TestLoadFromYAML(t *testing.T) {
@@ -53,6 +57,7 @@ llamacpp_url: "http://localhost:8080"
 llamacpp_model: "qwen2.5-1.5b"
 prompt_path: "/etc/pollex/polish.txt"
 api_key: "my-secret-key"
+request_timeout: 90s
 `
 	if err := os.WriteFile(yamlPath, []byte(content), 0644); err != nil {
 		t.Fatalf("write yaml: %v", err)
@@ -76,6 +81,7 @@ api_key: "my-secret-key"
 		{"llamacpp_url", cfg.LlamaCppURL, "http://localhost:8080"},
 		{"llamacpp_model", cfg.LlamaCppModel, "qwen2.5-1.5b"},
 		{"api_key", cfg.APIKey, "my-secret-key"},
+		{"request_timeout", cfg.RequestTimeout, 90 * time.Second},
 	}
 
 	for _, tt := range tests {
@@ -103,6 +109,7 @@ ollama_url: "http://from-yaml:11434"
 	t.Setenv("POLLEX_LLAMACPP_URL", "http://from-env:8080")
 	t.Setenv("POLLEX_LLAMACPP_MODEL", "custom-model")
 	t.Setenv("POLLEX_API_KEY", "env-api-key")
+	t.Setenv("POLLEX_REQUEST_TIMEOUT", "45s")
 
 	cfg, err := Load(yamlPath)
 	if err != nil {
@@ -120,6 +127,7 @@ ollama_url: "http://from-yaml:11434"
 		{"llamacpp_url from env", cfg.LlamaCppURL, "http://from-env:8080"},
 		{"llamacpp_model from env", cfg.LlamaCppModel, "custom-model"},
 		{"api_key from env", cfg.APIKey, "env-api-key"},
+		{"request_timeout from env", cfg.RequestTimeout, 45 * time.Second},
 	}
 
 	for _, tt := range tests {
@@ -150,3 +158,360 @@ func TestLoadMissingFile(t *testing.T) {
 		t.Error("expected error for missing file, got nil")
 	}
 }
+
+func TestLoadInvalidRequestTimeout(t *testing.T) {
+	for _, v := range []string{"soon", "0s", "-5s"} {
+		t.Setenv("POLLEX_REQUEST_TIMEOUT", v)
+		if _, err := Load(""); err == nil {
+			t.Errorf("POLLEX_REQUEST_TIMEOUT=%s: expected error, got nil", v)
+		}
+	}
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	t.Setenv("POLLEX_API_KEY", "legacy")
+	t.Setenv("POLLEX_API_KEYS", "alice:k-alice, bob:k-bob")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	keys := cfg.Keys()
+	want := map[string]string{"default": "legacy", "alice": "k-alice", "bob": "k-bob"}
+	if len(keys) != len(want) {
+		t.Fatalf("keys: got %v, want %v", keys, want)
+	}
+	for name, key := range want {
+		if keys[name] != key {
+			t.Errorf("key %q: got %q, want %q", name, keys[name], key)
+		}
+	}
+}
+
+func TestLoadInvalidAPIKeys(t *testing.T) {
+	t.Setenv("POLLEX_API_KEYS", "alice")
+
+	if _, err := Load(""); err == nil {
+		t.Error("expected error for malformed POLLEX_API_KEYS, got nil")
+	}
+}
+
+func TestLoadHistory(t *testing.T) {
+	t.Setenv("POLLEX_HISTORY_PATH", "/var/lib/pollex/history.jsonl")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.HistoryPath != "/var/lib/pollex/history.jsonl" {
+		t.Errorf("history_path: got %q", cfg.HistoryPath)
+	}
+	if cfg.HistoryMaxEntries != 10000 {
+		t.Errorf("history_max_entries: got %d, want 10000", cfg.HistoryMaxEntries)
+	}
+	if cfg.HistoryMaxAge != 30*24*time.Hour {
+		t.Errorf("history_max_age: got %v, want 720h", cfg.HistoryMaxAge)
+	}
+}
+
+func TestLoadRedaction(t *testing.T) {
+	t.Setenv("POLLEX_REMOTE_MODELS", "gpu-box, ")
+	t.Setenv("POLLEX_REDACT_TERMS", "Acme Corp,Jane Doe")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.RedactRemote {
+		t.Error("redact_remote: got false, want true by default")
+	}
+	if len(cfg.RemoteModels) != 1 || cfg.RemoteModels[0] != "gpu-box" {
+		t.Errorf("remote_models: got %v, want [gpu-box]", cfg.RemoteModels)
+	}
+	if len(cfg.RedactTerms) != 2 || cfg.RedactTerms[1] != "Jane Doe" {
+		t.Errorf("redact_terms: got %v", cfg.RedactTerms)
+	}
+
+	t.Setenv("POLLEX_REDACT_REMOTE", "maybe")
+	if _, err := Load(""); err == nil {
+		t.Error("expected error for invalid POLLEX_REDACT_REMOTE, got nil")
+	}
+}
+
+func TestLoadRoutes(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "config.yaml")
+	content := `claude_api_key: "sk-test"
+claude_model: "claude-test"
+remote_models: ["gpu-box"]
+local_only_keys: ["legal"]
+routes:
+  - name: short-local
+    max_chars: 500
+    models: [qwen, claude-test]
+  - name: nightly
+    hours: "22:00-06:00"
+    tiers: [quality]
+    keys: [alice]
+    models: [claude-test]
+`
+	if err := os.WriteFile(yamlPath, []byte(content), 0644); err != nil {
+		t.Fatalf("write yaml: %v", err)
+	}
+
+	cfg, err := Load(yamlPath)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Routes) != 2 {
+		t.Fatalf("routes: got %d, want 2", len(cfg.Routes))
+	}
+	if r := cfg.Routes[0]; r.Name != "short-local" || r.MaxChars != 500 || len(r.Models) != 2 {
+		t.Errorf("route 0: got %+v", r)
+	}
+	if r := cfg.Routes[1]; r.Hours != "22:00-06:00" || r.Tiers[0] != "quality" || r.Keys[0] != "alice" {
+		t.Errorf("route 1: got %+v", r)
+	}
+
+	for model, want := range map[string]bool{"claude-test": true, "gpu-box": true, "qwen": false} {
+		if got := cfg.IsRemote(model); got != want {
+			t.Errorf("IsRemote(%q): got %v, want %v", model, got, want)
+		}
+	}
+}
+
+func TestLoadLlamaCppEndpoints(t *testing.T) {
+	t.Setenv("POLLEX_LLAMACPP_URL", "http://a:8080")
+	t.Setenv("POLLEX_LLAMACPP_URLS", "http://b:8080,http://a:8080")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	got := cfg.LlamaCppEndpoints()
+	if len(got) != 2 || got[0] != "http://a:8080" || got[1] != "http://b:8080" {
+		t.Errorf("endpoints: got %v, want [http://a:8080 http://b:8080]", got)
+	}
+	if cfg.LoadBalance != "least_in_flight" || cfg.EjectAfter != 3 || cfg.EjectFor != 30*time.Second {
+		t.Errorf("defaults: got %q/%d/%v", cfg.LoadBalance, cfg.EjectAfter, cfg.EjectFor)
+	}
+}
+
+func TestLoadInvalidLoadBalance(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(yamlPath, []byte("load_balance: random\n"), 0644); err != nil {
+		t.Fatalf("write yaml: %v", err)
+	}
+	if _, err := Load(yamlPath); err == nil {
+		t.Error("expected error for invalid load_balance, got nil")
+	}
+}
+
+func TestLoadSupervisor(t *testing.T) {
+	t.Setenv("POLLEX_LLAMASERVER_BIN", "/usr/local/bin/llama-server")
+
+	if _, err := Load(""); err == nil {
+		t.Error("expected error for llamaserver_bin without llamacpp_url, got nil")
+	}
+
+	t.Setenv("POLLEX_LLAMACPP_URL", "http://localhost:8080")
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.LlamaServerBin != "/usr/local/bin/llama-server" {
+		t.Errorf("llamaserver_bin: got %q", cfg.LlamaServerBin)
+	}
+	if cfg.SuperviseInterval != 10*time.Second || cfg.SuperviseFailures != 3 || cfg.SuperviseStartupGrace != 2*time.Minute {
+		t.Errorf("supervise defaults: got %v/%d/%v", cfg.SuperviseInterval, cfg.SuperviseFailures, cfg.SuperviseStartupGrace)
+	}
+}
+
+func TestLoadWarmup(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.Warmup || cfg.WarmupText == "" || cfg.KeepWarmInterval != 0 {
+		t.Errorf("warmup defaults: got %v/%q/%v", cfg.Warmup, cfg.WarmupText, cfg.KeepWarmInterval)
+	}
+
+	t.Setenv("POLLEX_WARMUP", "false")
+	t.Setenv("POLLEX_KEEP_WARM_INTERVAL", "5m")
+	cfg, err = Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Warmup || cfg.KeepWarmInterval != 5*time.Minute {
+		t.Errorf("warmup env: got %v/%v", cfg.Warmup, cfg.KeepWarmInterval)
+	}
+
+	t.Setenv("POLLEX_KEEP_WARM_INTERVAL", "often")
+	if _, err := Load(""); err == nil {
+		t.Error("expected error for invalid POLLEX_KEEP_WARM_INTERVAL, got nil")
+	}
+}
+
+func TestLoadShadow(t *testing.T) {
+	t.Setenv("POLLEX_SHADOW_URL", "http://localhost:8081")
+	if _, err := Load(""); err == nil {
+		t.Error("expected error for shadow_url without shadow_path, got nil")
+	}
+
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(yamlPath, []byte("shadow_path: /tmp/shadow.jsonl\nshadow_model: qwen3-1.7b\n"), 0644); err != nil {
+		t.Fatalf("write yaml: %v", err)
+	}
+	t.Setenv("POLLEX_SHADOW_PERCENT", "25")
+	cfg, err := Load(yamlPath)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.ShadowURL != "http://localhost:8081" || cfg.ShadowModel != "qwen3-1.7b" || cfg.ShadowPercent != 25 || cfg.ShadowConcurrency != 1 {
+		t.Errorf("shadow: got %q/%q/%v/%d", cfg.ShadowURL, cfg.ShadowModel, cfg.ShadowPercent, cfg.ShadowConcurrency)
+	}
+
+	t.Setenv("POLLEX_SHADOW_PERCENT", "150")
+	if _, err := Load(yamlPath); err == nil {
+		t.Error("expected error for shadow_percent 150, got nil")
+	}
+}
+
+func TestLoadPromptTemplates(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "config.yaml")
+	content := `prompt_vars:
+  tone: concise
+  audiences: [engineers]
+glossary_path: glossary.yaml
+key_glossaries:
+  legal: legal-glossary.yaml
+model_prompts:
+  qwen:
+    prompt_path: prompts/qwen.txt
+    examples_path: prompts/qwen-examples.yaml
+`
+	if err := os.WriteFile(yamlPath, []byte(content), 0644); err != nil {
+		t.Fatalf("write yaml: %v", err)
+	}
+
+	cfg, err := Load(yamlPath)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.PromptVars.Tone != "concise" {
+		t.Errorf("prompt_vars: got %+v", cfg.PromptVars)
+	}
+	// A list given in YAML replaces the default; one left out keeps it.
+	if len(cfg.PromptVars.Audiences) != 1 || cfg.PromptVars.Audiences[0] != "engineers" || len(cfg.PromptVars.Tones) == 0 {
+		t.Errorf("allowed values: got tones %v, audiences %v", cfg.PromptVars.Tones, cfg.PromptVars.Audiences)
+	}
+	if cfg.GlossaryPath != "glossary.yaml" || cfg.KeyGlossaries["legal"] != "legal-glossary.yaml" {
+		t.Errorf("glossary: got %q %v", cfg.GlossaryPath, cfg.KeyGlossaries)
+	}
+	if mp := cfg.ModelPrompts["qwen"]; mp.PromptPath != "prompts/qwen.txt" || mp.ExamplesPath != "prompts/qwen-examples.yaml" {
+		t.Errorf("model_prompts: got %+v", cfg.ModelPrompts)
+	}
+}
+
+func TestLoadRulesAdapter(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.RulesAdapter || cfg.RulesPath != "" {
+		t.Errorf("rules defaults: got %v/%q", cfg.RulesAdapter, cfg.RulesPath)
+	}
+
+	t.Setenv("POLLEX_RULES_ADAPTER", "false")
+	t.Setenv("POLLEX_RULES_PATH", "rules.yaml")
+	cfg, err = Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.RulesAdapter || cfg.RulesPath != "rules.yaml" {
+		t.Errorf("rules env: got %v/%q", cfg.RulesAdapter, cfg.RulesPath)
+	}
+
+	t.Setenv("POLLEX_RULES_ADAPTER", "maybe")
+	if _, err := Load(""); err == nil {
+		t.Error("expected error for invalid POLLEX_RULES_ADAPTER, got nil")
+	}
+}
+
+func TestLoadAlternatives(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `alternatives:
+  max: 3
+  temperature: 0.6
+  weights: {similarity: 2, lint: 1}
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write yaml: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := Alternatives{Max: 3, Temperature: 0.6, Weights: ScoreWeights{Similarity: 2, Lint: 1}}
+	if cfg.Alternatives != want {
+		t.Errorf("alternatives: got %+v, want %+v", cfg.Alternatives, want)
+	}
+}
+
+func TestLoadInjection(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Injection.Action != "flag" {
+		t.Errorf("default action: got %q", cfg.Injection.Action)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `injection:
+  action: reject
+  patterns: ["pretend to be"]
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write yaml: %v", err)
+	}
+	cfg, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Injection.Action != "reject" || len(cfg.Injection.Patterns) != 1 {
+		t.Errorf("yaml: got %+v", cfg.Injection)
+	}
+
+	t.Setenv("POLLEX_INJECTION_ACTION", "sanitize")
+	if cfg, _ = Load(path); cfg.Injection.Action != "sanitize" {
+		t.Errorf("env: got %q", cfg.Injection.Action)
+	}
+	t.Setenv("POLLEX_INJECTION_ACTION", "block")
+	if _, err := Load(""); err == nil {
+		t.Error("expected error for invalid injection action, got nil")
+	}
+}
+
+func TestLoadSegmentCacheSize(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.SegmentCacheSize != 5000 {
+		t.Errorf("default: got %d", cfg.SegmentCacheSize)
+	}
+
+	t.Setenv("POLLEX_SEGMENT_CACHE_SIZE", "0")
+	if cfg, _ = Load(""); cfg.SegmentCacheSize != 0 {
+		t.Errorf("env: got %d", cfg.SegmentCacheSize)
+	}
+	t.Setenv("POLLEX_SEGMENT_CACHE_SIZE", "lots")
+	if _, err := Load(""); err == nil {
+		t.Error("expected error for invalid POLLEX_SEGMENT_CACHE_SIZE, got nil")
+	}
+}
@@ -0,0 +1,280 @@
+// Package diff computes line and word edit scripts between two texts.
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Op is the kind of an Edit.
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Edit is one token of an edit script. Delete tokens come from the old text,
+// Insert tokens from the new one, Equal tokens from both.
+type Edit struct {
+	Op   Op
+	Text string
+}
+
+// Compute returns a minimal edit script turning a into b. The common prefix
+// and suffix are matched directly; the rest is diffed with Hirschberg's
+// algorithm, which takes O(len(a)·len(b)) time but only linear space, so a
+// text at the API's 10k-char cap costs kilobytes rather than a full LCS
+// table. Within each run of changes, deletions come before insertions.
+func Compute(a, b []string) []Edit {
+	pre, suf := commonEnds(a, b)
+	edits := make([]Edit, 0, len(a)+len(b)-pre-suf)
+	for _, t := range a[:pre] {
+		edits = append(edits, Edit{Equal, t})
+	}
+	edits = hirschberg(edits, a[pre:len(a)-suf], b[pre:len(b)-suf])
+	for _, t := range a[len(a)-suf:] {
+		edits = append(edits, Edit{Equal, t})
+	}
+	deletesFirst(edits)
+	return edits
+}
+
+// deletesFirst reorders each run of changes in place so its deletions come
+// before its insertions, keeping their relative order.
+func deletesFirst(edits []Edit) {
+	var ins []Edit
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+		w := i
+		ins = ins[:0]
+		for ; i < len(edits) && edits[i].Op != Equal; i++ {
+			if edits[i].Op == Delete {
+				edits[w] = edits[i]
+				w++
+			} else {
+				ins = append(ins, edits[i])
+			}
+		}
+		copy(edits[w:i], ins)
+	}
+}
+
+// commonEnds returns the lengths of the common prefix and suffix of a and b,
+// not overlapping.
+func commonEnds(a, b []string) (pre, suf int) {
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	return pre, suf
+}
+
+// hirschberg appends a minimal edit script turning a into b to edits.
+func hirschberg(edits []Edit, a, b []string) []Edit {
+	switch {
+	case len(a) == 0:
+		for _, t := range b {
+			edits = append(edits, Edit{Insert, t})
+		}
+		return edits
+	case len(b) == 0:
+		for _, t := range a {
+			edits = append(edits, Edit{Delete, t})
+		}
+		return edits
+	case len(a) == 1:
+		j := 0
+		for j < len(b) && b[j] != a[0] {
+			j++
+		}
+		if j == len(b) {
+			edits = append(edits, Edit{Delete, a[0]})
+			return hirschberg(edits, nil, b)
+		}
+		edits = hirschberg(edits, nil, b[:j])
+		edits = append(edits, Edit{Equal, a[0]})
+		return hirschberg(edits, nil, b[j+1:])
+	}
+
+	// Split a in half and b where the LCS of the two halves is longest.
+	mid := len(a) / 2
+	fwd, bwd := lcsPrefixes(a[:mid], b), lcsSuffixes(a[mid:], b)
+	k := 0
+	for j := range fwd {
+		if fwd[j]+bwd[j] > fwd[k]+bwd[k] {
+			k = j
+		}
+	}
+	edits = hirschberg(edits, a[:mid], b[:k])
+	return hirschberg(edits, a[mid:], b[k:])
+}
+
+// lcsPrefixes returns, for each j, the LCS length of a and b[:j].
+func lcsPrefixes(a, b []string) []int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// lcsSuffixes returns, for each j, the LCS length of a and b[j:].
+func lcsSuffixes(a, b []string) []int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				cur[j] = prev[j+1] + 1
+			} else {
+				cur[j] = max(prev[j], cur[j+1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// SplitLines splits s into lines, each keeping its trailing newline.
+func SplitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Unified renders a unified diff (as produced by diff -u) of a and b with
+// three lines of context. It returns "" when the texts are equal.
+func Unified(fromName, toName, a, b string) string {
+	const context = 3
+
+	edits := Compute(SplitLines(a), SplitLines(b))
+
+	// aPos[k], bPos[k]: number of old/new lines consumed before edits[k].
+	aPos := make([]int, len(edits)+1)
+	bPos := make([]int, len(edits)+1)
+	var changes []int
+	for k, e := range edits {
+		aPos[k+1], bPos[k+1] = aPos[k], bPos[k]
+		if e.Op != Insert {
+			aPos[k+1]++
+		}
+		if e.Op != Delete {
+			bPos[k+1]++
+		}
+		if e.Op != Equal {
+			changes = append(changes, k)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	for c := 0; c < len(changes); {
+		start := max(changes[c]-context, 0)
+		end := changes[c] + 1
+		for c++; c < len(changes) && changes[c]-end <= 2*context; c++ {
+			end = changes[c] + 1
+		}
+		end = min(end+context, len(edits))
+
+		aLen, bLen := aPos[end]-aPos[start], bPos[end]-bPos[start]
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aPos[start], aLen), hunkRange(bPos[start], bLen))
+		for _, e := range edits[start:end] {
+			prefix := " "
+			switch e.Op {
+			case Delete:
+				prefix = "-"
+			case Insert:
+				prefix = "+"
+			}
+			out.WriteString(prefix + e.Text)
+			if !strings.HasSuffix(e.Text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	return out.String()
+}
+
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// SplitWords splits s into alternating runs of whitespace and non-whitespace,
+// so that joining the result reproduces s exactly.
+func SplitWords(s string) []string {
+	var tokens []string
+	start := 0
+	inSpace := false
+	for i, r := range s {
+		space := unicode.IsSpace(r)
+		if i > start && space != inSpace {
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+		inSpace = space
+	}
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
+
+// Similarity scores how close a and b are word by word, from 0 (nothing in
+// common) to 1 (identical), as 2*matches/(len(a)+len(b)) over the
+// non-whitespace tokens. Two empty texts are identical. Only the LCS length
+// is needed, so no edit script is built and memory stays linear.
+func Similarity(a, b string) float64 {
+	wa, wb := strings.Fields(a), strings.Fields(b)
+	if len(wa)+len(wb) == 0 {
+		return 1
+	}
+	pre, suf := commonEnds(wa, wb)
+	lcs := lcsPrefixes(wa[pre:len(wa)-suf], wb[pre:len(wb)-suf])
+	matches := pre + suf + lcs[len(lcs)-1]
+	return 2 * float64(matches) / float64(len(wa)+len(wb))
+}
+
+// RuneOffsets converts the byte offsets into text that span returns for each
+// item to code point offsets, in place.
+func RuneOffsets[T any](text string, items []T, span func(*T) (start, end *int)) {
+	for i := range items {
+		start, end := span(&items[i])
+		*start, *end = utf8.RuneCountInString(text[:*start]), utf8.RuneCountInString(text[:*end])
+	}
+}
+
+// NonNil returns s, or an empty slice if s is nil, so it encodes as []
+// rather than null.
+func NonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -0,0 +1,240 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCompute(t *testing.T) {
+	edits := Compute([]string{"a", "b", "c"}, []string{"a", "x", "c", "d"})
+
+	var got []string
+	for _, e := range edits {
+		got = append(got, []string{"=", "-", "+"}[e.Op]+e.Text)
+	}
+	want := "=a -b +x =c +d"
+	if strings.Join(got, " ") != want {
+		t.Errorf("edits: got %q, want %q", strings.Join(got, " "), want)
+	}
+}
+
+func TestComputeMinimal(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	tokens := func() []string {
+		out := make([]string, rng.IntN(12))
+		for i := range out {
+			out[i] = string(rune('a' + rng.IntN(4)))
+		}
+		return out
+	}
+	for range 500 {
+		a, b := tokens(), tokens()
+		var gotA, gotB []string
+		matches, inserted := 0, false
+		for _, e := range Compute(a, b) {
+			if e.Op != Insert {
+				gotA = append(gotA, e.Text)
+			}
+			if e.Op != Delete {
+				gotB = append(gotB, e.Text)
+			}
+			switch e.Op {
+			case Equal:
+				matches++
+				inserted = false
+			case Insert:
+				inserted = true
+			case Delete:
+				if inserted {
+					t.Errorf("Compute(%q, %q): insertion before deletion in a run", a, b)
+				}
+			}
+		}
+		if !slices.Equal(gotA, a) || !slices.Equal(gotB, b) {
+			t.Fatalf("Compute(%q, %q) does not reproduce its inputs", a, b)
+		}
+		if want := lcsLength(a, b); matches != want {
+			t.Errorf("Compute(%q, %q): %d matches, want %d", a, b, matches, want)
+		}
+	}
+}
+
+// lcsLength is the textbook quadratic LCS, as a reference.
+func lcsLength(a, b []string) int {
+	t := make([][]int, len(a)+1)
+	for i := range t {
+		t[i] = make([]int, len(b)+1)
+	}
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				t[i+1][j+1] = t[i][j] + 1
+			} else {
+				t[i+1][j+1] = max(t[i][j+1], t[i+1][j])
+			}
+		}
+	}
+	return t[len(a)][len(b)]
+}
+
+func TestComputeLinearSpace(t *testing.T) {
+	// Two unrelated 10k-char texts: a full LCS table would take ~23 MB.
+	var a, b strings.Builder
+	for i := range 1700 {
+		fmt.Fprintf(&a, "w%d ", i)
+		fmt.Fprintf(&b, "v%d ", i)
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	Compute(strings.Fields(a.String()), strings.Fields(b.String()))
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 2<<20 {
+		t.Errorf("allocated %d bytes, want under 2 MiB", n)
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"one", 1},
+		{"one\n", 1},
+		{"one\ntwo", 2},
+		{"one\n\ntwo\n", 3},
+	}
+	for _, tt := range tests {
+		if got := len(SplitLines(tt.in)); got != tt.want {
+			t.Errorf("SplitLines(%q): got %d lines, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestUnifiedEqual(t *testing.T) {
+	if got := Unified("a", "b", "same\n", "same\n"); got != "" {
+		t.Errorf("got %q, want empty", got)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := "line 1\nline 2\nline 3\nline 4\nline 5\nline 6\nline 7\nline 8\nline 9\nline 10\n"
+	b := "line 1\nline two\nline 3\nline 4\nline 5\nline 6\nline 7\nline 8\nline 9\nline 10\nline 11"
+
+	got := Unified("a.txt", "b.txt", a, b)
+	want := `--- a.txt
++++ b.txt
+@@ -1,5 +1,5 @@
+ line 1
+-line 2
++line two
+ line 3
+ line 4
+ line 5
+@@ -8,3 +8,4 @@
+ line 8
+ line 9
+ line 10
++line 11
+\ No newline at end of file
+`
+	if got != want {
+		t.Errorf("unified diff mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestUnifiedAppliesWithPatch checks the output against patch(1) when available.
+func TestUnifiedAppliesWithPatch(t *testing.T) {
+	if _, err := exec.LookPath("patch"); err != nil {
+		t.Skip("patch not installed")
+	}
+
+	a := "The quick brown fox\njumps over\nthe lazy dog.\n\nSecond paragraph.\n"
+	b := "The quick brown fox\njumped over\nthe lazy dog.\n\nSecond paragraph, edited.\nThird.\n"
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "text.txt")
+	if err := os.WriteFile(file, []byte(a), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("patch", "-s", file)
+	cmd.Stdin = strings.NewReader(Unified("text.txt", "text.txt", a, b))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("patch: %v\n%s", err, out)
+	}
+
+	got, _ := os.ReadFile(file)
+	if string(got) != b {
+		t.Errorf("patched: got %q, want %q", got, b)
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	in := "I goes  to store.\n"
+	got := SplitWords(in)
+	want := []string{"I", " ", "goes", "  ", "to", " ", "store.", "\n"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if strings.Join(got, "") != in {
+		t.Error("tokens do not reassemble the input")
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"I went to the store.", "I went to the store.", 1},
+		{"I went  to the\nstore.", "I went to the store.", 1},
+		{"I went to the store.", "", 0},
+		{"one two three four", "one two five four", 0.75},
+	}
+	for _, tt := range tests {
+		if got := Similarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("Similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestRuneOffsets(t *testing.T) {
+	type span struct{ Start, End int }
+	text := "¿Qué tal? ok"
+	spans := []span{{0, 2}, {2, 6}, {12, 14}}
+	RuneOffsets(text, spans, func(s *span) (*int, *int) { return &s.Start, &s.End })
+	want := []span{{0, 1}, {1, 4}, {10, 12}}
+	if !slices.Equal(spans, want) {
+		t.Errorf("got %v, want %v", spans, want)
+	}
+
+	if got := NonNil[span](nil); got == nil || len(got) != 0 {
+		t.Errorf("NonNil(nil): got %#v, want empty slice", got)
+	}
+}
+
+func TestSimilarityLinearSpace(t *testing.T) {
+	// Ranking scores up to five candidates per request, each against an
+	// input of up to 10k chars.
+	var a, b strings.Builder
+	for i := range 1700 {
+		fmt.Fprintf(&a, "w%d ", i)
+		fmt.Fprintf(&b, "w%d ", i%7)
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	Similarity(a.String(), b.String())
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 2<<20 {
+		t.Errorf("allocated %d bytes, want under 2 MiB", n)
+	}
+}
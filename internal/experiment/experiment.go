@@ -0,0 +1,246 @@
+// Package experiment runs A/B tests over system prompts and models. Callers
+// are assigned to variants by API key, so one person always sees the same
+// variant while an experiment runs.
+package experiment
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/mlorentedev/pollex/internal/adapter"
+	"github.com/mlorentedev/pollex/internal/config"
+	"github.com/mlorentedev/pollex/internal/history"
+	"github.com/mlorentedev/pollex/internal/prompt"
+)
+
+var (
+	// ErrNotFound is returned for an unknown experiment name.
+	ErrNotFound = errors.New("experiment: not found")
+	// ErrConflict is returned when starting an experiment while another runs.
+	ErrConflict = errors.New("experiment: another experiment is running")
+)
+
+// Variant is one arm of an experiment.
+type Variant struct {
+	Name   string
+	Weight int
+	Model  string           // "" keeps the requested or routed model
+	Prompt *prompt.Template // nil keeps the model's configured prompt
+}
+
+// Assignment is the variant a request belongs to.
+type Assignment struct {
+	Experiment string
+	Variant
+}
+
+// Status describes an experiment for the admin API.
+type Status struct {
+	Name     string
+	Running  bool
+	Started  time.Time // zero if never started
+	Stopped  time.Time // zero if running or never started
+	Variants []Variant
+}
+
+type experiment struct {
+	name     string
+	variants []Variant
+	total    int
+	running  bool
+	started  time.Time
+	stopped  time.Time
+}
+
+// Manager holds every configured experiment. It is safe for concurrent use.
+type Manager struct {
+	mu          sync.Mutex
+	experiments []*experiment
+	now         func() time.Time
+}
+
+// New validates defs and loads variant prompt templates. Models must be
+// registered in adapters.
+func New(defs []config.Experiment, adapters map[string]adapter.LLMAdapter) (*Manager, error) {
+	m := &Manager{now: time.Now}
+	names := make(map[string]bool)
+	running := ""
+	for _, d := range defs {
+		if d.Name == "" {
+			return nil, fmt.Errorf("experiment: missing name")
+		}
+		if names[d.Name] {
+			return nil, fmt.Errorf("experiment %q: duplicate name", d.Name)
+		}
+		names[d.Name] = true
+		if len(d.Variants) == 0 {
+			return nil, fmt.Errorf("experiment %q: no variants", d.Name)
+		}
+
+		e := &experiment{name: d.Name}
+		seen := make(map[string]bool)
+		for _, vd := range d.Variants {
+			if vd.Name == "" || seen[vd.Name] {
+				return nil, fmt.Errorf("experiment %q: variant names must be unique and non-empty", d.Name)
+			}
+			seen[vd.Name] = true
+			if vd.Weight < 0 {
+				return nil, fmt.Errorf("experiment %q: variant %q: negative weight", d.Name, vd.Name)
+			}
+			v := Variant{Name: vd.Name, Weight: max(vd.Weight, 1), Model: vd.Model}
+			if vd.Model != "" {
+				if _, ok := adapters[vd.Model]; !ok {
+					return nil, fmt.Errorf("experiment %q: variant %q: unknown model %q", d.Name, vd.Name, vd.Model)
+				}
+			}
+			if vd.PromptPath != "" {
+				tmpl, err := prompt.ParseFile(vd.PromptPath)
+				if err != nil {
+					return nil, fmt.Errorf("experiment %q: variant %q: %w", d.Name, vd.Name, err)
+				}
+				v.Prompt = tmpl
+			}
+			e.variants = append(e.variants, v)
+			e.total += v.Weight
+		}
+
+		if d.Running {
+			if running != "" {
+				return nil, fmt.Errorf("experiment %q: %q is already running", d.Name, running)
+			}
+			running = d.Name
+			e.running = true
+			e.started = m.now()
+		}
+		m.experiments = append(m.experiments, e)
+	}
+	return m, nil
+}
+
+// Assign returns key's variant in the running experiment, if any. The
+// choice depends only on the experiment name, the variants and key.
+func (m *Manager) Assign(key string) (Assignment, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range m.experiments {
+		if !e.running {
+			continue
+		}
+		h := fnv.New32a()
+		h.Write([]byte(e.name))
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+		n := int(h.Sum32() % uint32(e.total))
+		for _, v := range e.variants {
+			if n < v.Weight {
+				return Assignment{Experiment: e.name, Variant: v}, true
+			}
+			n -= v.Weight
+		}
+	}
+	return Assignment{}, false
+}
+
+// Start runs the named experiment. Starting a running experiment is a no-op.
+func (m *Manager) Start(name string) (Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.find(name)
+	if e == nil {
+		return Status{}, ErrNotFound
+	}
+	if e.running {
+		return e.status(), nil
+	}
+	for _, o := range m.experiments {
+		if o.running {
+			return Status{}, fmt.Errorf("%w: %s", ErrConflict, o.name)
+		}
+	}
+	e.running = true
+	e.started = m.now()
+	e.stopped = time.Time{}
+	return e.status(), nil
+}
+
+// Stop halts the named experiment. Stopping a stopped experiment is a no-op.
+func (m *Manager) Stop(name string) (Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.find(name)
+	if e == nil {
+		return Status{}, ErrNotFound
+	}
+	if e.running {
+		e.running = false
+		e.stopped = m.now()
+	}
+	return e.status(), nil
+}
+
+// List returns every experiment in config order.
+func (m *Manager) List() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]Status, 0, len(m.experiments))
+	for _, e := range m.experiments {
+		out = append(out, e.status())
+	}
+	return out
+}
+
+func (m *Manager) find(name string) *experiment {
+	for _, e := range m.experiments {
+		if e.name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+func (e *experiment) status() Status {
+	return Status{
+		Name:     e.name,
+		Running:  e.running,
+		Started:  e.started,
+		Stopped:  e.stopped,
+		Variants: e.variants,
+	}
+}
+
+// Tally counts one variant's recorded polishes and their ratings.
+type Tally struct {
+	Requests int
+	Accepted int
+	Rejected int
+}
+
+// Tallies counts, per variant, the history entries tagged with experiment
+// across all keys.
+func Tallies(store *history.Store, experiment string) map[string]Tally {
+	out := make(map[string]Tally)
+	store.Scan(func(e history.Entry) {
+		if e.Experiment != experiment {
+			return
+		}
+		t := out[e.Variant]
+		t.Requests++
+		if e.Feedback != nil {
+			switch e.Feedback.Rating {
+			case history.RatingAccepted:
+				t.Accepted++
+			case history.RatingRejected:
+				t.Rejected++
+			}
+		}
+		out[e.Variant] = t
+	})
+	return out
+}
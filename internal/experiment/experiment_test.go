@@ -0,0 +1,155 @@
+package experiment
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mlorentedev/pollex/internal/adapter"
+	"github.com/mlorentedev/pollex/internal/config"
+	"github.com/mlorentedev/pollex/internal/history"
+)
+
+var testAdapters = map[string]adapter.LLMAdapter{
+	"local":  &adapter.MockAdapter{},
+	"remote": &adapter.MockAdapter{},
+}
+
+func promptFile(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "prompt.txt")
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatalf("write prompt: %v", err)
+	}
+	return path
+}
+
+func TestAssignSticky(t *testing.T) {
+	m, err := New([]config.Experiment{{
+		Name:    "terse",
+		Running: true,
+		Variants: []config.ExperimentVariant{
+			{Name: "control", Weight: 3},
+			{Name: "terse", Weight: 1, PromptPath: promptFile(t, "Be terse.")},
+		},
+	}}, testAdapters)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	counts := map[string]int{}
+	for i := range 1000 {
+		key := fmt.Sprintf("key-%d", i)
+		a, ok := m.Assign(key)
+		if !ok {
+			t.Fatal("Assign: not assigned while running")
+		}
+		if again, _ := m.Assign(key); again.Name != a.Name {
+			t.Fatalf("Assign(%s): got %s then %s", key, a.Name, again.Name)
+		}
+		counts[a.Name]++
+		if (a.Prompt != nil) != (a.Name == "terse") || a.Experiment != "terse" {
+			t.Fatalf("assignment %+v", a)
+		}
+	}
+	// 3:1 weights; allow generous slack for hashing.
+	if counts["control"] < 650 || counts["control"] > 850 {
+		t.Errorf("control share: got %d/1000, want ~750", counts["control"])
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	m, err := New([]config.Experiment{
+		{Name: "a", Running: true, Variants: []config.ExperimentVariant{{Name: "x"}}},
+		{Name: "b", Variants: []config.ExperimentVariant{{Name: "y", Model: "remote"}}},
+	}, testAdapters)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	if _, err := m.Start("b"); !errors.Is(err, ErrConflict) {
+		t.Errorf("Start b while a runs: got %v, want ErrConflict", err)
+	}
+	if _, err := m.Start("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Start missing: got %v, want ErrNotFound", err)
+	}
+
+	st, err := m.Stop("a")
+	if err != nil || st.Running || !st.Stopped.Equal(now) {
+		t.Errorf("Stop a: got %+v, %v", st, err)
+	}
+	if _, ok := m.Assign("k"); ok {
+		t.Error("Assign with nothing running: got assigned")
+	}
+
+	st, err = m.Start("b")
+	if err != nil || !st.Running || !st.Started.Equal(now) {
+		t.Errorf("Start b: got %+v, %v", st, err)
+	}
+	if st, err := m.Start("b"); err != nil || !st.Running {
+		t.Errorf("Start b again: got %+v, %v, want no-op", st, err)
+	}
+	if a, ok := m.Assign("k"); !ok || a.Experiment != "b" || a.Model != "remote" {
+		t.Errorf("Assign: got %+v, %v", a, ok)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		defs []config.Experiment
+	}{
+		{"no variants", []config.Experiment{{Name: "a"}}},
+		{"duplicate experiment", []config.Experiment{
+			{Name: "a", Variants: []config.ExperimentVariant{{Name: "x"}}},
+			{Name: "a", Variants: []config.ExperimentVariant{{Name: "x"}}},
+		}},
+		{"duplicate variant", []config.Experiment{{Name: "a", Variants: []config.ExperimentVariant{{Name: "x"}, {Name: "x"}}}}},
+		{"unknown model", []config.Experiment{{Name: "a", Variants: []config.ExperimentVariant{{Name: "x", Model: "gpt"}}}}},
+		{"missing prompt", []config.Experiment{{Name: "a", Variants: []config.ExperimentVariant{{Name: "x", PromptPath: "/nonexistent"}}}}},
+		{"bad template", []config.Experiment{{Name: "a", Variants: []config.ExperimentVariant{{Name: "x", PromptPath: promptFile(t, "{{.Nope}}")}}}}},
+		{"two running", []config.Experiment{
+			{Name: "a", Running: true, Variants: []config.ExperimentVariant{{Name: "x"}}},
+			{Name: "b", Running: true, Variants: []config.ExperimentVariant{{Name: "x"}}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.defs, testAdapters); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestTallies(t *testing.T) {
+	store, err := history.Open(filepath.Join(t.TempDir(), "history.jsonl"), 0, 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer store.Close()
+
+	add := func(id, key, exp, variant string) {
+		store.Add(history.Entry{ID: id, Key: key, Time: time.Now(), Experiment: exp, Variant: variant})
+	}
+	add("1", "alice", "e", "control")
+	add("2", "bob", "e", "terse")
+	add("3", "bob", "e", "terse")
+	add("4", "bob", "other", "terse")
+	add("5", "bob", "", "")
+	store.SetFeedback("alice", "1", history.Feedback{Rating: history.RatingRejected})
+	store.SetFeedback("bob", "2", history.Feedback{Rating: history.RatingAccepted})
+
+	got := Tallies(store, "e")
+	if got["control"] != (Tally{Requests: 1, Rejected: 1}) {
+		t.Errorf("control: got %+v", got["control"])
+	}
+	if got["terse"] != (Tally{Requests: 2, Accepted: 1}) {
+		t.Errorf("terse: got %+v", got["terse"])
+	}
+}
@@ -0,0 +1,340 @@
+// Package explain turns a polish into a list of edits with reasons, so
+// writers can learn from the changes instead of only getting a rewrite.
+// The model is asked for JSON (see Instructions); replies that can't be
+// parsed fall back to a word diff without reasons.
+package explain
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mlorentedev/pollex/internal/diff"
+)
+
+// Categories reported in Edit.Category.
+const (
+	CategoryGrammar   = "grammar"
+	CategoryFlow      = "flow"
+	CategoryConcision = "concision"
+)
+
+// Sources reported in Explanation.Source.
+const (
+	SourceModel = "model" // edits and reasons from the model
+	SourceDiff  = "diff"  // edits computed from the texts, no reasons
+)
+
+// maxReasonLength caps a reason, in characters; models sometimes ramble.
+const maxReasonLength = 200
+
+// Instructions is appended to the system prompt in explain mode.
+const Instructions = `Reply with a single JSON object and nothing else, in this shape:
+{"polished": "<the full polished text>", "edits": [{"original": "<exact text from the input>", "replacement": "<new text>", "category": "grammar|flow|concision", "reason": "<one short sentence>"}]}
+List every change in input order. "original" must be copied exactly from the input.`
+
+// Edit is one change. Start and End are offsets into the input in Unicode
+// code points, End exclusive; an insertion has Start == End.
+type Edit struct {
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	Original    string `json:"original"`
+	Replacement string `json:"replacement"`
+	Category    string `json:"category,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// Explanation lists the edits that turn the input into the polished text,
+// ordered by Start. Edits is never nil.
+type Explanation struct {
+	Source string `json:"source"`
+	Edits  []Edit `json:"edits"`
+}
+
+// ErrNoText means the reply contained neither valid JSON nor plain text.
+var ErrNoText = errors.New("explain: no polished text in reply")
+
+type reply struct {
+	Polished string `json:"polished"`
+	Edits    []struct {
+		Original    string `json:"original"`
+		Replacement string `json:"replacement"`
+		Category    string `json:"category"`
+		Reason      string `json:"reason"`
+	} `json:"edits"`
+}
+
+var (
+	fenceRe    = regexp.MustCompile("(?s)^```[a-zA-Z]*\\s*(.*)```")
+	polishedRe = regexp.MustCompile(`"polished"\s*:\s*("(?:[^"\\]|\\.)*")`)
+)
+
+// Parse extracts the polished text and edits from a model reply to input.
+// JSON is repaired where possible (code fences, trailing commas, raw
+// newlines, truncation); edits whose original text isn't in the input are
+// dropped. When no edit survives, or only the polished text can be
+// recovered, or the model ignored the instructions and replied in plain
+// text, the edits come from Diff instead.
+func Parse(input, raw string) (string, Explanation, error) {
+	// Only a fence around the whole reply is stripped; one inside the
+	// polished text is part of it.
+	raw = strings.TrimSpace(raw)
+	if m := fenceRe.FindStringSubmatch(raw); m != nil {
+		raw = strings.TrimSpace(m[1])
+	}
+
+	open := strings.IndexByte(raw, '{')
+	if open < 0 {
+		if raw == "" {
+			return "", Explanation{}, ErrNoText
+		}
+		return raw, Diff(input, raw), nil
+	}
+	obj := raw[open:]
+	if end := strings.LastIndexByte(obj, '}'); end >= 0 {
+		obj = obj[:end+1]
+	}
+
+	var rep reply
+	if err := json.Unmarshal([]byte(obj), &rep); err != nil {
+		if err := json.Unmarshal([]byte(repair(obj)), &rep); err != nil {
+			rep = reply{Polished: salvage(obj)}
+		}
+	}
+	polished := strings.TrimSpace(rep.Polished)
+	if polished == "" {
+		return "", Explanation{}, ErrNoText
+	}
+
+	var edits []Edit
+	cursor := 0
+	for _, e := range rep.Edits {
+		if e.Original == "" || e.Original == e.Replacement {
+			continue
+		}
+		start := indexFrom(input, e.Original, cursor)
+		if start < 0 {
+			continue
+		}
+		end := start + len(e.Original)
+		cursor = end
+		edits = append(edits, Edit{
+			Start:       start,
+			End:         end,
+			Original:    e.Original,
+			Replacement: e.Replacement,
+			Category:    category(e.Category),
+			Reason:      reason(e.Reason),
+		})
+	}
+	if len(edits) == 0 && polished != input {
+		return polished, Diff(input, polished), nil
+	}
+	slices.SortStableFunc(edits, func(a, b Edit) int { return a.Start - b.Start })
+	edits = dropOverlaps(edits)
+	diff.RuneOffsets(input, edits, editSpan)
+	return polished, Explanation{Source: SourceModel, Edits: diff.NonNil(edits)}, nil
+}
+
+// indexFrom finds s in text at or after from, or anywhere if the model
+// listed edits out of order.
+func indexFrom(text, s string, from int) int {
+	if i := strings.Index(text[from:], s); i >= 0 {
+		return from + i
+	}
+	return strings.Index(text, s)
+}
+
+func dropOverlaps(edits []Edit) []Edit {
+	out := edits[:0]
+	end := -1
+	for _, e := range edits {
+		if e.Start < end {
+			continue
+		}
+		out = append(out, e)
+		end = e.End
+	}
+	return out
+}
+
+func category(c string) string {
+	switch c = strings.ToLower(strings.TrimSpace(c)); c {
+	case CategoryGrammar, CategoryFlow, CategoryConcision:
+		return c
+	}
+	return ""
+}
+
+func reason(r string) string {
+	r = strings.Join(strings.Fields(r), " ")
+	if utf8.RuneCountInString(r) > maxReasonLength {
+		r = string([]rune(r)[:maxReasonLength-1]) + "…"
+	}
+	return r
+}
+
+// repair fixes the JSON mistakes small models make: raw control characters
+// inside strings, trailing commas, and output cut off mid-object.
+func repair(s string) string {
+	var b strings.Builder
+	var closers []byte
+	inString, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			case c == '\n':
+				b.WriteString(`\n`)
+				continue
+			case c == '\r':
+				b.WriteString(`\r`)
+				continue
+			case c == '\t':
+				b.WriteString(`\t`)
+				continue
+			}
+			b.WriteByte(c)
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			closers = append(closers, '}')
+		case '[':
+			closers = append(closers, ']')
+		case '}', ']':
+			trimTrailingComma(&b)
+			if len(closers) > 0 {
+				closers = closers[:len(closers)-1]
+			}
+		}
+		b.WriteByte(c)
+	}
+	if inString {
+		if escaped {
+			b.WriteByte('\\')
+		}
+		b.WriteByte('"')
+	}
+	for i := len(closers) - 1; i >= 0; i-- {
+		trimTrailingComma(&b)
+		b.WriteByte(closers[i])
+	}
+	return b.String()
+}
+
+func trimTrailingComma(b *strings.Builder) {
+	s := strings.TrimRight(b.String(), " \t\r\n")
+	if t, ok := strings.CutSuffix(s, ","); ok {
+		b.Reset()
+		b.WriteString(t)
+	}
+}
+
+// salvage returns the "polished" string from JSON too broken to repair.
+func salvage(s string) string {
+	m := polishedRe.FindStringSubmatch(s)
+	if m == nil {
+		return ""
+	}
+	text, err := strconv.Unquote(m[1])
+	if err != nil {
+		return ""
+	}
+	return text
+}
+
+// Diff computes edits word by word, without categories or reasons. Only
+// words are diffed; an edit's span then takes in the whitespace between its
+// words, so changes separated only by whitespace form one edit, and
+// whitespace-only changes are not reported.
+func Diff(input, polished string) Explanation {
+	in, out := wordSpans(input), wordSpans(polished)
+	script := diff.Compute(spanTexts(input, in), spanTexts(polished, out))
+
+	var edits []Edit
+	i, j := 0, 0 // words of input and polished consumed
+	for k := 0; k < len(script); {
+		if script[k].Op == diff.Equal {
+			i, j, k = i+1, j+1, k+1
+			continue
+		}
+		i0, j0 := i, j
+		for ; k < len(script) && script[k].Op != diff.Equal; k++ {
+			if script[k].Op == diff.Delete {
+				i++
+			} else {
+				j++
+			}
+		}
+		edits = append(edits, changeEdit(input, polished, in, out, i0, i, j0, j))
+	}
+	diff.RuneOffsets(input, edits, editSpan)
+	return Explanation{Source: SourceDiff, Edits: diff.NonNil(edits)}
+}
+
+// changeEdit builds the edit replacing words in[i0:i1] of input with
+// out[j0:j1] of polished. A pure deletion or insertion also takes the
+// whitespace after its words (before them at the end of the text), so
+// applying it doesn't leave a double or missing space.
+func changeEdit(input, polished string, in, out []span, i0, i1, j0, j1 int) Edit {
+	switch {
+	case i0 == i1: // insertion
+		if i0 < len(in) {
+			return Edit{Start: in[i0].start, End: in[i0].start, Replacement: polished[out[j0].start:out[j1].start]}
+		}
+		pos, from := len(input), out[j0].start
+		if i0 > 0 {
+			pos, from = in[i0-1].end, out[j0-1].end
+		}
+		return Edit{Start: pos, End: pos, Replacement: polished[from:out[j1-1].end]}
+	case j0 == j1: // deletion
+		start, end := in[i0].start, in[i1-1].end
+		if i1 < len(in) {
+			end = in[i1].start
+		} else if i0 > 0 {
+			start = in[i0-1].end
+		}
+		return Edit{Start: start, End: end, Original: input[start:end]}
+	}
+	start, end := in[i0].start, in[i1-1].end
+	return Edit{Start: start, End: end, Original: input[start:end], Replacement: polished[out[j0].start:out[j1-1].end]}
+}
+
+// span is a word's byte range in its text.
+type span struct{ start, end int }
+
+// wordSpans returns the ranges of the non-whitespace runs of s.
+func wordSpans(s string) []span {
+	var spans []span
+	pos := 0
+	for _, t := range diff.SplitWords(s) {
+		if strings.TrimSpace(t) != "" {
+			spans = append(spans, span{pos, pos + len(t)})
+		}
+		pos += len(t)
+	}
+	return spans
+}
+
+func spanTexts(s string, spans []span) []string {
+	texts := make([]string, len(spans))
+	for i, sp := range spans {
+		texts[i] = s[sp.start:sp.end]
+	}
+	return texts
+}
+
+func editSpan(e *Edit) (*int, *int) { return &e.Start, &e.End }
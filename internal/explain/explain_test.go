@@ -0,0 +1,138 @@
+package explain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	input := "I didn't saw the the email."
+	want := "I didn't see the email."
+	model := []Edit{
+		{Start: 9, End: 12, Original: "saw", Replacement: "see", Category: CategoryGrammar, Reason: "Use the base form after \"didn't\"."},
+		{Start: 13, End: 21, Original: "the the ", Replacement: "the ", Category: CategoryConcision, Reason: "Remove the repeated word."},
+	}
+
+	tests := []struct {
+		name   string
+		raw    string
+		source string
+	}{
+		{"valid", `{"polished": "I didn't see the email.", "edits": [
+			{"original": "saw", "replacement": "see", "category": "grammar", "reason": "Use the base form after \"didn't\"."},
+			{"original": "the the ", "replacement": "the ", "category": "Concision", "reason": "Remove the repeated word."}]}`, SourceModel},
+		{"fenced with prose", "Sure!\n```json\n" + `{"polished": "I didn't see the email.", "edits": [
+			{"original": "saw", "replacement": "see", "category": "grammar", "reason": "Use the base form after \"didn't\"."},
+			{"original": "the the ", "replacement": "the ", "category": "concision", "reason": "Remove the repeated word."},]}` + "\n```", SourceModel},
+		{"out of order", `{"polished": "I didn't see the email.", "edits": [
+			{"original": "the the ", "replacement": "the ", "category": "concision", "reason": "Remove the repeated word."},
+			{"original": "saw", "replacement": "see", "category": "grammar", "reason": "Use the base form after \"didn't\"."}]}`, SourceModel},
+		{"truncated", `{"polished": "I didn't see the email.", "edits": [{"original": "saw", "replacement": "see"`, SourceModel},
+		{"unrepairable", `{"polished": "I didn't see the email.", "edits": [{"original": saw}]}`, SourceDiff},
+		{"edits not in input", `{"polished": "I didn't see the email.", "edits": [{"original": "seen", "replacement": "see"}]}`, SourceDiff},
+		{"fenced", "```json\n" + `{"polished": "I didn't see the email.", "edits": [
+			{"original": "saw", "replacement": "see", "category": "grammar", "reason": "Use the base form after \"didn't\"."},
+			{"original": "the the ", "replacement": "the ", "category": "concision", "reason": "Remove the repeated word."}]}` + "\n```\nHope this helps.", SourceModel},
+		{"plain text", "I didn't see the email.", SourceDiff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			polished, ex, err := Parse(input, tt.raw)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if polished != want {
+				t.Errorf("polished: got %q", polished)
+			}
+			if ex.Source != tt.source {
+				t.Errorf("source: got %q, want %q", ex.Source, tt.source)
+			}
+			if tt.source == SourceModel && tt.name != "truncated" && !reflect.DeepEqual(ex.Edits, model) {
+				t.Errorf("edits: got %+v", ex.Edits)
+			}
+		})
+	}
+}
+
+func TestParseCodeInPolished(t *testing.T) {
+	input := "Run this:\n```sh\nmake bulid\n```\nThen deploy."
+	want := "Run this:\n```sh\nmake build\n```\nThen deploy."
+	raw := `{"polished":"Run this:\n` + "```sh\\nmake build\\n```" + `\nThen deploy.","edits":[{"original":"bulid","replacement":"build","category":"spelling","reason":"Fix the typo."}]}`
+
+	polished, ex, err := Parse(input, raw)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if polished != want {
+		t.Errorf("polished: got %q, want %q", polished, want)
+	}
+	if ex.Source != SourceModel || len(ex.Edits) != 1 || ex.Edits[0].Original != "bulid" {
+		t.Errorf("explanation: got %+v", ex)
+	}
+}
+
+func TestParseNoText(t *testing.T) {
+	for _, raw := range []string{"", "```\n```", `{"edits": []}`} {
+		if _, _, err := Parse("hello", raw); !errors.Is(err, ErrNoText) {
+			t.Errorf("Parse(%q): got %v, want ErrNoText", raw, err)
+		}
+	}
+}
+
+func TestParseCleansFields(t *testing.T) {
+	_, ex, err := Parse("café au lait", `{"polished": "coffee au lait", "edits": [
+		{"original": "café", "replacement": "coffee", "category": "style", "reason": "  Plain\n English. "}]}`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := []Edit{{Start: 0, End: 4, Original: "café", Replacement: "coffee", Reason: "Plain English."}}
+	if !reflect.DeepEqual(ex.Edits, want) {
+		t.Errorf("edits: got %+v", ex.Edits)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name            string
+		input, polished string
+		want            []Edit
+	}{
+		{"equal", "Same text.", "Same text.", []Edit{}},
+		{"replace", "I saw it.", "I see it.", []Edit{{Start: 2, End: 5, Original: "saw", Replacement: "see"}}},
+		{"merged across space", "the big red dog", "the small blue dog",
+			[]Edit{{Start: 4, End: 11, Original: "big red", Replacement: "small blue"}}},
+		{"deletion", "very very good", "very good", []Edit{{Start: 5, End: 10, Original: "very ", Replacement: ""}}},
+		{"insertion", "Send report.", "Send the report.", []Edit{{Start: 5, End: 5, Original: "", Replacement: "the "}}},
+		{"rune offsets", "Jośe said hi", "Jośe says hi", []Edit{{Start: 5, End: 9, Original: "said", Replacement: "says"}}},
+		{"insertion at end", "Send it", "Send it today.", []Edit{{Start: 7, End: 7, Original: "", Replacement: " today."}}},
+		{"deletion at end", "Send it now", "Send it", []Edit{{Start: 7, End: 11, Original: " now", Replacement: ""}}},
+		{"whitespace only", "Send  it\nnow", "Send it now", []Edit{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ex := Diff(tt.input, tt.polished)
+			if ex.Source != SourceDiff {
+				t.Errorf("source: got %q", ex.Source)
+			}
+			if !reflect.DeepEqual(ex.Edits, tt.want) {
+				t.Errorf("edits: got %+v, want %+v", ex.Edits, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepair(t *testing.T) {
+	tests := map[string]string{
+		`{"a": [1, 2,], }`:          `{"a": [1, 2]}`,
+		"{\"a\": \"line\nbreak\"}":  `{"a": "line\nbreak"}`,
+		`{"a": [{"b": "cut`:         `{"a": [{"b": "cut"}]}`,
+		`{"a": "ends in escape\`:    `{"a": "ends in escape\\"}`,
+		`{"a": "keeps, ]} inside"}`: `{"a": "keeps, ]} inside"}`,
+	}
+	for in, want := range tests {
+		if got := repair(in); got != want {
+			t.Errorf("repair(%q): got %q, want %q", in, got, want)
+		}
+	}
+}
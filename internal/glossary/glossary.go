@@ -0,0 +1,255 @@
+// Package glossary keeps product names, acronyms and people's names intact
+// through a polish. Terms are passed to the prompt, and after generation
+// known misspellings are normalised and dropped terms are reported.
+package glossary
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mlorentedev/pollex/internal/config"
+)
+
+// Term is one glossary entry. Term is the canonical spelling and is matched
+// case-sensitively; Aliases are spellings rewritten to Term, matched
+// case-insensitively. In a file a bare string is a Term without aliases.
+type Term struct {
+	Term    string   `yaml:"term"`
+	Aliases []string `yaml:"aliases"`
+}
+
+// UnmarshalYAML accepts "Pollex" as well as {term: Pollex, aliases: [...]}.
+func (t *Term) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		t.Term = n.Value
+		return nil
+	}
+	type plain Term
+	return n.Decode((*plain)(t))
+}
+
+// Violation reports a term that appeared Expected times in the input (as
+// itself or an alias) but only Found times in the output.
+type Violation struct {
+	Term     string `json:"term"`
+	Expected int    `json:"expected"`
+	Found    int    `json:"found"`
+}
+
+type entry struct {
+	term    string
+	exact   *regexp.Regexp // the canonical spelling
+	aliases *regexp.Regexp // nil without aliases
+}
+
+// Glossary is an immutable set of terms, safe for concurrent use. A nil
+// *Glossary has no terms.
+type Glossary struct {
+	entries []entry
+}
+
+// New validates terms and compiles their matchers. A later duplicate of a
+// term replaces the earlier one, so per-key files can override the server's.
+func New(terms []Term) (*Glossary, error) {
+	var entries []entry
+	for _, t := range terms {
+		t.Term = strings.TrimSpace(t.Term)
+		if t.Term == "" {
+			return nil, fmt.Errorf("glossary: empty term")
+		}
+		e := entry{term: t.Term, exact: regexp.MustCompile(regexp.QuoteMeta(t.Term))}
+		var alts []string
+		for _, a := range t.Aliases {
+			if a = strings.TrimSpace(a); a == "" {
+				return nil, fmt.Errorf("glossary: %s: empty alias", t.Term)
+			}
+			if a != t.Term {
+				alts = append(alts, regexp.QuoteMeta(a))
+			}
+		}
+		if len(alts) > 0 {
+			// Longest first, so "k8s" isn't shadowed by a shorter alias "k8".
+			slices.SortFunc(alts, func(a, b string) int { return len(b) - len(a) })
+			e.aliases = regexp.MustCompile(`(?i)` + strings.Join(alts, "|"))
+		}
+		entries = append(entries, e)
+	}
+	return merge(nil, &Glossary{entries: entries}), nil
+}
+
+// LoadFile reads a YAML list of terms.
+func LoadFile(path string) (*Glossary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("glossary: %w", err)
+	}
+	var terms []Term
+	if err := yaml.Unmarshal(data, &terms); err != nil {
+		return nil, fmt.Errorf("glossary: parse %s: %w", path, err)
+	}
+	g, err := New(terms)
+	if err != nil {
+		return nil, fmt.Errorf("%w (in %s)", err, path)
+	}
+	return g, nil
+}
+
+// Terms returns the canonical spellings in file order, for the prompt.
+func (g *Glossary) Terms() []string {
+	if g == nil {
+		return nil
+	}
+	out := make([]string, len(g.entries))
+	for i, e := range g.entries {
+		out[i] = e.term
+	}
+	return out
+}
+
+// Enforce rewrites aliases in output to their canonical term and reports
+// terms the output has fewer of than the input. Only whole words match, so
+// "Go" is not found in "Google".
+func (g *Glossary) Enforce(input, output string) (string, []Violation) {
+	if g == nil {
+		return output, nil
+	}
+	var violations []Violation
+	for _, e := range g.entries {
+		expected := len(words(e.exact, input, ""))
+		if e.aliases != nil {
+			expected += len(words(e.aliases, input, e.term))
+			output = replace(e.aliases, output, e.term)
+		}
+		if found := len(words(e.exact, output, "")); found < expected {
+			violations = append(violations, Violation{Term: e.term, Expected: expected, Found: found})
+		}
+	}
+	return output, violations
+}
+
+// words returns the whole-word matches of re in s, except those equal to
+// skip (an alias matched case-insensitively may be the term itself).
+func words(re *regexp.Regexp, s, skip string) [][]int {
+	var out [][]int
+	for _, m := range re.FindAllStringIndex(s, -1) {
+		if boundary(s, m[0], m[1]) && s[m[0]:m[1]] != skip {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// boundary reports whether s[start:end] is not glued to a letter or digit.
+// Edges that are themselves punctuation ("C++", ".NET") need no boundary.
+func boundary(s string, start, end int) bool {
+	first, _ := utf8.DecodeRuneInString(s[start:end])
+	last, _ := utf8.DecodeLastRuneInString(s[start:end])
+	if isWord(first) && start > 0 {
+		if prev, _ := utf8.DecodeLastRuneInString(s[:start]); isWord(prev) {
+			return false
+		}
+	}
+	if isWord(last) && end < len(s) {
+		if next, _ := utf8.DecodeRuneInString(s[end:]); isWord(next) {
+			return false
+		}
+	}
+	return true
+}
+
+func isWord(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func replace(re *regexp.Regexp, s, with string) string {
+	matches := words(re, s, with)
+	if len(matches) == 0 {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(s[last:m[0]])
+		b.WriteString(with)
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// Set holds the server glossary and each API key's glossary merged over it.
+type Set struct {
+	base *Glossary
+	keys map[string]*Glossary
+}
+
+// Load reads cfg.GlossaryPath and cfg.KeyGlossaries. Both are optional; with
+// neither set the returned Set is nil.
+func Load(cfg config.Config) (*Set, error) {
+	if cfg.GlossaryPath == "" && len(cfg.KeyGlossaries) == 0 {
+		return nil, nil
+	}
+	var base *Glossary
+	if cfg.GlossaryPath != "" {
+		var err error
+		if base, err = LoadFile(cfg.GlossaryPath); err != nil {
+			return nil, err
+		}
+	}
+	keys := make(map[string]*Glossary, len(cfg.KeyGlossaries))
+	for key, path := range cfg.KeyGlossaries {
+		g, err := LoadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+		keys[key] = g
+	}
+	return NewSet(base, keys), nil
+}
+
+// NewSet returns a Set with base for every key and overrides per key name.
+func NewSet(base *Glossary, keys map[string]*Glossary) *Set {
+	s := &Set{base: base, keys: make(map[string]*Glossary, len(keys))}
+	for k, g := range keys {
+		s.keys[k] = merge(base, g)
+	}
+	return s
+}
+
+// For returns the glossary that applies to the API key name.
+func (s *Set) For(key string) *Glossary {
+	if s == nil {
+		return nil
+	}
+	if g, ok := s.keys[key]; ok {
+		return g
+	}
+	return s.base
+}
+
+// merge returns base's entries followed by over's; over wins on duplicates.
+func merge(base, over *Glossary) *Glossary {
+	g := &Glossary{}
+	index := make(map[string]int)
+	for _, src := range []*Glossary{base, over} {
+		if src == nil {
+			continue
+		}
+		for _, e := range src.entries {
+			if i, ok := index[e.term]; ok {
+				g.entries[i] = e
+				continue
+			}
+			index[e.term] = len(g.entries)
+			g.entries = append(g.entries, e)
+		}
+	}
+	return g
+}
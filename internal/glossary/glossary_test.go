@@ -0,0 +1,110 @@
+package glossary
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mlorentedev/pollex/internal/config"
+)
+
+func mustNew(t *testing.T, terms ...Term) *Glossary {
+	t.Helper()
+	g, err := New(terms)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return g
+}
+
+func TestEnforce(t *testing.T) {
+	g := mustNew(t,
+		Term{Term: "Pollex", Aliases: []string{"Pollux"}},
+		Term{Term: "k8s"},
+		Term{Term: "Go"},
+		Term{Term: "C++"},
+	)
+
+	tests := []struct {
+		name           string
+		input, output  string
+		wantOutput     string
+		wantViolations []Violation
+	}{
+		{"kept", "Pollex runs on k8s.", "Pollex runs on k8s.", "Pollex runs on k8s.", nil},
+		{"alias normalised", "Pollex is great.", "Pollux is great.", "Pollex is great.", nil},
+		{"alias case-insensitive", "pollux and POLLUX", "Pollux and POLLUX.", "Pollex and Pollex.", nil},
+		{"alias in input counts", "I like pollux", "I like Pollex.", "I like Pollex.", nil},
+		{"dropped", "Deploy to k8s now", "Deploy to Kubernetes now.", "Deploy to Kubernetes now.",
+			[]Violation{{Term: "k8s", Expected: 1, Found: 0}}},
+		{"one of two dropped", "k8s and k8s", "k8s and Kubernetes", "k8s and Kubernetes",
+			[]Violation{{Term: "k8s", Expected: 2, Found: 1}}},
+		{"whole words only", "Google is going", "Google is going.", "Google is going.", nil},
+		{"term case-sensitive", "Let's go.", "Let us go.", "Let us go.", nil},
+		{"punctuation edges", "I write C++.", "I write C.", "I write C.",
+			[]Violation{{Term: "C++", Expected: 1, Found: 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, violations := g.Enforce(tt.input, tt.output)
+			if out != tt.wantOutput {
+				t.Errorf("output: got %q, want %q", out, tt.wantOutput)
+			}
+			if !reflect.DeepEqual(violations, tt.wantViolations) {
+				t.Errorf("violations: got %+v, want %+v", violations, tt.wantViolations)
+			}
+		})
+	}
+}
+
+func TestNilGlossary(t *testing.T) {
+	var g *Glossary
+	out, violations := g.Enforce("Pollex", "Pollux")
+	if out != "Pollux" || violations != nil || g.Terms() != nil {
+		t.Errorf("nil glossary should do nothing, got %q %v", out, violations)
+	}
+	var s *Set
+	if s.For("alice") != nil {
+		t.Error("nil Set should return a nil glossary")
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	if _, err := New([]Term{{Term: "  "}}); err == nil {
+		t.Error("expected error for empty term")
+	}
+	if _, err := New([]Term{{Term: "Pollex", Aliases: []string{""}}}); err == nil {
+		t.Error("expected error for empty alias")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "glossary.yaml")
+	legal := filepath.Join(dir, "legal.yaml")
+	os.WriteFile(base, []byte("- Pollex\n- term: Kubernetes\n  aliases: [kubernetes, K8S]\n"), 0600)
+	os.WriteFile(legal, []byte("- GDPR\n- term: Pollex\n  aliases: [pollex.app]\n"), 0600)
+
+	s, err := Load(config.Config{GlossaryPath: base, KeyGlossaries: map[string]string{"legal": legal}})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := s.For("alice").Terms(); !reflect.DeepEqual(got, []string{"Pollex", "Kubernetes"}) {
+		t.Errorf("server terms: got %v", got)
+	}
+	if got := s.For("legal").Terms(); !reflect.DeepEqual(got, []string{"Pollex", "Kubernetes", "GDPR"}) {
+		t.Errorf("legal terms: got %v", got)
+	}
+	// The key's entry for Pollex replaces the server's, aliases included.
+	if out, _ := s.For("legal").Enforce("", "See pollex.app"); out != "See Pollex" {
+		t.Errorf("legal alias: got %q", out)
+	}
+
+	if s, err := Load(config.Config{}); s != nil || err != nil {
+		t.Errorf("no glossary configured: got %v, %v", s, err)
+	}
+	if _, err := Load(config.Config{GlossaryPath: filepath.Join(dir, "missing.yaml")}); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mlorentedev/pollex/internal/adapter"
+	"github.com/mlorentedev/pollex/internal/apierror"
+	"github.com/mlorentedev/pollex/internal/explain"
+	"github.com/mlorentedev/pollex/internal/glossary"
+	"github.com/mlorentedev/pollex/internal/history"
+	"github.com/mlorentedev/pollex/internal/lint"
+	"github.com/mlorentedev/pollex/internal/openapi"
+	"github.com/mlorentedev/pollex/internal/rank"
+	"github.com/mlorentedev/pollex/internal/supervisor"
+	"github.com/mlorentedev/pollex/pkg/client"
+)
+
+type specSchema struct {
+	Required   []string                   `json:"required"`
+	Properties map[string]json.RawMessage `json:"properties"`
+}
+
+type specDoc struct {
+	Components struct {
+		Schemas map[string]specSchema `json:"schemas"`
+	} `json:"components"`
+}
+
+// jsonFields returns the JSON property names of a struct type and the subset
+// that is always present (no omitempty).
+func jsonFields(t reflect.Type) (all, required []string) {
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		all = append(all, name)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(all)
+	sort.Strings(required)
+	return all, required
+}
+
+func TestOpenAPIContract(t *testing.T) {
+	var doc specDoc
+	if err := json.Unmarshal(openapi.Spec, &doc); err != nil {
+		t.Fatalf("parse spec: %v", err)
+	}
+
+	types := map[string]any{
+		"PolishRequest":   polishRequest{},
+		"PolishResponse":  polishResponse{},
+		"ModelInfo":       adapter.ModelInfo{},
+		"AdapterStatus":   adapterStatus{},
+		"Health":          healthResponse{},
+		"ProcessStatus":   supervisor.Status{},
+		"Readiness":       readyResponse{},
+		"HistoryEntry":    history.Entry{},
+		"HistoryPage":     historyResponse{},
+		"DeleteResult":    deleteResponse{},
+		"Feedback":        history.Feedback{},
+		"FeedbackRequest": feedbackRequest{},
+		"DatasetRecord":   datasetRecord{},
+		"Error":           apierror.Error{},
+
+		"ExperimentAssignment": experimentInfo{},
+		"Experiment":           experimentStatus{},
+		"ExperimentVariant":    variantStatus{},
+		"ExperimentList":       experimentsResponse{},
+		"GlossaryViolation":    glossary.Violation{},
+		"PolishLint":           polishLint{},
+		"PolishContext":        polishContext{},
+		"Languages":            languageInfo{},
+		"Injection":            injectionInfo{},
+		"Segments":             segmentInfo{},
+		"Explanation":          explain.Explanation{},
+		"Edit":                 explain.Edit{},
+		"Alternative":          rank.Candidate{},
+		"AlternativeScores":    rank.Scores{},
+		"LintRequest":          lintRequest{},
+		"LintReport":           lint.Report{},
+		"LintFinding":          lint.Finding{},
+		"LintStats":            lint.Stats{},
+	}
+
+	for name, v := range types {
+		t.Run(name, func(t *testing.T) {
+			schema, ok := doc.Components.Schemas[name]
+			if !ok {
+				t.Fatalf("schema %q missing from spec", name)
+			}
+
+			var props []string
+			for p := range schema.Properties {
+				props = append(props, p)
+			}
+			sort.Strings(props)
+			required := slices.Clone(schema.Required)
+			sort.Strings(required)
+
+			gotAll, gotRequired := jsonFields(reflect.TypeOf(v))
+			if !slices.Equal(gotAll, props) {
+				t.Errorf("properties: Go has %v, spec has %v", gotAll, props)
+			}
+			if !slices.Equal(gotRequired, required) {
+				t.Errorf("required: Go has %v, spec has %v", gotRequired, required)
+			}
+		})
+	}
+}
+
+// TestOpenAPIContractClient checks that pkg/client mirrors the spec. The
+// client deliberately omits deprecated properties.
+func TestOpenAPIContractClient(t *testing.T) {
+	var doc specDoc
+	if err := json.Unmarshal(openapi.Spec, &doc); err != nil {
+		t.Fatalf("parse spec: %v", err)
+	}
+
+	types := map[string]any{
+		"PolishRequest":        client.PolishRequest{},
+		"PolishResponse":       client.PolishResponse{},
+		"Route":                client.Route{},
+		"ExperimentAssignment": client.ExperimentAssignment{},
+		"GlossaryViolation":    client.GlossaryViolation{},
+		"PolishLint":           client.PolishLint{},
+		"PolishContext":        client.PolishContext{},
+		"Languages":            client.Languages{},
+		"Injection":            client.Injection{},
+		"Segments":             client.Segments{},
+		"Explanation":          client.Explanation{},
+		"Edit":                 client.Edit{},
+		"Alternative":          client.Alternative{},
+		"AlternativeScores":    client.AlternativeScores{},
+		"LintReport":           client.LintReport{},
+		"LintFinding":          client.LintFinding{},
+		"LintStats":            client.LintStats{},
+		"ModelInfo":            client.ModelInfo{},
+		"AdapterStatus":        client.AdapterStatus{},
+		"Health":               client.Health{},
+		"ProcessStatus":        client.ProcessStatus{},
+		"Error":                client.Error{},
+	}
+
+	for name, v := range types {
+		t.Run(name, func(t *testing.T) {
+			schema, ok := doc.Components.Schemas[name]
+			if !ok {
+				t.Fatalf("schema %q missing from spec", name)
+			}
+
+			var props []string
+			for p, raw := range schema.Properties {
+				var prop struct {
+					Deprecated bool `json:"deprecated"`
+				}
+				json.Unmarshal(raw, &prop)
+				if !prop.Deprecated {
+					props = append(props, p)
+				}
+			}
+			sort.Strings(props)
+
+			gotAll, _ := jsonFields(reflect.TypeOf(v))
+			if !slices.Equal(gotAll, props) {
+				t.Errorf("properties: client has %v, spec has %v", gotAll, props)
+			}
+		})
+	}
+}
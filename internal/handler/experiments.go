@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/mlorentedev/pollex/internal/apierror"
+	"github.com/mlorentedev/pollex/internal/experiment"
+	"github.com/mlorentedev/pollex/internal/history"
+	"github.com/mlorentedev/pollex/internal/middleware"
+)
+
+type experimentStatus struct {
+	Name     string          `json:"name"`
+	Running  bool            `json:"running"`
+	Started  *time.Time      `json:"started,omitempty"`
+	Stopped  *time.Time      `json:"stopped,omitempty"`
+	Variants []variantStatus `json:"variants"`
+}
+
+// variantStatus carries the counts needed to compare variants. Counts come
+// from history and are omitted when history is disabled.
+type variantStatus struct {
+	Name           string   `json:"name"`
+	Weight         int      `json:"weight"`
+	Model          string   `json:"model,omitempty"`
+	Prompt         string   `json:"prompt,omitempty"` // absent when the variant keeps the configured prompt
+	Requests       *int     `json:"requests,omitempty"`
+	Accepted       *int     `json:"accepted,omitempty"`
+	Rejected       *int     `json:"rejected,omitempty"`
+	AcceptanceRate *float64 `json:"acceptance_rate,omitempty"` // accepted / rated
+}
+
+type experimentsResponse struct {
+	Experiments []experimentStatus `json:"experiments"`
+}
+
+// Experiments lists experiments with per-variant results. Admin only.
+func Experiments(m *experiment.Manager, store *history.Store, adminKeys []string) http.HandlerFunc {
+	isAdmin := adminCheck(adminKeys)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		if !isAdmin(r) {
+			writeError(w, r, http.StatusForbidden, apierror.CodeForbidden, "admin API key required")
+			return
+		}
+
+		resp := experimentsResponse{Experiments: []experimentStatus{}}
+		for _, st := range m.List() {
+			resp.Experiments = append(resp.Experiments, toExperimentStatus(st, store))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// ExperimentControl serves POST /experiments/{name}/start and /stop. Both
+// are idempotent. Admin only.
+func ExperimentControl(m *experiment.Manager, store *history.Store, adminKeys []string, start bool) http.HandlerFunc {
+	isAdmin := adminCheck(adminKeys)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		if !isAdmin(r) {
+			writeError(w, r, http.StatusForbidden, apierror.CodeForbidden, "admin API key required")
+			return
+		}
+
+		name := r.PathValue("name")
+		var st experiment.Status
+		var err error
+		if start {
+			st, err = m.Start(name)
+		} else {
+			st, err = m.Stop(name)
+		}
+		switch {
+		case errors.Is(err, experiment.ErrNotFound):
+			writeError(w, r, http.StatusNotFound, apierror.CodeNotFound, "experiment not found")
+			return
+		case errors.Is(err, experiment.ErrConflict):
+			writeError(w, r, http.StatusConflict, apierror.CodeExperimentConflict, err.Error())
+			return
+		case err != nil:
+			writeError(w, r, http.StatusInternalServerError, apierror.CodeInternal, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toExperimentStatus(st, store))
+	}
+}
+
+// adminCheck returns whether a request's key is listed in adminKeys. With
+// auth disabled every caller is an admin, as every caller can already do
+// everything else.
+func adminCheck(adminKeys []string) func(*http.Request) bool {
+	admins := make(map[string]bool, len(adminKeys))
+	for _, k := range adminKeys {
+		admins[k] = true
+	}
+	return func(r *http.Request) bool {
+		key := middleware.KeyNameFromContext(r.Context())
+		return key == middleware.AnonymousKey || admins[key]
+	}
+}
+
+func toExperimentStatus(st experiment.Status, store *history.Store) experimentStatus {
+	out := experimentStatus{Name: st.Name, Running: st.Running, Variants: []variantStatus{}}
+	if !st.Started.IsZero() {
+		out.Started = &st.Started
+	}
+	if !st.Stopped.IsZero() {
+		out.Stopped = &st.Stopped
+	}
+
+	var tallies map[string]experiment.Tally
+	if store != nil {
+		tallies = experiment.Tallies(store, st.Name)
+	}
+	for _, v := range st.Variants {
+		vs := variantStatus{Name: v.Name, Weight: v.Weight, Model: v.Model}
+		if v.Prompt != nil {
+			vs.Prompt = v.Prompt.Version()
+		}
+		if tallies != nil {
+			t := tallies[v.Name]
+			vs.Requests, vs.Accepted, vs.Rejected = &t.Requests, &t.Accepted, &t.Rejected
+			if rated := t.Accepted + t.Rejected; rated > 0 {
+				rate := float64(t.Accepted) / float64(rated)
+				vs.AcceptanceRate = &rate
+			}
+		}
+		out.Variants = append(out.Variants, vs)
+	}
+	return out
+}
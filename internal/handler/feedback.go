@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mlorentedev/pollex/internal/apierror"
+	"github.com/mlorentedev/pollex/internal/history"
+	"github.com/mlorentedev/pollex/internal/metrics"
+	"github.com/mlorentedev/pollex/internal/middleware"
+)
+
+const maxCommentLength = 2000
+
+type feedbackRequest struct {
+	RequestID string `json:"request_id"`
+	Rating    string `json:"rating"`
+	Final     string `json:"final,omitempty"`
+	Comment   string `json:"comment,omitempty"`
+}
+
+// datasetRecord is one line of the feedback export: the polish input, the
+// model output and what the human actually kept.
+type datasetRecord struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Model   string    `json:"model"`
+	Prompt  string    `json:"prompt,omitempty"`
+	Mode    string    `json:"mode"`
+	Input   string    `json:"input"`
+	Output  string    `json:"output"`
+	Final   string    `json:"final,omitempty"`
+	Rating  string    `json:"rating"`
+	Comment string    `json:"comment,omitempty"`
+}
+
+// Feedback records the caller's rating of a polish identified by its
+// request ID. Only the first rating of an entry is counted in metrics.
+func Feedback(store *history.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		var req feedbackRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				writeError(w, r, http.StatusRequestEntityTooLarge, apierror.CodeBodyTooLarge, "request body too large")
+				return
+			}
+			writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidJSON, "invalid JSON body")
+			return
+		}
+
+		if req.RequestID == "" {
+			writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidFeedback, "request_id is required")
+			return
+		}
+		if req.Rating != history.RatingAccepted && req.Rating != history.RatingRejected {
+			writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidFeedback,
+				fmt.Sprintf("rating must be %q or %q", history.RatingAccepted, history.RatingRejected))
+			return
+		}
+		if len(req.Final) > maxTextLength {
+			e := apierror.New(apierror.CodeTextTooLong, fmt.Sprintf("final too long: %d characters (max %d)", len(req.Final), maxTextLength))
+			e.Details = map[string]any{"length": len(req.Final), "max": maxTextLength}
+			writeAPIError(w, r, http.StatusBadRequest, e)
+			return
+		}
+		if len(req.Comment) > maxCommentLength {
+			writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidFeedback,
+				fmt.Sprintf("comment too long: %d characters (max %d)", len(req.Comment), maxCommentLength))
+			return
+		}
+
+		key := middleware.KeyNameFromContext(r.Context())
+		e, replaced, err := store.SetFeedback(key, req.RequestID, history.Feedback{
+			Time:    time.Now().UTC(),
+			Rating:  req.Rating,
+			Final:   req.Final,
+			Comment: req.Comment,
+		})
+		if errors.Is(err, history.ErrNotFound) {
+			writeError(w, r, http.StatusNotFound, apierror.CodeNotFound, "history entry not found")
+			return
+		}
+		if err != nil {
+			slog.Error("feedback write failed", "error", err)
+			writeError(w, r, http.StatusInternalServerError, apierror.CodeInternal, "feedback write failed")
+			return
+		}
+
+		if !replaced {
+			metrics.FeedbackTotal.WithLabelValues(e.Model, e.Prompt, req.Rating).Inc()
+			if e.Experiment != "" {
+				metrics.ExperimentFeedback.WithLabelValues(e.Experiment, e.Variant, req.Rating).Inc()
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(e)
+	}
+}
+
+// FeedbackExport streams the caller's rated entries as JSONL, newest first.
+// It accepts the same model/since/until/q filters as History.
+func FeedbackExport(store *history.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		q, err := parseHistoryQuery(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidQuery, err.Error())
+			return
+		}
+		q.Key = middleware.KeyNameFromContext(r.Context())
+		q.Rated = true
+		q.Limit, q.Offset = 0, 0
+
+		entries, _ := store.Find(q)
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="pollex-feedback.jsonl"`)
+		enc := json.NewEncoder(w)
+		for _, e := range entries {
+			enc.Encode(toDatasetRecord(e))
+		}
+	}
+}
+
+func toDatasetRecord(e history.Entry) datasetRecord {
+	final := e.Feedback.Final
+	if final == "" && e.Feedback.Rating == history.RatingAccepted {
+		final = e.Output
+	}
+	return datasetRecord{
+		ID:      e.ID,
+		Time:    e.Time,
+		Model:   e.Model,
+		Prompt:  e.Prompt,
+		Mode:    e.Mode,
+		Input:   e.Input,
+		Output:  e.Output,
+		Final:   final,
+		Rating:  e.Feedback.Rating,
+		Comment: e.Feedback.Comment,
+	}
+}
@@ -6,20 +6,48 @@ import (
 
 	"github.com/mlorentedev/pollex/internal/adapter"
 	"github.com/mlorentedev/pollex/internal/metrics"
+	"github.com/mlorentedev/pollex/internal/supervisor"
+	"github.com/mlorentedev/pollex/internal/warmup"
 )
 
 type adapterStatus struct {
 	Available bool   `json:"available"`
 	Reason    string `json:"reason,omitempty"`
+	Warm      *bool  `json:"warm,omitempty"` // only for models that get warmed up
 }
 
 type healthResponse struct {
 	Status   string                   `json:"status"`
 	Version  string                   `json:"version"`
+	Ready    bool                     `json:"ready"`
 	Adapters map[string]adapterStatus `json:"adapters"`
+	Process  *supervisor.Status       `json:"process,omitempty"`
 }
 
-func Health(adapters map[string]adapter.LLMAdapter, version string) http.HandlerFunc {
+// HealthOption enables optional Health fields.
+type HealthOption func(*healthOptions)
+
+type healthOptions struct {
+	supervisor *supervisor.Supervisor
+	warmer     *warmup.Warmer
+}
+
+// WithSupervisor reports the supervised llama-server's state as "process".
+func WithSupervisor(s *supervisor.Supervisor) HealthOption {
+	return func(o *healthOptions) { o.supervisor = s }
+}
+
+// WithWarmer reports per-model warmth and derives "ready" from w.
+func WithWarmer(w *warmup.Warmer) HealthOption {
+	return func(o *healthOptions) { o.warmer = w }
+}
+
+func Health(adapters map[string]adapter.LLMAdapter, version string, opts ...HealthOption) http.HandlerFunc {
+	var o healthOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		statuses := make(map[string]adapterStatus, len(adapters))
 		for id, a := range adapters {
@@ -30,26 +58,58 @@ func Health(adapters map[string]adapter.LLMAdapter, version string) http.Handler
 				metrics.AdapterAvailable.WithLabelValues(id).Set(0)
 				s.Reason = unavailableReason(a)
 			}
+			if o.warmer != nil {
+				if warm, tracked := o.warmer.Warm(id); tracked {
+					s.Warm = &warm
+				}
+			}
 			statuses[id] = s
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(healthResponse{
+		resp := healthResponse{
 			Status:   "ok",
 			Version:  version,
+			Ready:    o.warmer == nil || o.warmer.Ready(),
 			Adapters: statuses,
-		})
+		}
+		if o.supervisor != nil {
+			st := o.supervisor.Status()
+			resp.Process = &st
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+type readyResponse struct {
+	Ready bool `json:"ready"`
+}
+
+// Ready answers 200 once every local model has been warmed up and 503 until
+// then, for load balancers and orchestrator readiness probes. A nil w means
+// warmup is disabled and the server is always ready.
+func Ready(w *warmup.Warmer) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ready := w == nil || w.Ready()
+		rw.Header().Set("Content-Type", "application/json")
+		if !ready {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(rw).Encode(readyResponse{Ready: ready})
 	}
 }
 
 func unavailableReason(a adapter.LLMAdapter) string {
-	switch a.(type) {
+	switch adapter.Base(a).(type) {
 	case *adapter.ClaudeAdapter:
 		return "no API key"
 	case *adapter.OllamaAdapter:
 		return "ollama unreachable"
 	case *adapter.LlamaCppAdapter:
 		return "llama-server unreachable"
+	case *adapter.PoolAdapter:
+		return "no healthy endpoint"
 	default:
 		return "unavailable"
 	}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mlorentedev/pollex/internal/apierror"
+	"github.com/mlorentedev/pollex/internal/history"
+	"github.com/mlorentedev/pollex/internal/middleware"
+)
+
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+type historyResponse struct {
+	Entries []history.Entry `json:"entries"`
+	Total   int             `json:"total"`
+	Limit   int             `json:"limit"`
+	Offset  int             `json:"offset"`
+}
+
+type deleteResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+// History serves GET (list/search) and DELETE (clear) on the caller's history.
+func History(store *history.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := middleware.KeyNameFromContext(r.Context())
+
+		switch r.Method {
+		case http.MethodGet:
+			q, err := parseHistoryQuery(r)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidQuery, err.Error())
+				return
+			}
+			q.Key = key
+			entries, total := store.Find(q)
+			if entries == nil {
+				entries = []history.Entry{}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(historyResponse{Entries: entries, Total: total, Limit: q.Limit, Offset: q.Offset})
+		case http.MethodDelete:
+			n, err := store.DeleteAll(key)
+			if err != nil {
+				writeError(w, r, http.StatusInternalServerError, apierror.CodeInternal, "history delete failed")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(deleteResponse{Deleted: n})
+		default:
+			writeError(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		}
+	}
+}
+
+// HistoryEntry serves GET and DELETE on /history/{id} for the caller's key.
+func HistoryEntry(store *history.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := middleware.KeyNameFromContext(r.Context())
+		id := r.PathValue("id")
+
+		switch r.Method {
+		case http.MethodGet:
+			e, ok := store.Get(key, id)
+			if !ok {
+				writeError(w, r, http.StatusNotFound, apierror.CodeNotFound, "history entry not found")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(e)
+		case http.MethodDelete:
+			ok, err := store.Delete(key, id)
+			if err != nil {
+				writeError(w, r, http.StatusInternalServerError, apierror.CodeInternal, "history delete failed")
+				return
+			}
+			if !ok {
+				writeError(w, r, http.StatusNotFound, apierror.CodeNotFound, "history entry not found")
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		}
+	}
+}
+
+func parseHistoryQuery(r *http.Request) (history.Query, error) {
+	v := r.URL.Query()
+	q := history.Query{
+		Model:  v.Get("model"),
+		Search: v.Get("q"),
+		Limit:  defaultHistoryLimit,
+	}
+
+	var err error
+	if q.Since, err = parseTime(v.Get("since")); err != nil {
+		return q, fmt.Errorf("invalid since: %w", err)
+	}
+	if q.Until, err = parseTime(v.Get("until")); err != nil {
+		return q, fmt.Errorf("invalid until: %w", err)
+	}
+	if s := v.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit < 1 || q.Limit > maxHistoryLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxHistoryLimit)
+		}
+	}
+	if s := v.Get("offset"); s != "" {
+		if q.Offset, err = strconv.Atoi(s); err != nil || q.Offset < 0 {
+			return q, fmt.Errorf("offset must be a non-negative integer")
+		}
+	}
+	return q, nil
+}
+
+// parseTime accepts RFC 3339 timestamps or plain dates (YYYY-MM-DD, UTC).
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/mlorentedev/pollex/internal/apierror"
+	"github.com/mlorentedev/pollex/internal/lint"
+)
+
+type lintRequest struct {
+	Text string `json:"text"`
+}
+
+// Lint checks text against the style rules without calling a model.
+func Lint(l *lint.Linter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		var req lintRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				writeError(w, r, http.StatusRequestEntityTooLarge, apierror.CodeBodyTooLarge, "request body too large")
+				return
+			}
+			writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidJSON, "invalid JSON body")
+			return
+		}
+		if !validText(w, r, req.Text) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(l.Check(req.Text))
+	}
+}
@@ -1,33 +1,241 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/mlorentedev/pollex/internal/adapter"
+	"github.com/mlorentedev/pollex/internal/apierror"
+	"github.com/mlorentedev/pollex/internal/diff"
+	"github.com/mlorentedev/pollex/internal/experiment"
+	"github.com/mlorentedev/pollex/internal/explain"
+	"github.com/mlorentedev/pollex/internal/glossary"
+	"github.com/mlorentedev/pollex/internal/history"
+	"github.com/mlorentedev/pollex/internal/injection"
+	"github.com/mlorentedev/pollex/internal/lang"
+	"github.com/mlorentedev/pollex/internal/lint"
 	"github.com/mlorentedev/pollex/internal/metrics"
+	"github.com/mlorentedev/pollex/internal/middleware"
+	"github.com/mlorentedev/pollex/internal/prompt"
+	"github.com/mlorentedev/pollex/internal/rank"
+	"github.com/mlorentedev/pollex/internal/redact"
+	"github.com/mlorentedev/pollex/internal/router"
+	"github.com/mlorentedev/pollex/internal/segment"
+	"github.com/mlorentedev/pollex/internal/shadow"
 )
 
 const maxTextLength = 10000
 
+// maxContextLength bounds the reference context, counted apart from text.
+const maxContextLength = 10000
+
+// statusClientClosedRequest is nginx's non-standard 499, used so that logs and
+// request metrics tell client disconnects apart from server-side failures.
+const statusClientClosedRequest = 499
+
+// autoModel asks the router to choose; so does an empty model_id.
+const autoModel = "auto"
+
 type polishRequest struct {
 	Text    string `json:"text"`
-	ModelID string `json:"model_id"`
+	ModelID string `json:"model_id,omitempty"`
+	Tier    string `json:"tier,omitempty"` // latency tier hint for routing
+
+	// Prompt template variables; empty means the configured default. Tone
+	// and audience must be among the configured choices.
+	Tone     string `json:"tone,omitempty"`
+	Audience string `json:"audience,omitempty"`
+
+	// Language is a deprecated alias of TargetLanguage that also accepts
+	// a language's English name.
+	Language string `json:"language,omitempty"`
+
+	// TargetLanguage is the language code to write in; the text is
+	// translated if it is in another. Empty keeps the input's language.
+	TargetLanguage string `json:"target_language,omitempty"`
+
+	Lint    bool `json:"lint,omitempty"`    // include style lint reports for input and output
+	Explain bool `json:"explain,omitempty"` // list the edits with reasons
+	N       int  `json:"n,omitempty"`       // candidates to generate and rank; 0 means 1
+
+	// Incremental reuses cached sentences from earlier polishes and sends
+	// only the changed ones.
+	Incremental bool `json:"incremental,omitempty"`
+
+	Context *polishContext `json:"context,omitempty"` // background the model must not polish
+}
+
+// polishContext is what the text is written in reply to. It reaches the
+// model as a separate, delimited message and is never part of the output.
+type polishContext struct {
+	PreviousMessage string `json:"previous_message,omitempty"`
+	Audience        string `json:"audience,omitempty"`
+	Purpose         string `json:"purpose,omitempty"`
+}
+
+func (c *polishContext) reference() adapter.Reference {
+	if c == nil {
+		return adapter.Reference{}
+	}
+	return adapter.Reference{PreviousMessage: c.PreviousMessage, Audience: c.Audience, Purpose: c.Purpose}
 }
 
 type polishResponse struct {
-	Polished  string `json:"polished"`
-	Model     string `json:"model"`
-	ElapsedMs int64  `json:"elapsed_ms"`
+	Polished   string          `json:"polished"`
+	Model      string          `json:"model"`
+	ElapsedMs  int64           `json:"elapsed_ms"`
+	Route      *routeInfo      `json:"route,omitempty"`
+	Experiment *experimentInfo `json:"experiment,omitempty"`
+
+	// GlossaryViolations lists glossary terms the output dropped. The text
+	// is returned as generated; the client decides what to do.
+	GlossaryViolations []glossary.Violation `json:"glossary_violations,omitempty"`
+
+	Lint    *polishLint          `json:"lint,omitempty"`
+	Explain *explain.Explanation `json:"explain,omitempty"`
+
+	// Alternatives holds every distinct candidate, best first, when the
+	// request asked for n > 1. Polished is the first.
+	Alternatives []rank.Candidate `json:"alternatives,omitempty"`
+
+	Languages *languageInfo  `json:"languages,omitempty"`
+	Injection *injectionInfo `json:"injection,omitempty"`
+	Segments  *segmentInfo   `json:"segments,omitempty"`
+}
+
+// segmentInfo reports how much of an incremental polish came from cache.
+type segmentInfo struct {
+	Total  int `json:"total"`
+	Reused int `json:"reused"`
+}
+
+// injectionInfo reports a prompt injection check that fired. Matches are
+// the phrases found in the text and context; Leak is set when the output
+// repeated the system prompt.
+type injectionInfo struct {
+	Action  string   `json:"action"`
+	Matches []string `json:"matches,omitempty"`
+	Leak    bool     `json:"leak"`
+}
+
+// languageInfo reports detected and requested languages. Codes are empty
+// when detection could not tell; Mismatch is set only when it could.
+type languageInfo struct {
+	Input      string `json:"input,omitempty"`
+	Target     string `json:"target,omitempty"`
+	Output     string `json:"output,omitempty"`
+	Translated bool   `json:"translated"`
+	Mismatch   bool   `json:"mismatch"`
+}
+
+// polishLint lets the client compare the style of input and output.
+type polishLint struct {
+	Input  lint.Report `json:"input"`
+	Output lint.Report `json:"output"`
+}
+
+// routeInfo tells the client why a model was chosen for it.
+type routeInfo struct {
+	Rule string `json:"rule"`
+}
+
+// experimentInfo names the experiment variant that served the request.
+type experimentInfo struct {
+	Name    string `json:"name"`
+	Variant string `json:"variant"`
+}
+
+// PolishOption enables optional Polish behaviour.
+type PolishOption func(*polishOptions)
+
+type polishOptions struct {
+	history     *history.Store
+	router      *router.Router
+	shadow      *shadow.Mirror
+	experiments *experiment.Manager
+	prompts     *prompt.Set
+	glossary    *glossary.Set
+	linter      *lint.Linter
+	ranker      *rank.Ranker
+	injection   *injection.Detector
+	segments    *segment.Cache
+}
+
+// WithSegments lets requests polish incrementally, reusing sentences
+// cached in c.
+func WithSegments(c *segment.Cache) PolishOption {
+	return func(o *polishOptions) { o.segments = c }
+}
+
+// WithInjection checks requests for prompt injection and outputs for
+// system prompt leaks, acting as d is configured.
+func WithInjection(d *injection.Detector) PolishOption {
+	return func(o *polishOptions) { o.injection = d }
+}
+
+// WithRanker lets requests ask for n alternatives, ranked by r.
+func WithRanker(r *rank.Ranker) PolishOption {
+	return func(o *polishOptions) { o.ranker = r }
+}
+
+// WithLinter serves lint reports to polish requests that ask for them.
+func WithLinter(l *lint.Linter) PolishOption {
+	return func(o *polishOptions) { o.linter = l }
+}
+
+// WithGlossary passes the caller's glossary terms to the prompt and checks
+// the output against them.
+func WithGlossary(s *glossary.Set) PolishOption {
+	return func(o *polishOptions) { o.glossary = s }
+}
+
+// WithPrompts renders the system prompt per request from s instead of
+// sending the fixed systemPrompt.
+func WithPrompts(s *prompt.Set) PolishOption {
+	return func(o *polishOptions) { o.prompts = s }
+}
+
+// WithRouter lets requests omit model_id and enforces local-only keys.
+func WithRouter(r *router.Router) PolishOption {
+	return func(o *polishOptions) { o.router = r }
+}
+
+// WithExperiments assigns callers to the running experiment's variants.
+func WithExperiments(m *experiment.Manager) PolishOption {
+	return func(o *polishOptions) { o.experiments = m }
+}
+
+// WithShadow offers every successful polish to m for background mirroring.
+func WithShadow(m *shadow.Mirror) PolishOption {
+	return func(o *polishOptions) { o.shadow = m }
+}
+
+// WithHistory records every successful polish in s.
+func WithHistory(s *history.Store) PolishOption {
+	return func(o *polishOptions) { o.history = s }
 }
 
-func Polish(adapters map[string]adapter.LLMAdapter, systemPrompt string) http.HandlerFunc {
+func Polish(adapters map[string]adapter.LLMAdapter, systemPrompt string, opts ...PolishOption) http.HandlerFunc {
+	var o polishOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	prompts := o.prompts
+	if prompts == nil {
+		prompts = prompt.NewSet(prompt.Literal(systemPrompt), prompt.Vars{})
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
-			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			writeError(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 			return
 		}
 
@@ -35,23 +243,149 @@ func Polish(adapters map[string]adapter.LLMAdapter, systemPrompt string) http.Ha
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			var maxBytesErr *http.MaxBytesError
 			if errors.As(err, &maxBytesErr) {
-				writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+				writeError(w, r, http.StatusRequestEntityTooLarge, apierror.CodeBodyTooLarge, "request body too large")
 				return
 			}
-			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidJSON, "invalid JSON body")
 			return
 		}
 
-		if req.Text == "" {
-			writeError(w, http.StatusBadRequest, "text is required")
+		if !validText(w, r, req.Text) {
+			return
+		}
+		// Tone and audience go into the system prompt, so only configured
+		// values are accepted.
+		tones, audiences := prompts.Allowed()
+		var ok bool
+		if req.Tone, ok = choose(w, r, "tone", req.Tone, tones); !ok {
+			return
+		}
+		if req.Audience, ok = choose(w, r, "audience", req.Audience, audiences); !ok {
+			return
+		}
+		ref := req.Context.reference()
+		if n := len(ref.PreviousMessage) + len(ref.Audience) + len(ref.Purpose); n > maxContextLength {
+			e := apierror.New(apierror.CodeContextTooLong, fmt.Sprintf("context too long: %d characters (max %d)", n, maxContextLength))
+			e.Details = map[string]any{"length": n, "max": maxContextLength}
+			writeAPIError(w, r, http.StatusBadRequest, e)
+			return
+		}
+		maxN := 1
+		if o.ranker != nil {
+			maxN = o.ranker.Max()
+		}
+		if req.N < 0 || req.N > maxN {
+			writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidOption, fmt.Sprintf("n must be between 1 and %d", maxN))
+			return
+		}
+		if req.N > 1 && req.Explain {
+			writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidOption, "explain is not supported with n > 1")
 			return
 		}
-		if len(req.Text) > maxTextLength {
-			writeError(w, http.StatusBadRequest, fmt.Sprintf("text too long: %d characters (max %d)", len(req.Text), maxTextLength))
+		// Each candidate is a generation of its own.
+		if req.N > 1 && !middleware.Charge(r.Context(), req.N-1) {
+			writeError(w, r, http.StatusTooManyRequests, apierror.CodeRateLimited,
+				fmt.Sprintf("rate limit exceeded: n=%d counts as %d requests", req.N, req.N))
 			return
 		}
+		if req.Incremental {
+			msg := ""
+			switch {
+			case o.segments == nil:
+				msg = "incremental polishing is disabled on this server"
+			case req.N > 1:
+				msg = "incremental is not supported with n > 1"
+			case req.Explain:
+				msg = "incremental is not supported with explain"
+			}
+			if msg != "" {
+				writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidOption, msg)
+				return
+			}
+		}
+		if req.Language != "" {
+			code := lang.Code(req.Language)
+			if code == "" || req.TargetLanguage != "" && req.TargetLanguage != code {
+				writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidOption,
+					"language is deprecated; use target_language, one of "+strings.Join(lang.Supported(), ", "))
+				return
+			}
+			req.TargetLanguage = code
+		}
+		if req.TargetLanguage != "" && lang.Name(req.TargetLanguage) == "" {
+			writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidOption,
+				fmt.Sprintf("target_language must be one of %s", strings.Join(lang.Supported(), ", ")))
+			return
+		}
+		langs := detectLanguages(req.Text, req.TargetLanguage)
+		if langs.Translated && req.Explain {
+			writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidOption, "explain is not supported when translating")
+			return
+		}
+		mode := "polish"
+		if langs.Translated {
+			mode = "translate"
+		}
+
+		key := middleware.KeyNameFromContext(r.Context())
+		var inj *injectionInfo
+		if d := o.injection; d != nil && d.Action() != injection.ActionOff {
+			if matches := injectionMatches(d, req.Text, ref); len(matches) > 0 {
+				auditInjection(r, key, req.ModelID, "input", d.Action(), len(matches))
+				if d.Action() == injection.ActionSanitize {
+					req.Text = d.Sanitize(req.Text)
+					ref = adapter.Reference{
+						PreviousMessage: d.Sanitize(ref.PreviousMessage),
+						Audience:        d.Sanitize(ref.Audience),
+						Purpose:         d.Sanitize(ref.Purpose),
+					}
+				}
+				if d.Action() == injection.ActionReject || req.Text == "" {
+					e := apierror.New(apierror.CodePromptInjection, "text contains instructions to the model")
+					e.Details = map[string]any{"matches": matches}
+					writeAPIError(w, r, http.StatusUnprocessableEntity, e)
+					return
+				}
+				inj = &injectionInfo{Action: d.Action(), Matches: matches}
+			}
+		}
+
+		var exp *experimentInfo
+		var variantPrompt *prompt.Template
+		if o.experiments != nil {
+			if a, ok := assignVariant(o.experiments, o.router, key, req.ModelID); ok {
+				if a.Model != "" {
+					req.ModelID = a.Model
+				}
+				variantPrompt = a.Prompt
+				exp = &experimentInfo{Name: a.Experiment, Variant: a.Name}
+			}
+		}
+
+		var route *routeInfo
+		if o.router != nil {
+			if req.ModelID == "" || req.ModelID == autoModel {
+				d, err := o.router.Route(router.Request{
+					Chars: len(req.Text),
+					Mode:  mode,
+					Key:   key,
+					Tier:  req.Tier,
+					Time:  time.Now(),
+				})
+				if err != nil {
+					writeError(w, r, http.StatusServiceUnavailable, apierror.CodeUpstreamUnavailable, "no available model for this request")
+					return
+				}
+				req.ModelID = d.Model
+				route = &routeInfo{Rule: d.Rule}
+			} else if !o.router.Allowed(key, req.ModelID) {
+				writeError(w, r, http.StatusForbidden, apierror.CodeModelForbidden,
+					fmt.Sprintf("model %s is remote and this API key is local-only", req.ModelID))
+				return
+			}
+		}
 		if req.ModelID == "" {
-			writeError(w, http.StatusBadRequest, "model_id is required")
+			writeError(w, r, http.StatusBadRequest, apierror.CodeModelRequired, "model_id is required")
 			return
 		}
 
@@ -59,26 +393,477 @@ func Polish(adapters map[string]adapter.LLMAdapter, systemPrompt string) http.Ha
 
 		a, ok := adapters[req.ModelID]
 		if !ok {
-			writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown model: %s", req.ModelID))
+			writeError(w, r, http.StatusBadRequest, apierror.CodeUnknownModel, fmt.Sprintf("unknown model: %s", req.ModelID))
+			return
+		}
+
+		gloss := o.glossary.For(key)
+		rendered, err := prompts.RenderWith(variantPrompt, req.ModelID, prompt.Vars{
+			Tone:     req.Tone,
+			Audience: req.Audience,
+			Glossary: gloss.Terms(),
+
+			Source:    langs.Input,
+			Target:    langs.Target,
+			Translate: langs.Translated,
+		})
+		if err != nil {
+			slog.Error("prompt render failed", "model", req.ModelID, "error", err)
+			writeError(w, r, http.StatusInternalServerError, apierror.CodeInternal, "prompt render failed")
 			return
 		}
+		ctx := adapter.WithExamples(r.Context(), rendered.Examples)
+		base := rendered.System
+		if ref != (adapter.Reference{}) {
+			ctx = adapter.WithReference(ctx, ref)
+			base += "\n\n" + adapter.ReferenceInstructions
+		}
+		system := base
+		if req.Explain {
+			system += "\n\n" + explain.Instructions
+		}
 
 		start := time.Now()
-		polished, err := a.Polish(r.Context(), req.Text, systemPrompt)
+		var polished string
+		var candidates []string
+		var segs *segmentInfo
+		if req.N > 1 {
+			workers := adapter.Parallelism(a)
+			if o.router != nil && o.router.Remote(req.ModelID) {
+				workers = req.N
+			}
+			candidates, err = polishCandidates(ctx, a, o.ranker, req.N, workers, req.Text, system)
+		} else if req.Incremental {
+			scope := strings.Join([]string{key, req.ModelID, rendered.Version, system,
+				ref.PreviousMessage, ref.Audience, ref.Purpose}, "\x00")
+			var si segmentInfo
+			polished, si, err = polishIncremental(ctx, a, o.segments, scope, req.Text, system, ref)
+			segs = &si
+		} else {
+			polished, err = a.Polish(ctx, req.Text, system)
+		}
+		var ex *explain.Explanation
+		if err == nil && req.Explain {
+			var e explain.Explanation
+			polished, e, err = explainReply(ctx, a, req.ModelID, req.Text, polished, base)
+			ex = &e
+		}
 		elapsed := time.Since(start)
 
 		if err != nil {
-			writeError(w, http.StatusBadGateway, fmt.Sprintf("polish failed: %v", err))
+			if r.Context().Err() == context.Canceled {
+				slog.Info("client closed request",
+					"request_id", middleware.RequestIDFromContext(r.Context()),
+					"model", req.ModelID,
+					"elapsed_ms", elapsed.Milliseconds(),
+				)
+				w.WriteHeader(statusClientClosedRequest)
+				return
+			}
+			status, code := classifyUpstreamError(err)
+			metrics.PolishErrors.WithLabelValues(req.ModelID, string(code)).Inc()
+			writeError(w, r, status, code, fmt.Sprintf("polish failed: %v", err))
 			return
 		}
 
 		metrics.PolishDuration.WithLabelValues(req.ModelID).Observe(elapsed.Seconds())
+		if polished != "" {
+			polished = stripEcho(ref, req.ModelID, req.Text, polished)
+		}
+		if d := o.injection; d != nil && d.Action() != injection.ActionOff {
+			leaked := false
+			if polished != "" {
+				polished, leaked = checkLeak(d, system, req.Text, polished)
+			}
+			for i := range candidates {
+				var l bool
+				candidates[i], l = checkLeak(d, system, req.Text, candidates[i])
+				leaked = leaked || l
+			}
+			if leaked {
+				auditInjection(r, key, req.ModelID, "output", d.Action(), 0)
+				if d.Action() == injection.ActionReject {
+					writeError(w, r, http.StatusUnprocessableEntity, apierror.CodePromptInjection, "output repeated the system prompt")
+					return
+				}
+				if inj == nil {
+					inj = &injectionInfo{Action: d.Action()}
+				}
+				inj.Leak = true
+			}
+		}
+		var alternatives []rank.Candidate
+		if candidates != nil {
+			for i := range candidates {
+				candidates[i] = stripEcho(ref, req.ModelID, req.Text, candidates[i])
+				candidates[i], _ = gloss.Enforce(req.Text, candidates[i])
+			}
+			alternatives = o.ranker.Rank(req.Text, candidates)
+			if len(alternatives) > 0 {
+				polished = alternatives[0].Text
+			}
+		}
+		polished, violations := gloss.Enforce(req.Text, polished)
+		if len(violations) > 0 {
+			metrics.GlossaryViolations.WithLabelValues(req.ModelID).Add(float64(len(violations)))
+		}
+		if langs.Target != "" {
+			langs.Output = lang.Detect(polished)
+			langs.Mismatch = langs.Output != "" && langs.Output != langs.Target
+			if langs.Mismatch {
+				metrics.LanguageMismatches.WithLabelValues(req.ModelID).Inc()
+			}
+		}
+		if ex != nil {
+			for i := range ex.Edits {
+				ex.Edits[i].Replacement, _ = gloss.Enforce("", ex.Edits[i].Replacement)
+			}
+		}
+		if exp != nil {
+			metrics.ExperimentDuration.WithLabelValues(exp.Name, exp.Variant).Observe(elapsed.Seconds())
+		}
+
+		if o.history != nil {
+			entry := history.Entry{
+				ID:        middleware.RequestIDFromContext(r.Context()),
+				Time:      start.UTC(),
+				Key:       key,
+				Model:     req.ModelID,
+				Mode:      mode,
+				Input:     req.Text,
+				Output:    polished,
+				ElapsedMs: elapsed.Milliseconds(),
+				Prompt:    rendered.Version,
+			}
+			if exp != nil {
+				entry.Experiment, entry.Variant = exp.Name, exp.Variant
+			}
+			err := o.history.Add(entry)
+			if err != nil {
+				slog.Error("history write failed", "error", err)
+			}
+		}
+		if o.shadow != nil {
+			o.shadow.Submit(shadow.Request{
+				ID:        middleware.RequestIDFromContext(r.Context()),
+				Key:       key,
+				Model:     req.ModelID,
+				Input:     req.Text,
+				Output:    polished,
+				ElapsedMs: elapsed.Milliseconds(),
 
+				SystemPrompt: base,
+				Examples:     rendered.Examples,
+				Reference:    ref,
+			})
+		}
+
+		resp := polishResponse{
+			Polished:           polished,
+			Model:              req.ModelID,
+			ElapsedMs:          elapsed.Milliseconds(),
+			Route:              route,
+			Experiment:         exp,
+			GlossaryViolations: violations,
+			Explain:            ex,
+			Alternatives:       alternatives,
+		}
+		if langs != (languageInfo{}) {
+			resp.Languages = &langs
+		}
+		resp.Injection = inj
+		resp.Segments = segs
+		if req.Lint && o.linter != nil {
+			resp.Lint = &polishLint{Input: o.linter.Check(req.Text), Output: o.linter.Check(polished)}
+		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(polishResponse{
-			Polished:  polished,
-			Model:     req.ModelID,
-			ElapsedMs: elapsed.Milliseconds(),
-		})
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// validText writes a 400 and returns false if text is empty or too long.
+func validText(w http.ResponseWriter, r *http.Request, text string) bool {
+	if text == "" {
+		writeError(w, r, http.StatusBadRequest, apierror.CodeTextRequired, "text is required")
+		return false
+	}
+	if len(text) > maxTextLength {
+		e := apierror.New(apierror.CodeTextTooLong, fmt.Sprintf("text too long: %d characters (max %d)", len(text), maxTextLength))
+		e.Details = map[string]any{"length": len(text), "max": maxTextLength}
+		writeAPIError(w, r, http.StatusBadRequest, e)
+		return false
+	}
+	return true
+}
+
+// choose matches value case-insensitively against allowed and returns the
+// configured spelling. An empty value is allowed and means the default.
+func choose(w http.ResponseWriter, r *http.Request, name, value string, allowed []string) (string, bool) {
+	if value == "" {
+		return "", true
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(a, value) {
+			return a, true
+		}
+	}
+	msg := name + " is not configurable on this server"
+	if len(allowed) > 0 {
+		msg = fmt.Sprintf("%s must be one of %s", name, strings.Join(allowed, ", "))
+	}
+	e := apierror.New(apierror.CodeInvalidOption, msg)
+	e.Details = map[string]any{name: value, "allowed": allowed}
+	writeAPIError(w, r, http.StatusBadRequest, e)
+	return "", false
+}
+
+// detectLanguages detects text's language and resolves the target: target
+// if set, the input's language otherwise.
+func detectLanguages(text, target string) languageInfo {
+	l := languageInfo{Input: lang.Detect(text), Target: target}
+	if l.Target == "" {
+		l.Target = l.Input
+	}
+	l.Translated = l.Input != "" && l.Input != l.Target
+	return l
+}
+
+// injectionMatches returns the phrases d flags in text and ref.
+func injectionMatches(d *injection.Detector, text string, ref adapter.Reference) []string {
+	var out []string
+	for _, s := range []string{text, ref.PreviousMessage, ref.Audience, ref.Purpose} {
+		for _, f := range d.Check(s) {
+			out = append(out, f.Match)
+		}
+	}
+	return out
+}
+
+// checkLeak reports whether output repeats the system prompt and, when d
+// sanitises, removes the leaked lines (all of them leaves the input).
+func checkLeak(d *injection.Detector, system, input, output string) (string, bool) {
+	clean, leaked := injection.Leak(system, input, output)
+	if !leaked || d.Action() != injection.ActionSanitize {
+		return output, leaked
+	}
+	if clean == "" {
+		return input, true
+	}
+	return clean, true
+}
+
+// auditInjection counts a detection and logs it for review, with the
+// request ID but never the text.
+func auditInjection(r *http.Request, key, model, stage, action string, matches int) {
+	metrics.PromptInjections.WithLabelValues(stage, action).Inc()
+	slog.Warn("prompt injection detected",
+		"audit", true,
+		"request_id", middleware.RequestIDFromContext(r.Context()),
+		"key", key,
+		"model", model,
+		"stage", stage,
+		"action", action,
+		"matches", matches,
+	)
+}
+
+// stripEcho removes any part of ref the model repeated in output.
+func stripEcho(ref adapter.Reference, model, input, output string) string {
+	if ref == (adapter.Reference{}) {
+		return output
+	}
+	out, stripped := ref.StripEcho(input, output)
+	if stripped {
+		metrics.ReferenceEchoes.WithLabelValues(model).Inc()
+	}
+	return out
+}
+
+// explainReply parses an explain-mode reply. If it holds no usable text the
+// input is polished again without the JSON instructions and the edits are
+// computed from the result.
+func explainReply(ctx context.Context, a adapter.LLMAdapter, model, input, reply, system string) (string, explain.Explanation, error) {
+	polished, ex, err := explain.Parse(input, reply)
+	if err != nil {
+		slog.Warn("explain reply unusable, retrying without it", "model", model, "error", err)
+		polished, err = a.Polish(ctx, input, system)
+		if err != nil {
+			return "", explain.Explanation{}, err
+		}
+		ex = explain.Diff(input, polished)
+	}
+	if ex.Source == explain.SourceDiff {
+		metrics.ExplainFallbacks.WithLabelValues(model).Inc()
+	}
+	return polished, ex, nil
+}
+
+// polishCandidates asks a for n rewrites, running at most workers at once
+// so a single-slot backend isn't queued up: the first with the backend's
+// default sampling, the rest with r's. Failed candidates are dropped; it
+// fails only if all of them do.
+func polishCandidates(ctx context.Context, a adapter.LLMAdapter, r *rank.Ranker, n, workers int, text, system string) ([]string, error) {
+	outs := make([]string, n)
+	errs := make([]error, n)
+	sem := make(chan struct{}, max(workers, 1))
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			c := ctx
+			if i > 0 {
+				c = adapter.WithSampling(ctx, r.Sampling(i))
+			}
+			outs[i], errs[i] = a.Polish(c, text, system)
+		}()
+	}
+	wg.Wait()
+
+	var texts []string
+	for i, out := range outs {
+		if errs[i] != nil {
+			slog.Warn("alternative failed", "candidate", i, "error", errs[i])
+			continue
+		}
+		texts = append(texts, out)
+	}
+	if len(texts) == 0 {
+		return nil, errs[0]
+	}
+	return texts, nil
+}
+
+// polishIncremental polishes text reusing sentences cached under scope.
+// With nothing cached the whole text goes out in one call, and the result
+// is cached sentence by sentence if it has as many sentences as the input.
+// Otherwise each run of uncached sentences is polished on its own, with the
+// polished sentences either side as reference.
+func polishIncremental(ctx context.Context, a adapter.LLMAdapter, c *segment.Cache, scope, text, system string, ref adapter.Reference) (string, segmentInfo, error) {
+	in := segment.Split(text)
+	out := make([]string, len(in))
+	hit := make([]bool, len(in))
+	info := segmentInfo{Total: len(in)}
+	for i, s := range in {
+		out[i], hit[i] = c.Get(segment.Key(scope, s.Text))
+		if hit[i] {
+			info.Reused++
+		}
+	}
+	metrics.SegmentCache.WithLabelValues("hit").Add(float64(info.Reused))
+	metrics.SegmentCache.WithLabelValues("miss").Add(float64(info.Total - info.Reused))
+
+	if info.Reused == 0 {
+		polished, err := a.Polish(ctx, text, system)
+		if err != nil {
+			return "", info, err
+		}
+		cacheSegments(c, scope, in, segment.Split(polished))
+		return polished, info, nil
+	}
+
+	if !strings.Contains(system, adapter.ReferenceInstructions) {
+		system += "\n\n" + adapter.ReferenceInstructions
+	}
+	var b strings.Builder
+	for i := 0; i < len(in); {
+		if hit[i] {
+			b.WriteString(out[i] + in[i].Sep)
+			i++
+			continue
+		}
+		j := i
+		for j < len(in) && !hit[j] {
+			j++
+		}
+		run := strings.TrimSpace(segment.Join(in[i:j]))
+		r := ref
+		if i > 0 {
+			r.Before = out[i-1]
+		}
+		if j < len(in) {
+			r.After = out[j]
+		}
+		polished, err := a.Polish(adapter.WithReference(ctx, r), run, system)
+		if err != nil {
+			return "", info, err
+		}
+		polished, _ = r.StripEcho(run, strings.TrimSpace(polished))
+		cacheSegments(c, scope, in[i:j], segment.Split(polished))
+		b.WriteString(polished + in[j-1].Sep)
+		i = j
+	}
+	return strings.TrimSpace(b.String()), info, nil
+}
+
+// minSegmentSimilarity is the word similarity every input/output sentence
+// pair needs before the output is cached; a polish keeps most words, while
+// a pair shifted by a merge and a split shares few.
+const minSegmentSimilarity = 0.5
+
+// cacheSegments stores each output sentence under its input sentence when
+// the two line up one to one; otherwise there is no telling which is which.
+// Equal counts are not enough, since a merge and a split cancel out, so
+// every pair must also be similar.
+func cacheSegments(c *segment.Cache, scope string, in, out []segment.Segment) {
+	if len(in) != len(out) {
+		return
+	}
+	for i := range in {
+		if diff.Similarity(in[i].Text, out[i].Text) < minSegmentSimilarity {
+			return
+		}
+	}
+	for i := range in {
+		c.Put(segment.Key(scope, in[i].Text), out[i].Text)
+	}
+}
+
+// assignVariant returns key's experiment variant if the request can take
+// part: a variant that sets a model applies only when the caller left the
+// choice to the router and the key may use that model.
+func assignVariant(m *experiment.Manager, rt *router.Router, key, modelID string) (experiment.Assignment, bool) {
+	a, ok := m.Assign(key)
+	if !ok || a.Model == "" || a.Model == modelID {
+		return a, ok
 	}
+	if modelID != "" && modelID != autoModel {
+		return experiment.Assignment{}, false
+	}
+	if rt != nil && !rt.Allowed(key, a.Model) {
+		return experiment.Assignment{}, false
+	}
+	return a, true
+}
+
+// classifyUpstreamError maps an adapter error to an HTTP status and error code.
+func classifyUpstreamError(err error) (int, apierror.Code) {
+	if errors.Is(err, redact.ErrConfidential) {
+		return http.StatusUnprocessableEntity, apierror.CodeConfidential
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return http.StatusGatewayTimeout, apierror.CodeUpstreamTimeout
+	}
+
+	var statusErr *adapter.StatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode == http.StatusTooManyRequests:
+			return http.StatusServiceUnavailable, apierror.CodeUpstreamUnavailable
+		case statusErr.StatusCode >= 400 && statusErr.StatusCode < 500:
+			return http.StatusBadGateway, apierror.CodeUpstreamRejected
+		default:
+			return http.StatusBadGateway, apierror.CodeUpstreamError
+		}
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return http.StatusServiceUnavailable, apierror.CodeUpstreamUnavailable
+	}
+
+	return http.StatusBadGateway, apierror.CodeUpstreamError
 }
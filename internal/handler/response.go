@@ -1,16 +1,17 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
+
+	"github.com/mlorentedev/pollex/internal/apierror"
+	"github.com/mlorentedev/pollex/internal/middleware"
 )
 
-type errorResponse struct {
-	Error string `json:"error"`
+func writeError(w http.ResponseWriter, r *http.Request, status int, code apierror.Code, msg string) {
+	writeAPIError(w, r, status, apierror.New(code, msg))
 }
 
-func writeError(w http.ResponseWriter, code int, msg string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(errorResponse{Error: msg})
+func writeAPIError(w http.ResponseWriter, r *http.Request, status int, e apierror.Error) {
+	e.RequestID = middleware.RequestIDFromContext(r.Context())
+	apierror.Write(w, status, e)
 }
@@ -0,0 +1,340 @@
+// Package history is an opt-in, append-only JSONL store of polish results,
+// scoped per API key name.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is one recorded polish.
+type Entry struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	Key        string    `json:"key"`
+	Model      string    `json:"model"`
+	Mode       string    `json:"mode"`
+	Input      string    `json:"input"`
+	Output     string    `json:"output"`
+	ElapsedMs  int64     `json:"elapsed_ms"`
+	Prompt     string    `json:"prompt,omitempty"` // system prompt version
+	Experiment string    `json:"experiment,omitempty"`
+	Variant    string    `json:"variant,omitempty"`
+	Feedback   *Feedback `json:"feedback,omitempty"`
+}
+
+// Ratings accepted in Feedback.Rating.
+const (
+	RatingAccepted = "accepted"
+	RatingRejected = "rejected"
+)
+
+// Feedback is the caller's verdict on an entry.
+type Feedback struct {
+	Time    time.Time `json:"time"`
+	Rating  string    `json:"rating"`
+	Final   string    `json:"final,omitempty"` // user-corrected text, if any
+	Comment string    `json:"comment,omitempty"`
+}
+
+// feedbackRecord is appended to the file when an entry is rated, and
+// folded into that entry on load, so rating doesn't rewrite the file.
+type feedbackRecord struct {
+	FeedbackFor string    `json:"feedback_for"`
+	Key         string    `json:"key"`
+	Feedback    *Feedback `json:"feedback"`
+}
+
+// ErrNotFound is returned when no entry matches the key and ID.
+var ErrNotFound = errors.New("history: entry not found")
+
+// Query selects entries for one key. Zero fields do not filter.
+type Query struct {
+	Key    string
+	Model  string
+	Since  time.Time
+	Until  time.Time
+	Search string // case-insensitive substring of input or output
+	Rated  bool   // only entries with feedback
+	Limit  int
+	Offset int
+}
+
+// Store keeps all live entries in memory, oldest first, and mirrors them in
+// a JSONL file. Appends and feedback are O(1); deletes rewrite the file, and
+// so do retention and feedback once the extra lines they leave reach a
+// tenth of the live entries.
+type Store struct {
+	mu         sync.Mutex
+	path       string
+	file       *os.File
+	entries    []Entry
+	dead       int // lines in the file beyond one per live entry
+	maxEntries int
+	maxAge     time.Duration
+	now        func() time.Time
+}
+
+// Open loads path (creating it if missing) and applies retention: at most
+// maxEntries entries no older than maxAge. Zero limits disable that check.
+func Open(path string, maxEntries int, maxAge time.Duration) (*Store, error) {
+	s := &Store{path: path, maxEntries: maxEntries, maxAge: maxAge, now: time.Now}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, fmt.Errorf("history: create dir: %w", err)
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	s.prune()
+	if err := s.rewrite(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("history: open: %w", err)
+	}
+	defer f.Close()
+
+	index := make(map[string]int) // key + "\x00" + ID -> position
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var rec struct {
+			Entry
+			FeedbackFor string `json:"feedback_for"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			// A torn final line after a crash is expected; skip it.
+			continue
+		}
+		if rec.FeedbackFor != "" {
+			if i, ok := index[rec.Key+"\x00"+rec.FeedbackFor]; ok {
+				s.entries[i].Feedback = rec.Feedback
+			}
+			continue
+		}
+		index[rec.Key+"\x00"+rec.ID] = len(s.entries)
+		s.entries = append(s.entries, rec.Entry)
+	}
+	return sc.Err()
+}
+
+// Add appends e and applies retention.
+func (s *Store) Add(e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("history: marshal: %w", err)
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("history: write: %w", err)
+	}
+	s.entries = append(s.entries, e)
+
+	// Compact lazily so steady-state appends don't rewrite the file.
+	before := len(s.entries)
+	s.prune()
+	s.dead += before - len(s.entries)
+	return s.compact()
+}
+
+// Get returns the entry with the given request ID if it belongs to key.
+func (s *Store) Get(key, id string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.entries {
+		if e.ID == id && e.Key == key {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Find returns matching entries newest first, and the total match count
+// before pagination.
+func (s *Store) Find(q Query) ([]Entry, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	search := strings.ToLower(q.Search)
+	var matched []Entry
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		e := s.entries[i]
+		switch {
+		case e.Key != q.Key:
+		case q.Model != "" && e.Model != q.Model:
+		case q.Rated && e.Feedback == nil:
+		case !q.Since.IsZero() && e.Time.Before(q.Since):
+		case !q.Until.IsZero() && !e.Time.Before(q.Until):
+		case search != "" && !strings.Contains(strings.ToLower(e.Input), search) &&
+			!strings.Contains(strings.ToLower(e.Output), search):
+		default:
+			matched = append(matched, e)
+		}
+	}
+
+	total := len(matched)
+	start := min(q.Offset, total)
+	end := total
+	if q.Limit > 0 {
+		end = min(start+q.Limit, total)
+	}
+	return matched[start:end], total
+}
+
+// Scan calls fn for every live entry of every key, oldest first. fn must not
+// call back into s.
+func (s *Store) Scan(fn func(Entry)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		fn(e)
+	}
+}
+
+// SetFeedback attaches fb to the entry with the given request ID if it
+// belongs to key, replacing any earlier feedback. replaced reports whether
+// there was earlier feedback.
+func (s *Store) SetFeedback(key, id string, fb Feedback) (e Entry, replaced bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.entries {
+		if s.entries[i].ID != id || s.entries[i].Key != key {
+			continue
+		}
+		line, err := json.Marshal(feedbackRecord{FeedbackFor: id, Key: key, Feedback: &fb})
+		if err != nil {
+			return Entry{}, false, fmt.Errorf("history: marshal: %w", err)
+		}
+		if _, err := s.file.Write(append(line, '\n')); err != nil {
+			return Entry{}, false, fmt.Errorf("history: write: %w", err)
+		}
+		replaced = s.entries[i].Feedback != nil
+		s.entries[i].Feedback = &fb
+		s.dead++
+		return s.entries[i], replaced, s.compact()
+	}
+	return Entry{}, false, ErrNotFound
+}
+
+// Delete removes the entry with the given request ID if it belongs to key.
+func (s *Store) Delete(key, id string) (bool, error) {
+	n, err := s.deleteWhere(func(e Entry) bool { return e.Key == key && e.ID == id })
+	return n > 0, err
+}
+
+// DeleteAll removes every entry belonging to key and returns how many.
+func (s *Store) DeleteAll(key string) (int, error) {
+	return s.deleteWhere(func(e Entry) bool { return e.Key == key })
+}
+
+func (s *Store) deleteWhere(match func(Entry) bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.entries[:0]
+	for _, e := range s.entries {
+		if !match(e) {
+			kept = append(kept, e)
+		}
+	}
+	n := len(s.entries) - len(kept)
+	s.entries = kept
+	if n == 0 {
+		return 0, nil
+	}
+	return n, s.rewrite()
+}
+
+// Close flushes and closes the underlying file.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// prune drops entries beyond the retention limits. Caller holds mu.
+func (s *Store) prune() {
+	if s.maxAge > 0 {
+		cutoff := s.now().Add(-s.maxAge)
+		i := 0
+		for i < len(s.entries) && s.entries[i].Time.Before(cutoff) {
+			i++
+		}
+		s.entries = s.entries[i:]
+	}
+	if s.maxEntries > 0 && len(s.entries) > s.maxEntries {
+		s.entries = s.entries[len(s.entries)-s.maxEntries:]
+	}
+}
+
+// compact rewrites the file once dead lines (pruned entries and feedback
+// records) reach a tenth of the live entries. Caller holds mu.
+func (s *Store) compact() error {
+	if s.dead == 0 || s.dead*10 < len(s.entries) {
+		return nil
+	}
+	return s.rewrite()
+}
+
+// rewrite atomically replaces the file with the live entries and reopens it
+// for appending. Caller holds mu (or owns s exclusively).
+func (s *Store) rewrite() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".history-*.jsonl")
+	if err != nil {
+		return fmt.Errorf("history: rewrite: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, e := range s.entries {
+		if err := enc.Encode(e); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return fmt.Errorf("history: rewrite: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("history: rewrite: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("history: rewrite: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return fmt.Errorf("history: rewrite: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("history: rewrite: %w", err)
+	}
+
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file, err = os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("history: reopen: %w", err)
+	}
+	s.dead = 0
+	return nil
+}
@@ -0,0 +1,234 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTemp(t *testing.T, maxEntries int, maxAge time.Duration) (*Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := Open(path, maxEntries, maxAge)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, path
+}
+
+func TestStoreAddFindPersist(t *testing.T) {
+	s, path := openTemp(t, 0, 0)
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	entries := []Entry{
+		{ID: "1", Time: base, Key: "alice", Model: "qwen", Input: "i goes", Output: "I go"},
+		{ID: "2", Time: base.Add(time.Hour), Key: "alice", Model: "claude", Input: "teh cat", Output: "The cat"},
+		{ID: "3", Time: base.Add(2 * time.Hour), Key: "bob", Model: "qwen", Input: "bob text", Output: "Bob text"},
+	}
+	for _, e := range entries {
+		if err := s.Add(e); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	got, total := s.Find(Query{Key: "alice"})
+	if total != 2 || got[0].ID != "2" || got[1].ID != "1" {
+		t.Errorf("alice: got %v (total %d), want [2 1] newest first", ids(got), total)
+	}
+
+	got, _ = s.Find(Query{Key: "alice", Model: "qwen"})
+	if len(got) != 1 || got[0].ID != "1" {
+		t.Errorf("model filter: got %v, want [1]", ids(got))
+	}
+
+	got, _ = s.Find(Query{Key: "alice", Search: "CAT"})
+	if len(got) != 1 || got[0].ID != "2" {
+		t.Errorf("search: got %v, want [2]", ids(got))
+	}
+
+	got, _ = s.Find(Query{Key: "alice", Since: base.Add(30 * time.Minute)})
+	if len(got) != 1 || got[0].ID != "2" {
+		t.Errorf("since: got %v, want [2]", ids(got))
+	}
+
+	got, total = s.Find(Query{Key: "alice", Limit: 1, Offset: 1})
+	if total != 2 || len(got) != 1 || got[0].ID != "1" {
+		t.Errorf("pagination: got %v (total %d), want [1] of 2", ids(got), total)
+	}
+
+	s.Close()
+	reopened, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	if _, total := reopened.Find(Query{Key: "bob"}); total != 1 {
+		t.Errorf("after reopen: bob has %d entries, want 1", total)
+	}
+}
+
+func TestStoreDeleteScopedToKey(t *testing.T) {
+	s, path := openTemp(t, 0, 0)
+	now := time.Now()
+	s.Add(Entry{ID: "a1", Time: now, Key: "alice"})
+	s.Add(Entry{ID: "a2", Time: now, Key: "alice"})
+	s.Add(Entry{ID: "b1", Time: now, Key: "bob"})
+
+	if ok, _ := s.Delete("bob", "a1"); ok {
+		t.Error("bob deleted alice's entry")
+	}
+	if ok, err := s.Delete("alice", "a1"); !ok || err != nil {
+		t.Errorf("Delete: got %v, %v", ok, err)
+	}
+	if n, _ := s.DeleteAll("alice"); n != 1 {
+		t.Errorf("DeleteAll: got %d, want 1", n)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "alice") {
+		t.Errorf("file still contains deleted entries:\n%s", data)
+	}
+	if _, total := s.Find(Query{Key: "bob"}); total != 1 {
+		t.Errorf("bob entries: got %d, want 1", total)
+	}
+}
+
+func TestStoreRetention(t *testing.T) {
+	s, _ := openTemp(t, 3, 24*time.Hour)
+	now := time.Now()
+
+	s.Add(Entry{ID: "old", Time: now.Add(-48 * time.Hour), Key: "k"})
+	for _, id := range []string{"1", "2", "3", "4"} {
+		s.Add(Entry{ID: id, Time: now, Key: "k"})
+	}
+
+	got, total := s.Find(Query{Key: "k"})
+	if total != 3 {
+		t.Fatalf("total: got %d, want 3", total)
+	}
+	if got[2].ID != "2" {
+		t.Errorf("oldest kept: got %q, want %q", got[2].ID, "2")
+	}
+}
+
+func TestStoreCompactsAfterRetention(t *testing.T) {
+	s, path := openTemp(t, 50, 0)
+	now := time.Now()
+	for i := range 300 {
+		if err := s.Add(Entry{ID: fmt.Sprint(i), Time: now, Key: "k", Input: "text"}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	if n := lineCount(t, path); n < 50 || n >= 55 {
+		t.Errorf("file has %d lines, want 50 live plus under 10%% dead", n)
+	}
+	if _, total := s.Find(Query{Key: "k"}); total != 50 {
+		t.Errorf("total: got %d, want 50", total)
+	}
+}
+
+func lineCount(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return strings.Count(string(data), "\n")
+}
+
+func TestStoreSkipsTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	os.WriteFile(path, []byte(`{"id":"1","key":"k","time":"2026-01-01T00:00:00Z"}`+"\n"+`{"id":"2","ke`), 0600)
+
+	s, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	if _, total := s.Find(Query{Key: "k"}); total != 1 {
+		t.Errorf("total: got %d, want 1", total)
+	}
+}
+
+func ids(entries []Entry) []string {
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func TestStoreSetFeedback(t *testing.T) {
+	s, path := openTemp(t, 0, 0)
+	now := time.Now().UTC()
+	s.Add(Entry{ID: "1", Time: now, Key: "alice", Input: "i goes", Output: "I goes"})
+	s.Add(Entry{ID: "2", Time: now, Key: "alice", Input: "teh", Output: "The"})
+
+	if _, _, err := s.SetFeedback("bob", "1", Feedback{Rating: RatingAccepted}); err != ErrNotFound {
+		t.Errorf("other key: got %v, want ErrNotFound", err)
+	}
+
+	_, replaced, err := s.SetFeedback("alice", "1", Feedback{Rating: RatingRejected, Final: "I go"})
+	if err != nil || replaced {
+		t.Fatalf("first feedback: replaced=%v err=%v", replaced, err)
+	}
+	e, replaced, err := s.SetFeedback("alice", "1", Feedback{Rating: RatingAccepted, Final: "I go."})
+	if err != nil || !replaced {
+		t.Fatalf("second feedback: replaced=%v err=%v", replaced, err)
+	}
+	if e.Feedback.Final != "I go." {
+		t.Errorf("final: got %q, want %q", e.Feedback.Final, "I go.")
+	}
+
+	got, total := s.Find(Query{Key: "alice", Rated: true})
+	if total != 1 || got[0].ID != "1" {
+		t.Errorf("rated filter: got %v, want [1]", ids(got))
+	}
+
+	s.Close()
+	reopened, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	if e, _ := reopened.Get("alice", "1"); e.Feedback == nil || e.Feedback.Rating != RatingAccepted {
+		t.Errorf("feedback not persisted: got %+v", e.Feedback)
+	}
+}
+
+func TestStoreFeedbackAppends(t *testing.T) {
+	s, path := openTemp(t, 0, 0)
+	now := time.Now().UTC()
+	for i := range 50 {
+		s.Add(Entry{ID: fmt.Sprint(i), Time: now, Key: "k", Input: "text"})
+	}
+	for _, rating := range []string{RatingAccepted, RatingRejected, RatingAccepted} {
+		if _, _, err := s.SetFeedback("k", "7", Feedback{Rating: rating}); err != nil {
+			t.Fatalf("SetFeedback: %v", err)
+		}
+	}
+	if n := lineCount(t, path); n != 53 {
+		t.Errorf("file has %d lines, want 50 entries + 3 feedback records", n)
+	}
+
+	s.Close()
+	reopened, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	if e, _ := reopened.Get("k", "7"); e.Feedback == nil || e.Feedback.Rating != RatingAccepted {
+		t.Errorf("feedback after reload: got %+v", e.Feedback)
+	}
+	if _, total := reopened.Find(Query{Key: "k"}); total != 50 {
+		t.Errorf("total: got %d, want 50", total)
+	}
+	if n := lineCount(t, path); n != 50 {
+		t.Errorf("after reopen the file has %d lines, want 50", n)
+	}
+}
@@ -0,0 +1,157 @@
+// Package injection spots text that tries to instruct the model instead of
+// being polished ("ignore previous instructions"), and outputs that leak
+// the system prompt. It is a heuristic: it catches the common phrasings in
+// English and Spanish, not a determined attacker.
+package injection
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/mlorentedev/pollex/internal/config"
+)
+
+// Actions for a flagged request.
+const (
+	ActionFlag     = "flag"     // serve it and report
+	ActionSanitize = "sanitize" // remove the matches (or leaked lines) first
+	ActionReject   = "reject"   // refuse it
+	ActionOff      = "off"      // no checks
+)
+
+// DefaultPatterns are always active. They are matched case-insensitively.
+var DefaultPatterns = []string{
+	`\b(ignore|disregard|forget|override)\s+(all\s+|any\s+)?(the\s+|your\s+|my\s+)?(previous|prior|above|earlier|preceding|system)\s+(instructions?|prompts?|rules|directions|messages?)`,
+	`\byou\s+are\s+now\s+(a|an|my|the|in)\b`,
+	`\b(reveal|print|show|repeat|output)\s+(me\s+)?(your|the)\s+(system\s+)?(prompt|instructions)`,
+	`\bnew\s+(system\s+)?instructions\s*:`,
+	`\bfrom\s+now\s+on,?\s+(you|respond|answer|reply)\b`,
+	`\b(do\s+not|don't)\s+(polish|correct|edit)\s+(this|the\s+text)\b`,
+	`<\|?(im_start|im_end|system|endoftext)\|?>`,
+	`(?m)^\s*#{2,}\s*(system|instruction)s?\b`,
+	`\b(ignora|olvida|omite)\s+(todas\s+)?(las\s+)?(instrucciones|indicaciones|órdenes)\s+(anteriores|previas)`,
+	`\b(muestra|revela|repite)\s+(tu|el)\s+(prompt|mensaje\s+del?\s+sistema|instrucciones)`,
+	`\ba\s+partir\s+de\s+ahora,?\s+(eres|responde|actúa)\b`,
+}
+
+// Finding is one pattern match. Start and End are byte offsets.
+type Finding struct {
+	Pattern    int // index into DefaultPatterns followed by the configured ones
+	Match      string
+	Start, End int
+}
+
+// Detector is safe for concurrent use.
+type Detector struct {
+	action   string
+	patterns []*regexp.Regexp
+}
+
+// New compiles DefaultPatterns plus cfg.Patterns. An empty action is
+// ActionFlag.
+func New(cfg config.Injection) (*Detector, error) {
+	d := &Detector{action: cfg.Action}
+	if d.action == "" {
+		d.action = ActionFlag
+	}
+	switch d.action {
+	case ActionFlag, ActionSanitize, ActionReject, ActionOff:
+	default:
+		return nil, fmt.Errorf("injection: unknown action %q", d.action)
+	}
+	for _, p := range append(DefaultPatterns[:len(DefaultPatterns):len(DefaultPatterns)], cfg.Patterns...) {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return nil, fmt.Errorf("injection: pattern %q: %w", p, err)
+		}
+		d.patterns = append(d.patterns, re)
+	}
+	return d, nil
+}
+
+// Action returns what to do with flagged requests.
+func (d *Detector) Action() string { return d.action }
+
+// Check returns every match in text, in pattern order.
+func (d *Detector) Check(text string) []Finding {
+	var out []Finding
+	for i, re := range d.patterns {
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			out = append(out, Finding{Pattern: i, Match: text[loc[0]:loc[1]], Start: loc[0], End: loc[1]})
+		}
+	}
+	return out
+}
+
+var spaces = regexp.MustCompile(`[ \t]{2,}`)
+
+// Sanitize removes every match from text, keeping its line breaks.
+func (d *Detector) Sanitize(text string) string {
+	for _, re := range d.patterns {
+		text = re.ReplaceAllString(text, "")
+	}
+	return strings.TrimSpace(spaces.ReplaceAllString(text, " "))
+}
+
+// shingle is the number of consecutive words that count as a leak: long
+// enough that an ordinary reply does not share it with the prompt by chance.
+const shingle = 8
+
+// Leak reports whether output repeats shingle consecutive words of system
+// that input does not, and returns output without the lines doing so.
+func Leak(system, input, output string) (string, bool) {
+	prompt := shingles(words(system))
+	if len(prompt) == 0 {
+		return output, false
+	}
+	own := shingles(words(input))
+
+	// Number the output's words by line, so a leak that spans several
+	// short lines removes all of them.
+	lines := strings.Split(output, "\n")
+	var ws []string
+	var lineOf []int
+	for i, line := range lines {
+		for _, w := range words(line) {
+			ws = append(ws, w)
+			lineOf = append(lineOf, i)
+		}
+	}
+	drop := make(map[int]bool)
+	for i := 0; i+shingle <= len(ws); i++ {
+		s := strings.Join(ws[i:i+shingle], " ")
+		if prompt[s] && !own[s] {
+			for j := i; j < i+shingle; j++ {
+				drop[lineOf[j]] = true
+			}
+		}
+	}
+	if len(drop) == 0 {
+		return output, false
+	}
+	var kept []string
+	for i, line := range lines {
+		if !drop[i] {
+			kept = append(kept, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n")), true
+}
+
+// words splits text into lower-cased words, ignoring punctuation.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// shingles returns the set of runs of shingle consecutive words.
+func shingles(ws []string) map[string]bool {
+	set := make(map[string]bool)
+	for i := 0; i+shingle <= len(ws); i++ {
+		set[strings.Join(ws[i:i+shingle], " ")] = true
+	}
+	return set
+}
@@ -0,0 +1,120 @@
+// Package lang tells English from Spanish offline, by counting function
+// words and Spanish orthography. It needs a sentence or so of evidence and
+// answers "unknown" rather than guess on less.
+package lang
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Supported language codes (ISO 639-1).
+const (
+	English = "en"
+	Spanish = "es"
+)
+
+var names = map[string]string{English: "English", Spanish: "Spanish"}
+
+// Words shared by both languages ("a", "no", "me", "he", "son") are left
+// out of both lists.
+var (
+	englishWords = set("the and of to is are was were be been have has had will would can could should " +
+		"this that these those with for from about it its you your we our they their she his her " +
+		"not but or if then than which who what when where how there here at on in an by as do does did " +
+		"just also i my please thanks thank hi hello so all any some more very")
+	spanishWords = set("el la los las de del que y en es está están estamos por para con una un uno lo le les " +
+		"se su sus al pero como más muy ya también este esta esto estos estas ese esa eso hay ser fue era " +
+		"tiene tengo hemos ha han sin sobre entre cuando donde porque pues nos nuestro nuestra usted ustedes " +
+		"hola gracias saludos si sí mi tu yo él ella qué cómo cuál puedes puede podemos favor bien todo todos " +
+		"hasta desde mañana hoy")
+)
+
+func set(words string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		m[w] = true
+	}
+	return m
+}
+
+// minEvidence is the score the winning language needs; dominance is how
+// many times the loser's score it must reach.
+const (
+	minEvidence = 2
+	dominance   = 1.5
+)
+
+// Detect returns English, Spanish, or "" if text is too short or too mixed
+// to tell.
+func Detect(text string) string {
+	var en, es float64
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\'' && r != '’'
+	})
+	for _, w := range words {
+		w = strings.Trim(strings.ReplaceAll(w, "’", "'"), "'")
+		if isContraction(w) {
+			en++
+			continue
+		}
+		if englishWords[w] {
+			en++
+		}
+		if spanishWords[w] {
+			es++
+		}
+	}
+	for _, r := range text {
+		switch r {
+		case 'ñ', 'Ñ', '¿', '¡':
+			es++
+		case 'á', 'é', 'í', 'ó', 'ú', 'Á', 'É', 'Í', 'Ó', 'Ú':
+			// Loanwords such as café also occur in English.
+			es += 0.5
+		}
+	}
+
+	switch {
+	case en >= minEvidence && en >= dominance*es:
+		return English
+	case es >= minEvidence && es >= dominance*en:
+		return Spanish
+	}
+	return ""
+}
+
+// isContraction reports English contractions such as don't and we're.
+func isContraction(w string) bool {
+	for _, suffix := range []string{"n't", "'s", "'re", "'ll", "'ve", "'m", "'d"} {
+		if len(w) > len(suffix) && strings.HasSuffix(w, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Name returns the English name of a supported code, or "" for others.
+func Name(code string) string { return names[code] }
+
+// Code returns the code for a supported code or English name, matched
+// case-insensitively ("es", "Spanish"), or "" for others.
+func Code(s string) string {
+	for code, name := range names {
+		if strings.EqualFold(s, code) || strings.EqualFold(s, name) {
+			return code
+		}
+	}
+	return ""
+}
+
+// Supported returns the supported codes, sorted.
+func Supported() []string {
+	codes := make([]string, 0, len(names))
+	for c := range names {
+		codes = append(codes, c)
+	}
+	slices.Sort(codes)
+	return codes
+}
@@ -0,0 +1,41 @@
+package lang
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+	}{
+		{"english", "Hi team, I think we should ship the fix on Friday.", English},
+		{"english contractions", "Don't worry, we're nearly done", English},
+		{"spanish", "Hola equipo, creo que podemos desplegar el arreglo el viernes.", Spanish},
+		{"spanish orthography", "¿Revisaste la configuración?", Spanish},
+		{"spanish with english terms", "El deploy del backend falla en staging por el timeout.", Spanish},
+		{"english with loanword", "The café on the corner is closed this week.", English},
+		{"too short", "ok", ""},
+		{"no function words", "Kubernetes Docker Grafana", ""},
+		{"mixed", "the fix and el deploy y", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.text); got != tt.want {
+				t.Errorf("Detect(%q): got %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNames(t *testing.T) {
+	if !slices.Equal(Supported(), []string{English, Spanish}) {
+		t.Errorf("supported: got %v", Supported())
+	}
+	if Name(Spanish) != "Spanish" || Name("fr") != "" {
+		t.Errorf("names: got %q %q", Name(Spanish), Name("fr"))
+	}
+	if Code("SPANISH") != Spanish || Code("en") != English || Code("French") != "" {
+		t.Errorf("codes: got %q %q %q", Code("SPANISH"), Code("en"), Code("French"))
+	}
+}
@@ -0,0 +1,229 @@
+// Package lint checks English prose against deterministic style rules:
+// banned words, passive voice, sentence length and repeated words, plus
+// Flesch readability scores. It needs no model, so it works when every
+// backend is down.
+package lint
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mlorentedev/pollex/internal/diff"
+)
+
+// Rules reported in Finding.Rule.
+const (
+	RuleBannedWord   = "banned_word"
+	RulePassiveVoice = "passive_voice"
+	RuleLongSentence = "long_sentence"
+	RuleRepeatedWord = "repeated_word"
+)
+
+// Severities reported in Finding.Severity. Hints are stylistic and often
+// fine; warnings are almost always worth fixing.
+const (
+	SeverityWarning = "warning"
+	SeverityHint    = "hint"
+)
+
+// DefaultBannedWords are the AI-isms prompts/polish.txt tells the model to
+// avoid. Inflections ("delves", "leveraging") match too.
+var DefaultBannedWords = []string{
+	"delve", "leverage", "utilize", "utilise", "facilitate", "seamless",
+	"tapestry", "testament", "embark", "realm", "plethora", "synergy",
+	"furthermore", "moreover", "navigate the complexities",
+}
+
+// DefaultMaxSentenceWords is the sentence length above which a
+// long_sentence hint is reported.
+const DefaultMaxSentenceWords = 30
+
+// Finding is one rule violation. Start and End are offsets in Unicode code
+// points, End exclusive.
+type Finding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+// Stats describes the text as a whole. Readability scores are zero for
+// text without words.
+type Stats struct {
+	Words              int     `json:"words"`
+	Sentences          int     `json:"sentences"`
+	FleschReadingEase  float64 `json:"flesch_reading_ease"`  // higher is easier; 60–70 is plain English
+	FleschKincaidGrade float64 `json:"flesch_kincaid_grade"` // US school grade
+}
+
+// Report is the result of Check. Findings are ordered by Start.
+type Report struct {
+	Findings []Finding `json:"findings"`
+	Stats    Stats     `json:"stats"`
+}
+
+// Options configures a Linter.
+type Options struct {
+	BannedWords      []string // added to DefaultBannedWords
+	MaxSentenceWords int      // 0 means DefaultMaxSentenceWords
+}
+
+// Linter is safe for concurrent use.
+type Linter struct {
+	banned      []*regexp.Regexp
+	maxSentence int
+}
+
+// New compiles opts into a Linter.
+func New(opts Options) *Linter {
+	l := &Linter{maxSentence: opts.MaxSentenceWords}
+	if l.maxSentence <= 0 {
+		l.maxSentence = DefaultMaxSentenceWords
+	}
+	seen := make(map[string]bool)
+	for _, w := range append(append([]string{}, DefaultBannedWords...), opts.BannedWords...) {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if w == "" || seen[w] {
+			continue
+		}
+		seen[w] = true
+		l.banned = append(l.banned, regexp.MustCompile(`(?i)\b`+inflect(w)+`\b`))
+	}
+	return l
+}
+
+// inflect returns a pattern matching phrase with its last word inflected:
+// "delve" also matches "delves", "delved" and "delving".
+func inflect(phrase string) string {
+	head, last := "", phrase
+	if i := strings.LastIndexByte(phrase, ' '); i >= 0 {
+		head, last = phrase[:i+1], phrase[i+1:]
+	}
+	head = strings.ReplaceAll(regexp.QuoteMeta(head), " ", `\s+`)
+	if base, ok := strings.CutSuffix(last, "e"); ok {
+		return head + regexp.QuoteMeta(base) + `(?:e|es|ed|ing)`
+	}
+	return head + regexp.QuoteMeta(last) + `(?:s|es|ed|ing)?`
+}
+
+var (
+	wordRe = regexp.MustCompile(`[\p{L}\p{N}]+(?:['’][\p{L}]+)*`)
+	// A sentence ends at terminal punctuation followed by space, or a newline.
+	sentenceEndRe = regexp.MustCompile(`[.!?]+(?:["'”’)]*)(?:\s+|$)|\n+`)
+	passiveRe     = regexp.MustCompile(`(?i)\b(?:am|is|are|was|were|be|been|being)\s+(?:\w+ly\s+)?(?:\w{2,}ed|` +
+		`written|done|made|given|taken|seen|known|shown|built|sent|found|held|kept|told|thought|brought|` +
+		`bought|chosen|driven|forgotten|hidden|paid|said|sold|spent|won|begun|broken|spoken|stolen|understood)\b`)
+)
+
+// Check lints text.
+func (l *Linter) Check(text string) Report {
+	var findings []Finding
+	add := func(rule, severity, msg string, start, end int) {
+		findings = append(findings, Finding{Rule: rule, Severity: severity, Message: msg, Start: start, End: end})
+	}
+
+	for _, re := range l.banned {
+		for _, m := range re.FindAllStringIndex(text, -1) {
+			add(RuleBannedWord, SeverityWarning, fmt.Sprintf("avoid %q", text[m[0]:m[1]]), m[0], m[1])
+		}
+	}
+	for _, m := range passiveRe.FindAllStringIndex(text, -1) {
+		add(RulePassiveVoice, SeverityHint, fmt.Sprintf("possible passive voice: %q", text[m[0]:m[1]]), m[0], m[1])
+	}
+
+	words := wordRe.FindAllStringIndex(text, -1)
+	for i := 1; i < len(words); i++ {
+		prev, cur := words[i-1], words[i]
+		if strings.TrimSpace(text[prev[1]:cur[0]]) == "" &&
+			strings.EqualFold(text[prev[0]:prev[1]], text[cur[0]:cur[1]]) {
+			add(RuleRepeatedWord, SeverityWarning, fmt.Sprintf("repeated word %q", text[cur[0]:cur[1]]), prev[0], cur[1])
+		}
+	}
+
+	sentences := 0
+	start, w := 0, 0
+	for _, end := range sentenceEnds(text) {
+		n := 0
+		for w < len(words) && words[w][0] < end {
+			n++
+			w++
+		}
+		if n > 0 {
+			sentences++
+			if n > l.maxSentence {
+				s, e := trimSpan(text, start, end)
+				add(RuleLongSentence, SeverityHint, fmt.Sprintf("sentence has %d words (max %d)", n, l.maxSentence), s, e)
+			}
+		}
+		start = end
+	}
+
+	slices.SortStableFunc(findings, func(a, b Finding) int { return a.Start - b.Start })
+	diff.RuneOffsets(text, findings, findingSpan)
+	return Report{Findings: diff.NonNil(findings), Stats: stats(text, words, sentences)}
+}
+
+// sentenceEnds returns the byte offset just past each sentence, the last
+// one being len(text).
+func sentenceEnds(text string) []int {
+	var ends []int
+	for _, m := range sentenceEndRe.FindAllStringIndex(text, -1) {
+		ends = append(ends, m[1])
+	}
+	if len(ends) == 0 || ends[len(ends)-1] != len(text) {
+		ends = append(ends, len(text))
+	}
+	return ends
+}
+
+// trimSpan shrinks [start, end) to exclude surrounding whitespace.
+func trimSpan(text string, start, end int) (int, int) {
+	s := text[start:end]
+	start += len(s) - len(strings.TrimLeft(s, " \t\r\n"))
+	end -= len(s) - len(strings.TrimRight(s, " \t\r\n"))
+	return start, end
+}
+
+func stats(text string, words [][]int, sentences int) Stats {
+	st := Stats{Words: len(words), Sentences: sentences}
+	if st.Words == 0 {
+		return st
+	}
+	syllables := 0
+	for _, m := range words {
+		syllables += countSyllables(text[m[0]:m[1]])
+	}
+	wps := float64(st.Words) / float64(st.Sentences)
+	spw := float64(syllables) / float64(st.Words)
+	st.FleschReadingEase = round1(206.835 - 1.015*wps - 84.6*spw)
+	st.FleschKincaidGrade = round1(0.39*wps + 11.8*spw - 15.59)
+	return st
+}
+
+// countSyllables estimates syllables as vowel groups, discounting a silent
+// final "e". Every word has at least one.
+func countSyllables(word string) int {
+	word = strings.ToLower(word)
+	n, inVowel := 0, false
+	for _, r := range word {
+		v := strings.ContainsRune("aeiouy", r)
+		if v && !inVowel {
+			n++
+		}
+		inVowel = v
+	}
+	if n > 1 && strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") {
+		n--
+	}
+	return max(n, 1)
+}
+
+func round1(f float64) float64 {
+	return math.Round(f*10) / 10
+}
+
+func findingSpan(f *Finding) (*int, *int) { return &f.Start, &f.End }
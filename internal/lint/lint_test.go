@@ -0,0 +1,84 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+)
+
+func rules(r Report) []string {
+	var out []string
+	for _, f := range r.Findings {
+		out = append(out, f.Rule)
+	}
+	return out
+}
+
+func TestCheckRules(t *testing.T) {
+	l := New(Options{BannedWords: []string{"circle back"}, MaxSentenceWords: 8})
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"clean", "We shipped the fix on Monday.", nil},
+		{"banned word", "Let's delve into it.", []string{RuleBannedWord}},
+		{"banned inflection", "We are leveraging caches.", []string{RuleBannedWord}},
+		{"banned phrase from options", "We should circle  back later.", []string{RuleBannedWord}},
+		{"banned inside word ignored", "The realms... no, realmstone.", []string{RuleBannedWord}},
+		{"passive", "The report was written by Ana.", []string{RulePassiveVoice}},
+		{"passive with adverb", "It was quickly fixed.", []string{RulePassiveVoice}},
+		{"repeated word", "This is the the plan.", []string{RuleRepeatedWord}},
+		{"repeated across punctuation ignored", "Done. Done.", nil},
+		{"long sentence", "One two three four five six seven eight nine.", []string{RuleLongSentence}},
+		{"newline splits sentences", "one two three four five\nsix seven eight nine", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rules(l.Check(tt.text))
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Check(%q): got %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOffsets(t *testing.T) {
+	// Offsets count code points, so the accented name doesn't shift them.
+	text := "José will utilize it."
+	r := New(Options{}).Check(text)
+	if len(r.Findings) != 1 {
+		t.Fatalf("findings: got %+v", r.Findings)
+	}
+	f := r.Findings[0]
+	if got := string([]rune(text)[f.Start:f.End]); got != "utilize" {
+		t.Errorf("span: got %q (%d-%d)", got, f.Start, f.End)
+	}
+	if f.Severity != SeverityWarning {
+		t.Errorf("severity: got %q", f.Severity)
+	}
+}
+
+func TestCheckStats(t *testing.T) {
+	r := New(Options{}).Check("The cat sat on the mat. It was happy.")
+	if r.Stats.Words != 9 || r.Stats.Sentences != 2 {
+		t.Errorf("counts: got %+v", r.Stats)
+	}
+	// Short words in short sentences read as very easy.
+	if r.Stats.FleschReadingEase < 90 || r.Stats.FleschKincaidGrade > 3 {
+		t.Errorf("scores: got %+v", r.Stats)
+	}
+
+	empty := New(Options{}).Check("  ")
+	if empty.Findings == nil || empty.Stats != (Stats{}) {
+		t.Errorf("empty: got %+v", empty)
+	}
+}
+
+func TestCountSyllables(t *testing.T) {
+	for word, want := range map[string]int{"cat": 1, "make": 1, "table": 2, "polish": 2, "readability": 5, "rhythm": 1, "42": 1} {
+		if got := countSyllables(word); got != want {
+			t.Errorf("countSyllables(%q): got %d, want %d", word, got, want)
+		}
+	}
+}
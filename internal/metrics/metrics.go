@@ -19,6 +19,20 @@ var (
 		Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120},
 	}, []string{"model"})
 
+	// PolishErrors counts failed polish calls by model and error code.
+	// Client cancellations are not counted.
+	PolishErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pollex_polish_errors_total",
+		Help: "Polish calls that failed, by model and error code.",
+	}, []string{"model", "code"})
+
+	// FeedbackTotal counts first-time feedback on polishes by model, system
+	// prompt version and rating. Acceptance rate is accepted / total.
+	FeedbackTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pollex_feedback_total",
+		Help: "Feedback received on polishes, by model, prompt version and rating.",
+	}, []string{"model", "prompt", "rating"})
+
 	// InputChars tracks the distribution of input text lengths.
 	InputChars = promauto.NewHistogram(prometheus.HistogramOpts{
 		Name:    "pollex_input_chars",
@@ -26,6 +40,124 @@ var (
 		Buckets: []float64{50, 100, 250, 500, 1000, 2500, 5000, 10000},
 	})
 
+	// EndpointAvailable tracks each pooled backend endpoint's last probe.
+	EndpointAvailable = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pollex_endpoint_available",
+		Help: "Whether a pooled backend endpoint passed its last probe (1) or not (0).",
+	}, []string{"model", "endpoint"})
+
+	// EndpointInFlight tracks requests currently running on each endpoint.
+	EndpointInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pollex_endpoint_in_flight",
+		Help: "Polish requests in flight per pooled backend endpoint.",
+	}, []string{"model", "endpoint"})
+
+	// EndpointRequests counts pooled requests by endpoint and result
+	// (ok, error, client_error).
+	EndpointRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pollex_endpoint_requests_total",
+		Help: "Polish requests per pooled backend endpoint, by result.",
+	}, []string{"model", "endpoint", "result"})
+
+	// EndpointEjections counts passive ejections after consecutive errors.
+	EndpointEjections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pollex_endpoint_ejections_total",
+		Help: "Times a pooled backend endpoint was ejected after consecutive errors.",
+	}, []string{"model", "endpoint"})
+
+	// WarmupDuration tracks warmup generations per model and reason
+	// (startup, recovered, keep_warm).
+	WarmupDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pollex_warmup_duration_seconds",
+		Help:    "Time spent on warmup generations.",
+		Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120},
+	}, []string{"model", "reason"})
+
+	// ShadowRequests counts mirrored requests per candidate by result
+	// (ok, error, dropped).
+	ShadowRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pollex_shadow_requests_total",
+		Help: "Polish requests mirrored to a shadow model, by result.",
+	}, []string{"shadow", "result"})
+
+	// ShadowDuration tracks the candidate's latency on mirrored requests, with
+	// the same buckets as PolishDuration for side-by-side panels.
+	ShadowDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pollex_shadow_duration_seconds",
+		Help:    "Shadow model inference latency.",
+		Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120},
+	}, []string{"shadow"})
+
+	// ShadowSimilarity tracks word-level similarity between the primary and
+	// shadow outputs (1 means identical).
+	ShadowSimilarity = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pollex_shadow_similarity",
+		Help:    "Word-level similarity of shadow output to the primary output.",
+		Buckets: []float64{0.5, 0.6, 0.7, 0.8, 0.9, 0.95, 0.99, 1},
+	}, []string{"shadow"})
+
+	// ExperimentDuration tracks polish latency per experiment variant; its
+	// count is the number of requests each variant served.
+	ExperimentDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pollex_experiment_duration_seconds",
+		Help:    "Polish latency of requests assigned to an experiment variant.",
+		Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120},
+	}, []string{"experiment", "variant"})
+
+	// ExperimentFeedback counts first ratings per experiment variant.
+	ExperimentFeedback = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pollex_experiment_feedback_total",
+		Help: "Feedback ratings of polishes assigned to an experiment variant.",
+	}, []string{"experiment", "variant", "rating"})
+
+	// GlossaryViolations counts glossary terms dropped from polished output,
+	// per model, to spot models that ignore the glossary.
+	GlossaryViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pollex_glossary_violations_total",
+		Help: "Glossary terms present in the input but missing from the polished output.",
+	}, []string{"model"})
+
+	// ExplainFallbacks counts explain-mode polishes whose edits had to be
+	// computed because the model's JSON was unusable, per model.
+	ExplainFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pollex_explain_fallbacks_total",
+		Help: "Explain-mode polishes answered with computed edits instead of the model's.",
+	}, []string{"model"})
+
+	// ReferenceEchoes counts outputs that repeated the request's reference
+	// context and had it stripped, per model.
+	ReferenceEchoes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pollex_reference_echoes_total",
+		Help: "Polished outputs from which an echoed reference context was removed.",
+	}, []string{"model"})
+
+	// LanguageMismatches counts polishes whose output was detected in another
+	// language than requested, per model.
+	LanguageMismatches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pollex_language_mismatches_total",
+		Help: "Polished outputs detected in a different language than requested.",
+	}, []string{"model"})
+
+	// PromptInjections counts prompt injection detections by stage (input,
+	// output) and the action taken.
+	PromptInjections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pollex_prompt_injections_total",
+		Help: "Polish requests flagged for prompt injection, by stage and action.",
+	}, []string{"stage", "action"})
+
+	// SegmentCache counts sentence lookups of incremental polishes by
+	// result (hit, miss).
+	SegmentCache = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pollex_segment_cache_total",
+		Help: "Sentence cache lookups of incremental polishes, by result.",
+	}, []string{"result"})
+
+	// SupervisorRestarts counts restarts of the supervised llama-server.
+	SupervisorRestarts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pollex_supervisor_restarts_total",
+		Help: "Times the supervised llama-server was restarted after a crash or hang.",
+	})
+
 	// AdapterAvailable tracks whether each adapter is reachable.
 	AdapterAvailable = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "pollex_adapter_available",
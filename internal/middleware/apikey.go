@@ -1,43 +1,88 @@
 package middleware
 
 import (
+	"context"
 	"crypto/subtle"
-	"encoding/json"
 	"net/http"
+
+	"github.com/mlorentedev/pollex/internal/apierror"
 )
 
+const keyNameKey contextKey = "keyName"
+
+// AnonymousKey is the key name recorded when auth is disabled.
+const AnonymousKey = "anonymous"
+
+// publicPaths are served without an API key: health, readiness and metrics so
+// monitoring works without credentials, and the OpenAPI document so clients
+// can bootstrap.
+var publicPaths = map[string]bool{
+	"/api/health":       true,
+	"/api/v1/health":    true,
+	"/api/ready":        true,
+	"/api/v1/ready":     true,
+	"/api/openapi.json": true,
+	"/metrics":          true,
+}
+
 // APIKey returns middleware that requires a valid X-API-Key header.
 // If expectedKey is empty, the middleware is a no-op (backward compatible).
-// /api/health is exempt so monitoring works without credentials.
 func APIKey(expectedKey string) func(http.Handler) http.Handler {
+	keys := map[string]string{}
+	if expectedKey != "" {
+		keys["default"] = expectedKey
+	}
+	return APIKeys(keys)
+}
+
+// APIKeys is APIKey for several named keys. The name of the matching key is
+// stored in the request context (see KeyNameFromContext). If keys is empty,
+// auth is disabled and every request is attributed to AnonymousKey.
+func APIKeys(keys map[string]string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if expectedKey == "" {
-				next.ServeHTTP(w, r)
+			if len(keys) == 0 {
+				next.ServeHTTP(w, withKeyName(r, AnonymousKey))
 				return
 			}
 
-			if r.URL.Path == "/api/health" || r.URL.Path == "/metrics" {
+			if publicPaths[r.URL.Path] {
 				next.ServeHTTP(w, r)
 				return
 			}
 
 			provided := r.Header.Get("X-API-Key")
 			if provided == "" {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusUnauthorized)
-				json.NewEncoder(w).Encode(map[string]string{"error": "missing API key"})
+				writeError(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "missing API key")
 				return
 			}
 
-			if subtle.ConstantTimeCompare([]byte(provided), []byte(expectedKey)) != 1 {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusUnauthorized)
-				json.NewEncoder(w).Encode(map[string]string{"error": "invalid API key"})
+			// Compare against every key so timing doesn't reveal which matched.
+			matched := ""
+			for name, key := range keys {
+				if subtle.ConstantTimeCompare([]byte(provided), []byte(key)) == 1 {
+					matched = name
+				}
+			}
+			if matched == "" {
+				writeError(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "invalid API key")
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, withKeyName(r, matched))
 		})
 	}
 }
+
+func withKeyName(r *http.Request, name string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), keyNameKey, name))
+}
+
+// KeyNameFromContext returns the name of the API key that authenticated the
+// request, AnonymousKey when auth is disabled, or "" on public paths.
+func KeyNameFromContext(ctx context.Context) string {
+	if name, ok := ctx.Value(keyNameKey).(string); ok {
+		return name
+	}
+	return ""
+}
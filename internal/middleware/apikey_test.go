@@ -45,10 +45,13 @@ func TestAPIKeyMiddleware(t *testing.T) {
 			t.Errorf("status: got %d, want %d", w.Code, http.StatusUnauthorized)
 		}
 
-		var body map[string]string
+		var body map[string]any
 		json.NewDecoder(w.Body).Decode(&body)
-		if body["error"] != "missing API key" {
-			t.Errorf("error: got %q, want %q", body["error"], "missing API key")
+		if body["code"] != "unauthorized" {
+			t.Errorf("code: got %v, want %q", body["code"], "unauthorized")
+		}
+		if body["message"] != "missing API key" {
+			t.Errorf("message: got %v, want %q", body["message"], "missing API key")
 		}
 	})
 
@@ -63,10 +66,13 @@ func TestAPIKeyMiddleware(t *testing.T) {
 			t.Errorf("status: got %d, want %d", w.Code, http.StatusUnauthorized)
 		}
 
-		var body map[string]string
+		var body map[string]any
 		json.NewDecoder(w.Body).Decode(&body)
-		if body["error"] != "invalid API key" {
-			t.Errorf("error: got %q, want %q", body["error"], "invalid API key")
+		if body["code"] != "unauthorized" {
+			t.Errorf("code: got %v, want %q", body["code"], "unauthorized")
+		}
+		if body["message"] != "invalid API key" {
+			t.Errorf("message: got %v, want %q", body["message"], "invalid API key")
 		}
 	})
 
@@ -81,6 +87,19 @@ func TestAPIKeyMiddleware(t *testing.T) {
 		}
 	})
 
+	t.Run("versioned health and openapi exempt", func(t *testing.T) {
+		handler := APIKey("secret-123")(inner)
+		for _, path := range []string{"/api/v1/health", "/api/openapi.json"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Errorf("%s status: got %d, want %d", path, w.Code, http.StatusOK)
+			}
+		}
+	})
+
 	t.Run("metrics endpoint exempt", func(t *testing.T) {
 		handler := APIKey("secret-123")(inner)
 		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
@@ -103,3 +122,37 @@ func TestAPIKeyMiddleware(t *testing.T) {
 		}
 	})
 }
+
+func TestAPIKeysNamed(t *testing.T) {
+	var gotName string
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotName = KeyNameFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("matching key name in context", func(t *testing.T) {
+		handler := APIKeys(map[string]string{"alice": "k-alice", "bob": "k-bob"})(inner)
+		req := httptest.NewRequest(http.MethodPost, "/api/polish", nil)
+		req.Header.Set("X-API-Key", "k-bob")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status: got %d, want %d", w.Code, http.StatusOK)
+		}
+		if gotName != "bob" {
+			t.Errorf("key name: got %q, want %q", gotName, "bob")
+		}
+	})
+
+	t.Run("anonymous when disabled", func(t *testing.T) {
+		handler := APIKeys(nil)(inner)
+		req := httptest.NewRequest(http.MethodPost, "/api/polish", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if gotName != AnonymousKey {
+			t.Errorf("key name: got %q, want %q", gotName, AnonymousKey)
+		}
+	})
+}
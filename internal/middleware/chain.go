@@ -9,12 +9,13 @@ import (
 // Order: CORS → RequestID → Logging → Metrics → APIKey → RateLimit → MaxBytes → Timeout → mux
 // APIKey runs before RateLimit so that: (1) invalid keys are rejected without
 // consuming rate limit budget, and (2) authenticated requests skip rate limiting.
-func Chain(handler http.Handler, rl *RateLimiter, apiKey string) http.Handler {
+// Timeout only sets the context deadline; handlers map expiry to 504.
+func Chain(handler http.Handler, rl *RateLimiter, keys map[string]string, timeout time.Duration) http.Handler {
 	h := handler
-	h = http.TimeoutHandler(h, 120*time.Second, `{"error":"request timeout"}`)
+	h = Timeout(timeout)(h)
 	h = MaxBytes(64 * 1024)(h)
 	h = RateLimit(rl)(h)
-	h = APIKey(apiKey)(h)
+	h = APIKeys(keys)(h)
 	h = Metrics(h)
 	h = Logging(h)
 	h = RequestID(h)
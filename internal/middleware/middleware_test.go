@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestCORSMiddleware(t *testing.T) {
@@ -23,8 +24,8 @@ func TestCORSMiddleware(t *testing.T) {
 		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
 			t.Errorf("Allow-Origin: got %q, want %q", got, "*")
 		}
-		if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST, OPTIONS" {
-			t.Errorf("Allow-Methods: got %q, want %q", got, "GET, POST, OPTIONS")
+		if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST, DELETE, OPTIONS" {
+			t.Errorf("Allow-Methods: got %q, want %q", got, "GET, POST, DELETE, OPTIONS")
 		}
 		if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, X-API-Key" {
 			t.Errorf("Allow-Headers: got %q, want %q", got, "Content-Type, X-API-Key")
@@ -156,3 +157,25 @@ func TestMaxBytesMiddleware(t *testing.T) {
 		}
 	})
 }
+
+func TestTimeoutMiddleware(t *testing.T) {
+	var deadline time.Time
+	var ok bool
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+		w.WriteHeader(http.StatusOK)
+	})
+
+	start := time.Now()
+	handler := Timeout(30 * time.Second)(inner)
+	req := httptest.NewRequest(http.MethodPost, "/api/polish", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if !ok {
+		t.Fatal("context has no deadline")
+	}
+	if d := deadline.Sub(start); d < 29*time.Second || d > 31*time.Second {
+		t.Errorf("deadline: got %v from start, want ~30s", d)
+	}
+}
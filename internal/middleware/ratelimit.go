@@ -1,11 +1,13 @@
 package middleware
 
 import (
-	"encoding/json"
+	"context"
 	"net"
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/mlorentedev/pollex/internal/apierror"
 )
 
 // RateLimiter tracks requests per IP using a sliding window.
@@ -25,6 +27,11 @@ func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
 }
 
 func (rl *RateLimiter) Allow(key string) bool {
+	return rl.AllowN(key, 1)
+}
+
+// AllowN counts n requests for key if all of them fit in the window.
+func (rl *RateLimiter) AllowN(key string, n int) bool {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
@@ -39,15 +46,28 @@ func (rl *RateLimiter) Allow(key string) bool {
 		}
 	}
 
-	if len(valid) >= rl.limit {
+	if len(valid)+n > rl.limit {
 		rl.requests[key] = valid
 		return false
 	}
 
-	rl.requests[key] = append(valid, now)
+	for range n {
+		valid = append(valid, now)
+	}
+	rl.requests[key] = valid
 	return true
 }
 
+type chargeKey struct{}
+
+// Charge counts n more requests against the caller's rate limit, for
+// handlers whose request does the work of several. It reports false if
+// that exceeds the limit, and true for callers that are not rate limited.
+func Charge(ctx context.Context, n int) bool {
+	charge, ok := ctx.Value(chargeKey{}).(func(int) bool)
+	return !ok || n <= 0 || charge(n)
+}
+
 // RateLimit rejects requests exceeding the per-IP limit with 429.
 // Authenticated requests (with X-API-Key header) bypass rate limiting —
 // by the time a request reaches this middleware, APIKey has already validated it.
@@ -64,12 +84,11 @@ func RateLimit(rl *RateLimiter) func(http.Handler) http.Handler {
 			}
 			ip := clientIP(r)
 			if !rl.Allow(ip) {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusTooManyRequests)
-				json.NewEncoder(w).Encode(map[string]string{"error": "rate limit exceeded"})
+				writeError(w, r, http.StatusTooManyRequests, apierror.CodeRateLimited, "rate limit exceeded")
 				return
 			}
-			next.ServeHTTP(w, r)
+			charge := func(n int) bool { return rl.AllowN(ip, n) }
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), chargeKey{}, charge)))
 		})
 	}
 }
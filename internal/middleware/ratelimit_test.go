@@ -124,3 +124,25 @@ func TestRateLimiterWindowExpiry(t *testing.T) {
 		t.Error("request after window should be allowed")
 	}
 }
+
+func TestRateLimitCharge(t *testing.T) {
+	var charged []bool
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		charged = append(charged, Charge(r.Context(), 2))
+	})
+	handler := RateLimit(NewRateLimiter(4, time.Minute))(inner)
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "1.2.3.4:1234"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	// 1+2 fit in 4; the second request fits but its extra 2 don't.
+	if len(charged) != 2 || !charged[0] || charged[1] {
+		t.Errorf("charged: got %v, want [true false]", charged)
+	}
+
+	if !Charge(httptest.NewRequest(http.MethodGet, "/", nil).Context(), 5) {
+		t.Error("Charge without a limiter: got false, want true")
+	}
+}
@@ -0,0 +1,13 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/mlorentedev/pollex/internal/apierror"
+)
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code apierror.Code, msg string) {
+	e := apierror.New(code, msg)
+	e.RequestID = RequestIDFromContext(r.Context())
+	apierror.Write(w, status, e)
+}
@@ -0,0 +1,20 @@
+// Package openapi embeds the OpenAPI 3 document describing the Pollex API.
+// The document is hand-maintained; contract tests in handler and server fail
+// when the Go types or routes drift from it.
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed openapi.json
+var Spec []byte
+
+// Handler serves the embedded OpenAPI document.
+func Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(Spec)
+	}
+}
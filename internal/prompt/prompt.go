@@ -0,0 +1,274 @@
+// Package prompt renders system prompts from text/template files, with
+// per-model and per-language overrides and few-shot examples. Templates are
+// validated when loaded, so a typo fails startup rather than a request.
+package prompt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mlorentedev/pollex/internal/adapter"
+	"github.com/mlorentedev/pollex/internal/config"
+	"github.com/mlorentedev/pollex/internal/lang"
+)
+
+// Vars is the data a template is executed with. Language is only ever the
+// configured default; requests choose the output language through Target.
+type Vars struct {
+	Model    string
+	Tone     string
+	Audience string
+	Language string
+	Glossary []string
+
+	// Source and Target are the language codes of the input and the output
+	// (see package lang); Source is empty if it could not be detected.
+	// Translate is set when they differ.
+	Source    string
+	Target    string
+	Translate bool
+}
+
+// merge fills v's empty fields from defaults.
+func (v Vars) merge(defaults Vars) Vars {
+	if v.Tone == "" {
+		v.Tone = defaults.Tone
+	}
+	if v.Audience == "" {
+		v.Audience = defaults.Audience
+	}
+	if v.Language == "" {
+		v.Language = defaults.Language
+	}
+	if v.Glossary == nil {
+		v.Glossary = defaults.Glossary
+	}
+	return v
+}
+
+var funcs = template.FuncMap{
+	"join":     strings.Join,
+	"lower":    strings.ToLower,
+	"langname": lang.Name,
+}
+
+// Template is a parsed, validated prompt template.
+type Template struct {
+	src string
+	t   *template.Template // nil for Literal
+}
+
+// Literal returns a Template that renders text verbatim.
+func Literal(text string) *Template {
+	return &Template{src: text}
+}
+
+// Parse parses src and executes it once with empty and with filled-in Vars,
+// so unknown fields and functions are reported now.
+func Parse(name, src string) (*Template, error) {
+	t, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("prompt: %w", err)
+	}
+	tp := &Template{src: src, t: t}
+	sample := Vars{Model: "m", Tone: "t", Audience: "a", Language: "l", Glossary: []string{"g"},
+		Source: lang.Spanish, Target: lang.English, Translate: true}
+	for _, v := range []Vars{{}, sample} {
+		if _, err := tp.Execute(v); err != nil {
+			return nil, err
+		}
+	}
+	return tp, nil
+}
+
+// ParseFile reads and parses the template at path.
+func ParseFile(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("prompt: %w", err)
+	}
+	return Parse(path, string(data))
+}
+
+// Execute renders the template with v.
+func (t *Template) Execute(v Vars) (string, error) {
+	if t.t == nil {
+		return t.src, nil
+	}
+	var b strings.Builder
+	if err := t.t.Execute(&b, v); err != nil {
+		return "", fmt.Errorf("prompt: %w", err)
+	}
+	return b.String(), nil
+}
+
+// Version identifies the template source by the first 12 hex digits of its
+// SHA-256, so feedback can be compared across prompt revisions.
+func (t *Template) Version() string {
+	return Version(t.src)
+}
+
+// Version hashes text the same way Template.Version does.
+func Version(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Rendered is a system prompt ready to send.
+type Rendered struct {
+	System   string
+	Examples []adapter.Example
+	Version  string // template and examples, independent of Vars
+}
+
+type modelPrompt struct {
+	tmpl     *Template // nil for the language or base template
+	examples []adapter.Example
+}
+
+// Set picks and renders the prompt for a model.
+type Set struct {
+	base      *Template
+	models    map[string]modelPrompt
+	languages map[string]*Template
+	defaults  Vars
+	tones     []string
+	audiences []string
+}
+
+// NewSet returns a Set that renders base for every model.
+func NewSet(base *Template, defaults Vars) *Set {
+	return &Set{base: base, models: make(map[string]modelPrompt), languages: make(map[string]*Template), defaults: defaults}
+}
+
+// Override makes model use tmpl (nil keeps the language or base template)
+// and examples.
+func (s *Set) Override(model string, tmpl *Template, examples []adapter.Example) {
+	s.models[model] = modelPrompt{tmpl: tmpl, examples: examples}
+}
+
+// Language makes output in the language code use tmpl, unless the model has
+// its own template.
+func (s *Set) Language(code string, tmpl *Template) {
+	s.languages[code] = tmpl
+}
+
+// Allow sets the tones and audiences a request may choose. Until it is
+// called, requests may choose neither.
+func (s *Set) Allow(tones, audiences []string) {
+	s.tones, s.audiences = tones, audiences
+}
+
+// Allowed returns the values set by Allow.
+func (s *Set) Allowed() (tones, audiences []string) {
+	return s.tones, s.audiences
+}
+
+// version identifies tmpl plus examples; without examples it is
+// tmpl.Version(), so a plain prompt keeps its version when moved here.
+func version(tmpl *Template, examples []adapter.Example) string {
+	if len(examples) == 0 {
+		return tmpl.Version()
+	}
+	var b strings.Builder
+	b.WriteString(tmpl.src)
+	for _, ex := range examples {
+		b.WriteString("\x00" + ex.Input + "\x00" + ex.Output)
+	}
+	return Version(b.String())
+}
+
+// Load builds a Set from cfg.PromptPath, cfg.PromptVars, cfg.LanguagePrompts
+// and cfg.ModelPrompts.
+func Load(cfg config.Config) (*Set, error) {
+	base, err := ParseFile(cfg.PromptPath)
+	if err != nil {
+		return nil, err
+	}
+	s := NewSet(base, Vars{
+		Tone:     cfg.PromptVars.Tone,
+		Audience: cfg.PromptVars.Audience,
+		Language: cfg.PromptVars.Language,
+	})
+	s.Allow(cfg.PromptVars.Tones, cfg.PromptVars.Audiences)
+	for code, path := range cfg.LanguagePrompts {
+		if lang.Name(code) == "" {
+			return nil, fmt.Errorf("prompt: unsupported language %q (supported: %s)", code, strings.Join(lang.Supported(), ", "))
+		}
+		tmpl, err := ParseFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("language %s: %w", code, err)
+		}
+		s.Language(code, tmpl)
+	}
+	for model, mp := range cfg.ModelPrompts {
+		var tmpl *Template
+		if mp.PromptPath != "" {
+			if tmpl, err = ParseFile(mp.PromptPath); err != nil {
+				return nil, fmt.Errorf("model %s: %w", model, err)
+			}
+		}
+		var examples []adapter.Example
+		if mp.ExamplesPath != "" {
+			if examples, err = loadExamples(mp.ExamplesPath); err != nil {
+				return nil, fmt.Errorf("model %s: %w", model, err)
+			}
+		}
+		s.Override(model, tmpl, examples)
+	}
+	return s, nil
+}
+
+func loadExamples(path string) ([]adapter.Example, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("prompt: %w", err)
+	}
+	var examples []adapter.Example
+	if err := yaml.Unmarshal(data, &examples); err != nil {
+		return nil, fmt.Errorf("prompt: parse %s: %w", path, err)
+	}
+	for i, ex := range examples {
+		if ex.Input == "" || ex.Output == "" {
+			return nil, fmt.Errorf("prompt: %s: example %d needs input and output", path, i+1)
+		}
+	}
+	return examples, nil
+}
+
+// Render renders model's prompt. Empty fields of v take the configured
+// defaults; v.Model is set to model.
+func (s *Set) Render(model string, v Vars) (Rendered, error) {
+	return s.RenderWith(nil, model, v)
+}
+
+// RenderWith is Render with tmpl in place of model's configured template,
+// keeping the model's examples. Experiments use it to swap prompts; a nil
+// tmpl means no swap. Without either, v.Target's language template is used
+// if there is one, and the base template otherwise.
+func (s *Set) RenderWith(tmpl *Template, model string, v Vars) (Rendered, error) {
+	v = v.merge(s.defaults)
+	v.Model = model
+
+	mp := s.models[model]
+	switch {
+	case tmpl != nil:
+		mp.tmpl = tmpl
+	case mp.tmpl != nil:
+	case s.languages[v.Target] != nil:
+		mp.tmpl = s.languages[v.Target]
+	default:
+		mp.tmpl = s.base
+	}
+	system, err := mp.tmpl.Execute(v)
+	if err != nil {
+		return Rendered{}, err
+	}
+	return Rendered{System: system, Examples: mp.examples, Version: version(mp.tmpl, mp.examples)}, nil
+}
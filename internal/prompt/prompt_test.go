@@ -0,0 +1,240 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mlorentedev/pollex/internal/config"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		wantErr bool
+	}{
+		{"plain text", "Fix grammar.", false},
+		{"vars", "Tone: {{.Tone}}. {{if .Glossary}}Keep {{join .Glossary \", \"}}.{{end}}", false},
+		{"funcs", "{{lower .Language}}", false},
+		{"language vars", "{{if .Translate}}{{langname .Source}} to {{langname .Target}}{{end}}", false},
+		{"unknown field", "{{.Nope}}", true},
+		{"unknown func", "{{upper .Tone}}", true},
+		{"syntax error", "{{.Tone", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("test", tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse(%q): err = %v, wantErr %v", tt.src, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLiteralIsVerbatim(t *testing.T) {
+	// Braces in a literal must not be interpreted.
+	got, err := Literal("Keep {{.Tone}} as is.").Execute(Vars{Tone: "formal"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got != "Keep {{.Tone}} as is." {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestVersionStable(t *testing.T) {
+	tmpl, err := Parse("test", "Tone: {{.Tone}}")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if tmpl.Version() != Version("Tone: {{.Tone}}") || len(tmpl.Version()) != 12 {
+		t.Errorf("Version() = %q", tmpl.Version())
+	}
+	// Variables don't change the version, so feedback groups by template.
+	s := NewSet(tmpl, Vars{})
+	a, _ := s.Render("m", Vars{Tone: "formal"})
+	b, _ := s.Render("m", Vars{Tone: "casual"})
+	if a.Version != b.Version || a.System == b.System {
+		t.Errorf("got %+v and %+v", a, b)
+	}
+}
+
+func TestSetRender(t *testing.T) {
+	base, err := Parse("base", "{{.Model}}/{{.Tone}}/{{.Audience}}")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	other, err := Parse("other", "other {{.Tone}}")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	s := NewSet(base, Vars{Tone: "neutral", Audience: "engineers"})
+	s.Override("small", other, nil)
+	s.Language("es", Literal("spanish"))
+
+	tests := []struct {
+		name  string
+		tmpl  *Template
+		model string
+		vars  Vars
+		want  string
+	}{
+		{"defaults", nil, "big", Vars{}, "big/neutral/engineers"},
+		{"request overrides default", nil, "big", Vars{Tone: "formal"}, "big/formal/engineers"},
+		{"model override", nil, "small", Vars{}, "other neutral"},
+		{"swapped template", Literal("swapped"), "small", Vars{}, "swapped"},
+		{"language template", nil, "big", Vars{Target: "es"}, "spanish"},
+		{"model override beats language", nil, "small", Vars{Target: "es"}, "other neutral"},
+		{"no language template", nil, "big", Vars{Target: "en"}, "big/neutral/engineers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.RenderWith(tt.tmpl, tt.model, tt.vars)
+			if err != nil {
+				t.Fatalf("RenderWith: %v", err)
+			}
+			if got.System != tt.want {
+				t.Errorf("got %q, want %q", got.System, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	base := writeFile(t, "base.txt", "Base, {{.Tone}}.")
+	small := writeFile(t, "small.txt", "Small, {{.Tone}}.")
+	spanish := writeFile(t, "es.txt", "Spanish, {{.Tone}}.")
+	examples := writeFile(t, "examples.yaml", `
+- input: "teh cat"
+  output: "The cat."
+- input: "its ok"
+  output: "It's OK."
+`)
+
+	s, err := Load(config.Config{
+		PromptPath:      base,
+		PromptVars:      config.PromptVars{Tone: "concise"},
+		LanguagePrompts: map[string]string{"es": spanish},
+		ModelPrompts: map[string]config.ModelPrompt{
+			"small":    {PromptPath: small, ExamplesPath: examples},
+			"examples": {ExamplesPath: examples},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	got, _ := s.Render("big", Vars{})
+	if got.System != "Base, concise." || len(got.Examples) != 0 {
+		t.Errorf("big: got %+v", got)
+	}
+	got, _ = s.Render("big", Vars{Target: "es"})
+	if got.System != "Spanish, concise." {
+		t.Errorf("big in Spanish: got %+v", got)
+	}
+	got, _ = s.Render("small", Vars{})
+	if got.System != "Small, concise." || len(got.Examples) != 2 || got.Examples[1].Output != "It's OK." {
+		t.Errorf("small: got %+v", got)
+	}
+	plain, _ := s.Render("big", Vars{})
+	withExamples, _ := s.Render("examples", Vars{})
+	if withExamples.System != plain.System || withExamples.Version == plain.Version {
+		t.Errorf("examples should keep the base prompt but change the version: %+v vs %+v", withExamples, plain)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	good := writeFile(t, "good.txt", "Fix grammar.")
+	bad := writeFile(t, "bad.txt", "{{.Nope}}")
+	incomplete := writeFile(t, "incomplete.yaml", "- input: only input\n")
+
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want string
+	}{
+		{"missing base", config.Config{PromptPath: filepath.Join(t.TempDir(), "missing.txt")}, "no such file"},
+		{"bad base", config.Config{PromptPath: bad}, "Nope"},
+		{"bad model prompt", config.Config{
+			PromptPath:   good,
+			ModelPrompts: map[string]config.ModelPrompt{"m": {PromptPath: bad}},
+		}, "model m"},
+		{"unsupported language", config.Config{
+			PromptPath:      good,
+			LanguagePrompts: map[string]string{"fr": good},
+		}, "unsupported language"},
+		{"incomplete example", config.Config{
+			PromptPath:   good,
+			ModelPrompts: map[string]config.ModelPrompt{"m": {ExamplesPath: incomplete}},
+		}, "needs input and output"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestShippedPrompt(t *testing.T) {
+	tmpl, err := ParseFile("../../prompts/polish.txt")
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	plain, _ := tmpl.Execute(Vars{})
+	if strings.Contains(plain, "{{") || strings.Contains(plain, "Keep these terms") {
+		t.Errorf("without glossary: got %q", plain)
+	}
+	withTerms, _ := tmpl.Execute(Vars{Glossary: []string{"Pollex", "k8s"}})
+	if !strings.Contains(withTerms, "meaning.\n- Keep these terms exactly as written: Pollex, k8s.\nOutput ONLY") {
+		t.Errorf("with glossary: got %q", withTerms)
+	}
+	if !strings.HasPrefix(plain, "Role: Professional English Polishing Assistant.\n") || strings.Contains(plain, "Write") {
+		t.Errorf("no language: got %q", plain)
+	}
+	same, _ := tmpl.Execute(Vars{Source: "es", Target: "es"})
+	if !strings.Contains(same, "Spanish Polishing Assistant") || !strings.Contains(same, "Constraints:\n- Write in Spanish, the language of the text. NEVER translate it.\n") {
+		t.Errorf("same language: got %q", same)
+	}
+	translate, _ := tmpl.Execute(Vars{Source: "es", Target: "en", Translate: true})
+	if !strings.Contains(translate, "Translate the text from Spanish into English") || !strings.Contains(translate, "- Write ONLY in English.\n") {
+		t.Errorf("translate: got %q", translate)
+	}
+	styled, _ := tmpl.Execute(Vars{Tone: "formal", Audience: "executives"})
+	if !strings.Contains(styled, "- Tone: formal, natural") || !strings.Contains(styled, "- Write for this audience: executives.\n") ||
+		strings.Contains(styled, "Professional but natural tone") {
+		t.Errorf("tone and audience: got %q", styled)
+	}
+}
+
+func TestShippedSpanishPrompt(t *testing.T) {
+	tmpl, err := ParseFile("../../prompts/polish.es.txt")
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	polish, _ := tmpl.Execute(Vars{Source: "es", Target: "es"})
+	translate, _ := tmpl.Execute(Vars{Source: "en", Target: "es", Translate: true})
+	if !strings.Contains(polish, "NUNCA traduzcas") || strings.Contains(polish, "Traduce") {
+		t.Errorf("polish: got %q", polish)
+	}
+	if !strings.Contains(translate, "Traduce el texto al español") || strings.Contains(translate, "NUNCA traduzcas") {
+		t.Errorf("translate: got %q", translate)
+	}
+	styled, _ := tmpl.Execute(Vars{Target: "es", Tone: "friendly", Audience: "customers"})
+	if !strings.Contains(styled, "- Tono: friendly, pero natural.\n") || !strings.Contains(styled, "- Escribe para este público: customers.\n") {
+		t.Errorf("tone and audience: got %q", styled)
+	}
+}
@@ -0,0 +1,134 @@
+// Package rank scores alternative rewrites of the same input so the most
+// faithful, cleanest one can be returned first.
+package rank
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mlorentedev/pollex/internal/adapter"
+	"github.com/mlorentedev/pollex/internal/config"
+	"github.com/mlorentedev/pollex/internal/diff"
+	"github.com/mlorentedev/pollex/internal/lint"
+)
+
+// Defaults for zero-valued config.Alternatives fields.
+const (
+	DefaultMax         = 5
+	DefaultTemperature = 0.8
+)
+
+// Scores are the components of a candidate's score, each from 0 (worst) to
+// 1 (best).
+type Scores struct {
+	Similarity  float64 `json:"similarity"`   // word similarity to the input
+	LengthRatio float64 `json:"length_ratio"` // 1 when as long as the input
+	Lint        float64 `json:"lint"`         // 1 without lint findings
+}
+
+// Candidate is one ranked rewrite. Score is the weighted mean of Scores.
+type Candidate struct {
+	Text   string  `json:"text"`
+	Score  float64 `json:"score"`
+	Scores Scores  `json:"scores"`
+}
+
+// Ranker is safe for concurrent use.
+type Ranker struct {
+	max         int
+	temperature float64
+	weights     config.ScoreWeights
+	linter      *lint.Linter
+}
+
+// New returns a Ranker for cfg. Lint scores use l.
+func New(cfg config.Alternatives, l *lint.Linter) *Ranker {
+	r := &Ranker{max: cfg.Max, temperature: cfg.Temperature, weights: cfg.Weights, linter: l}
+	if r.max <= 0 {
+		r.max = DefaultMax
+	}
+	if r.temperature <= 0 {
+		r.temperature = DefaultTemperature
+	}
+	if r.weights == (config.ScoreWeights{}) {
+		r.weights = config.ScoreWeights{Similarity: 1, LengthRatio: 1, Lint: 1}
+	}
+	return r
+}
+
+// Max is the largest n a request may ask for.
+func (r *Ranker) Max() int { return r.max }
+
+// Sampling returns the sampling for the i-th candidate (i >= 1; the first
+// candidate uses the backend's default).
+func (r *Ranker) Sampling(i int) adapter.Sampling {
+	return adapter.Sampling{Temperature: r.temperature, Seed: i}
+}
+
+// Rank scores texts against input and returns them best first. Texts that
+// differ only in whitespace count as duplicates; the first is kept.
+func (r *Ranker) Rank(input string, texts []string) []Candidate {
+	seen := make(map[string]bool)
+	var out []Candidate
+	for _, t := range texts {
+		key := strings.Join(strings.Fields(t), " ")
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, r.score(input, t))
+	}
+	slices.SortStableFunc(out, func(a, b Candidate) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	return out
+}
+
+func (r *Ranker) score(input, text string) Candidate {
+	s := Scores{
+		Similarity:  round3(diff.Similarity(input, text)),
+		LengthRatio: round3(lengthScore(input, text)),
+		Lint:        round3(r.lintScore(text)),
+	}
+	w := r.weights
+	total := w.Similarity*s.Similarity + w.LengthRatio*s.LengthRatio + w.Lint*s.Lint
+	return Candidate{Text: text, Score: round3(total / (w.Similarity + w.LengthRatio + w.Lint)), Scores: s}
+}
+
+// lengthScore is 1 minus the relative length change, floored at 0.
+func lengthScore(input, text string) float64 {
+	in := utf8.RuneCountInString(input)
+	if in == 0 {
+		return 0
+	}
+	ratio := float64(utf8.RuneCountInString(text)) / float64(in)
+	return math.Max(0, 1-math.Abs(ratio-1))
+}
+
+// lintScore halves with each warning; hints count half as much.
+func (r *Ranker) lintScore(text string) float64 {
+	if r.linter == nil {
+		return 1
+	}
+	penalty := 0.0
+	for _, f := range r.linter.Check(text).Findings {
+		if f.Severity == lint.SeverityWarning {
+			penalty++
+		} else {
+			penalty += 0.5
+		}
+	}
+	return math.Pow(0.5, penalty)
+}
+
+func round3(f float64) float64 {
+	return math.Round(f*1000) / 1000
+}
@@ -0,0 +1,59 @@
+package rank
+
+import (
+	"testing"
+
+	"github.com/mlorentedev/pollex/internal/adapter"
+	"github.com/mlorentedev/pollex/internal/config"
+	"github.com/mlorentedev/pollex/internal/lint"
+)
+
+func TestRank(t *testing.T) {
+	r := New(config.Alternatives{}, lint.New(lint.Options{}))
+	input := "We need fix the the bug today."
+
+	got := r.Rank(input, []string{
+		"We need to leverage a fix for the bug today.", // banned word
+		"We need to fix the bug today.",
+		"We need to fix  the bug today.", // duplicate modulo whitespace
+		"Fix it.",                        // far too short
+		"",
+	})
+	if len(got) != 3 {
+		t.Fatalf("candidates: got %+v", got)
+	}
+	if got[0].Text != "We need to fix the bug today." || got[2].Text != "Fix it." {
+		t.Errorf("order: got %q, %q, %q", got[0].Text, got[1].Text, got[2].Text)
+	}
+	if got[0].Scores.Lint != 1 || got[1].Scores.Lint != 0.5 {
+		t.Errorf("lint scores: got %+v, %+v", got[0].Scores, got[1].Scores)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Score > got[i-1].Score {
+			t.Errorf("not sorted: %v after %v", got[i].Score, got[i-1].Score)
+		}
+	}
+}
+
+func TestWeights(t *testing.T) {
+	// Weighing only length prefers the verbose candidate that matches the
+	// input's length over the faithful but shorter one.
+	r := New(config.Alternatives{Weights: config.ScoreWeights{LengthRatio: 1}}, nil)
+	got := r.Rank("aaaa bbbb cccc", []string{"aaaa", "xxxx yyyy zzzz"})
+	if got[0].Text != "xxxx yyyy zzzz" || got[0].Score != 1 {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	r := New(config.Alternatives{}, nil)
+	if r.Max() != DefaultMax {
+		t.Errorf("max: got %d", r.Max())
+	}
+	if s := r.Sampling(2); s != (adapter.Sampling{Temperature: DefaultTemperature, Seed: 2}) {
+		t.Errorf("sampling: got %+v", s)
+	}
+	if s := New(config.Alternatives{Max: 3, Temperature: 1.2}, nil); s.Max() != 3 || s.Sampling(1).Temperature != 1.2 {
+		t.Errorf("configured: got %d %+v", s.Max(), s.Sampling(1))
+	}
+}
@@ -0,0 +1,159 @@
+// Package redact replaces personal and internal data in text with stable
+// placeholders before it is sent to a remote model, and restores it after.
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrConfidential is returned when text carries a confidential marker and
+// must not leave the network at all.
+var ErrConfidential = errors.New("redact: text is marked confidential")
+
+// Options configures a Redactor. Built-in rules for emails and phone
+// numbers are always active.
+type Options struct {
+	Terms           []string // names or words to redact, matched case-insensitively
+	InternalDomains []string // host suffixes such as "corp.example.com"
+	Markers         []string // case-sensitive substrings that block the text entirely
+}
+
+type rule struct {
+	kind string // placeholder prefix and log key
+	re   *regexp.Regexp
+	keep func(match string) bool // optional post-filter
+}
+
+// Redactor is safe for concurrent use.
+type Redactor struct {
+	rules   []rule
+	markers []string
+}
+
+var (
+	emailRe = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	phoneRe = regexp.MustCompile(`\+?\(?\d[\d\s().-]{6,}\d`)
+)
+
+// New builds a Redactor from opts.
+func New(opts Options) *Redactor {
+	// Order matters: emails go first so their domain isn't taken for a host.
+	rules := []rule{
+		{kind: "EMAIL", re: emailRe},
+		{kind: "PHONE", re: phoneRe, keep: plausiblePhone},
+	}
+	if len(opts.InternalDomains) > 0 {
+		alts := make([]string, len(opts.InternalDomains))
+		for i, d := range opts.InternalDomains {
+			alts[i] = regexp.QuoteMeta(strings.Trim(d, "."))
+		}
+		rules = append(rules, rule{
+			kind: "HOST",
+			re:   regexp.MustCompile(`(?i)\b(?:[a-z0-9-]+\.)*(?:` + strings.Join(alts, "|") + `)\b`),
+		})
+	}
+	var terms []string
+	for _, t := range opts.Terms {
+		if t = strings.TrimSpace(t); t != "" {
+			terms = append(terms, regexp.QuoteMeta(t))
+		}
+	}
+	if len(terms) > 0 {
+		rules = append(rules, rule{
+			kind: "TERM",
+			re:   regexp.MustCompile(`(?i)\b(?:` + strings.Join(terms, "|") + `)\b`),
+		})
+	}
+	return &Redactor{rules: rules, markers: opts.Markers}
+}
+
+// plausiblePhone rejects short digit runs such as dates and version numbers.
+func plausiblePhone(s string) bool {
+	n := 0
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			n++
+		}
+	}
+	return n >= 9 && n <= 15
+}
+
+// Mapping records the placeholders substituted by Redact.
+type Mapping struct {
+	placeholders []string
+	values       []string
+	counts       map[string]int
+	seen         map[string]string // kind+value -> placeholder
+	required     int               // placeholders Restore expects to find
+}
+
+// Counts returns the number of distinct values redacted per kind
+// (EMAIL, PHONE, HOST, TERM). It never exposes the values themselves.
+func (m *Mapping) Counts() map[string]int { return m.counts }
+
+// Len returns the number of placeholders in use.
+func (m *Mapping) Len() int { return len(m.placeholders) }
+
+// Redact returns text with every match replaced by a placeholder such as
+// [EMAIL_1]. Repeated values share a placeholder. It returns ErrConfidential
+// if text contains any configured marker.
+func (r *Redactor) Redact(text string) (string, *Mapping, error) {
+	m := &Mapping{counts: make(map[string]int), seen: make(map[string]string)}
+	text, err := r.Extend(m, text)
+	if err != nil {
+		return "", nil, err
+	}
+	m.required = len(m.placeholders)
+	return text, m, nil
+}
+
+// Extend redacts text into an existing mapping, so values it shares with the
+// text given to Redact keep their placeholder. It is meant for context sent
+// alongside that text: placeholders first seen here are restored but never
+// reported lost.
+func (r *Redactor) Extend(m *Mapping, text string) (string, error) {
+	for _, marker := range r.markers {
+		if marker != "" && strings.Contains(text, marker) {
+			return "", ErrConfidential
+		}
+	}
+
+	for _, ru := range r.rules {
+		text = ru.re.ReplaceAllStringFunc(text, func(match string) string {
+			if ru.keep != nil && !ru.keep(match) {
+				return match
+			}
+			key := ru.kind + "\x00" + match
+			if p, ok := m.seen[key]; ok {
+				return p
+			}
+			m.counts[ru.kind]++
+			p := fmt.Sprintf("[%s_%d]", ru.kind, m.counts[ru.kind])
+			m.seen[key] = p
+			m.placeholders = append(m.placeholders, p)
+			m.values = append(m.values, match)
+			return p
+		})
+	}
+	return text, nil
+}
+
+// Restore puts the original values back into text and returns how many
+// placeholders could not be found (e.g. because the model rewrote them).
+func (m *Mapping) Restore(text string) (string, int) {
+	if len(m.placeholders) == 0 {
+		return text, 0
+	}
+	lost := 0
+	pairs := make([]string, 0, 2*len(m.placeholders))
+	for i, p := range m.placeholders {
+		if i < m.required && !strings.Contains(text, p) {
+			lost++
+		}
+		pairs = append(pairs, p, m.values[i])
+	}
+	return strings.NewReplacer(pairs...).Replace(text), lost
+}
@@ -0,0 +1,90 @@
+package redact
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRedactRestore(t *testing.T) {
+	r := New(Options{
+		Terms:           []string{"Acme Corp", "Jane Doe"},
+		InternalDomains: []string{"corp.example.com"},
+	})
+
+	in := "Ask jane doe (jane@acme.com, +34 612 345 678) about build01.corp.example.com. " +
+		"Jane Doe said Acme Corp ships on 2026-01-12, see jane@acme.com."
+	out, m, err := r.Redact(in)
+	if err != nil {
+		t.Fatalf("Redact: %v", err)
+	}
+
+	for _, leak := range []string{"jane@acme.com", "612 345 678", "build01", "Acme Corp", "Jane Doe", "jane doe"} {
+		if strings.Contains(out, leak) {
+			t.Errorf("redacted text still contains %q: %s", leak, out)
+		}
+	}
+	if !strings.Contains(out, "2026-01-12") {
+		t.Errorf("date was redacted as a phone number: %s", out)
+	}
+
+	want := map[string]int{"EMAIL": 1, "PHONE": 1, "HOST": 1, "TERM": 3}
+	for kind, n := range want {
+		if got := m.Counts()[kind]; got != n {
+			t.Errorf("count %s: got %d, want %d", kind, got, n)
+		}
+	}
+
+	restored, lost := m.Restore(out)
+	if restored != in {
+		t.Errorf("restore:\ngot  %q\nwant %q", restored, in)
+	}
+	if lost != 0 {
+		t.Errorf("lost: got %d, want 0", lost)
+	}
+}
+
+func TestRestoreReportsLostPlaceholders(t *testing.T) {
+	_, m, _ := New(Options{}).Redact("mail me at a@b.io")
+	got, lost := m.Restore("Mail me.")
+	if got != "Mail me." || lost != 1 {
+		t.Errorf("got %q lost=%d, want %q lost=1", got, lost, "Mail me.")
+	}
+}
+
+func TestRedactConfidentialMarker(t *testing.T) {
+	r := New(Options{Markers: []string{"CONFIDENTIAL"}})
+
+	if _, _, err := r.Redact("CONFIDENTIAL: q3 numbers"); !errors.Is(err, ErrConfidential) {
+		t.Errorf("marker: got %v, want ErrConfidential", err)
+	}
+	if _, _, err := r.Redact("this is confidential-ish"); err != nil {
+		t.Errorf("lowercase word: got %v, want nil", err)
+	}
+}
+
+func TestExtend(t *testing.T) {
+	r := New(Options{Terms: []string{"Jane Doe"}, Markers: []string{"CONFIDENTIAL"}})
+	_, m, err := r.Redact("Reply to Jane Doe.")
+	if err != nil {
+		t.Fatalf("Redact: %v", err)
+	}
+
+	ctx, err := r.Extend(m, "From Jane Doe <jane@acme.com>")
+	if err != nil {
+		t.Fatalf("Extend: %v", err)
+	}
+	if want := "From [TERM_1] <[EMAIL_1]>"; ctx != want {
+		t.Errorf("extended: got %q, want %q", ctx, want)
+	}
+
+	// The email only appeared in the context, so its absence is not a loss.
+	got, lost := m.Restore("Thanks, [TERM_1].")
+	if got != "Thanks, Jane Doe." || lost != 0 {
+		t.Errorf("restore: got %q lost=%d", got, lost)
+	}
+
+	if _, err := r.Extend(m, "CONFIDENTIAL thread"); !errors.Is(err, ErrConfidential) {
+		t.Errorf("marker: got %v, want ErrConfidential", err)
+	}
+}
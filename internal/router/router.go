@@ -0,0 +1,198 @@
+// Package router picks a model for requests that don't name one, using the
+// ordered rules from config.
+package router
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mlorentedev/pollex/internal/adapter"
+	"github.com/mlorentedev/pollex/internal/config"
+)
+
+// ErrNoRoute is returned when no rule yields an available, permitted model.
+var ErrNoRoute = errors.New("router: no available model for request")
+
+// DefaultRule names the implicit last rule: every model in priority order.
+const DefaultRule = "default"
+
+// availabilityTTL bounds how often a backend's Available() is called, since
+// for local backends it is an HTTP health check.
+const availabilityTTL = 10 * time.Second
+
+// Request holds the attributes rules can match on.
+type Request struct {
+	Chars int
+	Mode  string
+	Key   string
+	Tier  string
+	Time  time.Time
+}
+
+// Decision is the chosen model and the rule that chose it.
+type Decision struct {
+	Model string
+	Rule  string
+}
+
+type rule struct {
+	config.RouteRule
+	from, to int // minutes since midnight
+}
+
+// Router is safe for concurrent use.
+type Router struct {
+	rules     []rule
+	fallback  []string
+	adapters  map[string]adapter.LLMAdapter
+	isRemote  func(model string) bool
+	localOnly map[string]bool
+
+	mu    sync.Mutex
+	avail map[string]availability
+	now   func() time.Time
+}
+
+type availability struct {
+	ok      bool
+	checked time.Time
+}
+
+// New validates rules against the registered adapters. models gives the
+// priority order used when no rule matches.
+func New(rules []config.RouteRule, adapters map[string]adapter.LLMAdapter, models []adapter.ModelInfo, isRemote func(string) bool, localOnlyKeys []string) (*Router, error) {
+	r := &Router{
+		adapters:  adapters,
+		isRemote:  isRemote,
+		localOnly: make(map[string]bool),
+		avail:     make(map[string]availability),
+		now:       time.Now,
+	}
+	for _, k := range localOnlyKeys {
+		r.localOnly[k] = true
+	}
+	for _, m := range models {
+		r.fallback = append(r.fallback, m.ID)
+	}
+
+	for i, rc := range rules {
+		if rc.Name == "" {
+			rc.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if len(rc.Models) == 0 {
+			return nil, fmt.Errorf("router: rule %q: no models", rc.Name)
+		}
+		for _, m := range rc.Models {
+			if _, ok := adapters[m]; !ok {
+				return nil, fmt.Errorf("router: rule %q: unknown model %q", rc.Name, m)
+			}
+		}
+		ru := rule{RouteRule: rc}
+		if rc.Hours != "" {
+			var err error
+			if ru.from, ru.to, err = parseHours(rc.Hours); err != nil {
+				return nil, fmt.Errorf("router: rule %q: %w", rc.Name, err)
+			}
+		}
+		r.rules = append(r.rules, ru)
+	}
+	return r, nil
+}
+
+// parseHours parses "HH:MM-HH:MM" into minutes since midnight.
+func parseHours(s string) (from, to int, err error) {
+	var h1, m1, h2, m2 int
+	if _, err := fmt.Sscanf(s, "%d:%d-%d:%d", &h1, &m1, &h2, &m2); err != nil {
+		return 0, 0, fmt.Errorf("invalid hours %q (want HH:MM-HH:MM)", s)
+	}
+	if h1 > 23 || h2 > 24 || m1 > 59 || m2 > 59 || h1 < 0 || h2 < 0 || m1 < 0 || m2 < 0 {
+		return 0, 0, fmt.Errorf("invalid hours %q", s)
+	}
+	return h1*60 + m1, h2*60 + m2, nil
+}
+
+// Allowed reports whether key may use model. Local-only keys may not use
+// remote models.
+func (r *Router) Allowed(key, model string) bool {
+	return !r.localOnly[key] || !r.isRemote(model)
+}
+
+// Remote reports whether model runs outside the network.
+func (r *Router) Remote(model string) bool {
+	return r.isRemote(model)
+}
+
+// Route returns the first available, permitted model of the first matching
+// rule, falling through to later rules when every candidate is down.
+func (r *Router) Route(req Request) (Decision, error) {
+	for _, ru := range r.rules {
+		if !ru.matches(req) {
+			continue
+		}
+		if m, ok := r.pick(req.Key, ru.Models); ok {
+			return Decision{Model: m, Rule: ru.Name}, nil
+		}
+	}
+	if m, ok := r.pick(req.Key, r.fallback); ok {
+		return Decision{Model: m, Rule: DefaultRule}, nil
+	}
+	return Decision{}, ErrNoRoute
+}
+
+func (ru rule) matches(req Request) bool {
+	switch {
+	case ru.MinChars > 0 && req.Chars < ru.MinChars:
+		return false
+	case ru.MaxChars > 0 && req.Chars > ru.MaxChars:
+		return false
+	case len(ru.Modes) > 0 && !slices.Contains(ru.Modes, req.Mode):
+		return false
+	case len(ru.Keys) > 0 && !slices.Contains(ru.Keys, req.Key):
+		return false
+	case len(ru.Tiers) > 0 && !slices.Contains(ru.Tiers, req.Tier):
+		return false
+	case ru.Hours != "" && !inWindow(req.Time, ru.from, ru.to):
+		return false
+	}
+	return true
+}
+
+func inWindow(t time.Time, from, to int) bool {
+	m := t.Hour()*60 + t.Minute()
+	if from <= to {
+		return m >= from && m < to
+	}
+	return m >= from || m < to // wraps midnight
+}
+
+func (r *Router) pick(key string, candidates []string) (string, bool) {
+	for _, m := range candidates {
+		if r.Allowed(key, m) && r.available(m) {
+			return m, true
+		}
+	}
+	return "", false
+}
+
+func (r *Router) available(model string) bool {
+	a, ok := r.adapters[model]
+	if !ok {
+		return false
+	}
+
+	r.mu.Lock()
+	cached, ok := r.avail[model]
+	r.mu.Unlock()
+	if ok && r.now().Sub(cached.checked) < availabilityTTL {
+		return cached.ok
+	}
+
+	up := a.Available()
+	r.mu.Lock()
+	r.avail[model] = availability{ok: up, checked: r.now()}
+	r.mu.Unlock()
+	return up
+}
@@ -0,0 +1,151 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mlorentedev/pollex/internal/adapter"
+	"github.com/mlorentedev/pollex/internal/config"
+)
+
+type stubAdapter struct {
+	up    bool
+	calls int
+}
+
+func (s *stubAdapter) Name() string { return "stub" }
+func (s *stubAdapter) Polish(ctx context.Context, text, systemPrompt string) (string, error) {
+	return text, nil
+}
+func (s *stubAdapter) Available() bool { s.calls++; return s.up }
+
+func newTestRouter(t *testing.T, rules []config.RouteRule, up map[string]bool) *Router {
+	t.Helper()
+	adapters := map[string]adapter.LLMAdapter{}
+	var models []adapter.ModelInfo
+	for _, id := range []string{"jetson", "claude"} {
+		adapters[id] = &stubAdapter{up: up[id]}
+		models = append(models, adapter.ModelInfo{ID: id})
+	}
+	r, err := New(rules, adapters, models, func(m string) bool { return m == "claude" }, []string{"legal"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return r
+}
+
+func TestRoute(t *testing.T) {
+	rules := []config.RouteRule{
+		{Name: "short-local", MaxChars: 500, Models: []string{"jetson"}},
+		{Name: "long-cloud", MinChars: 501, Models: []string{"claude", "jetson"}},
+	}
+	noon := time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name      string
+		up        map[string]bool
+		req       Request
+		wantModel string
+		wantRule  string
+	}{
+		{"short text", map[string]bool{"jetson": true, "claude": true}, Request{Chars: 100, Time: noon}, "jetson", "short-local"},
+		{"long text", map[string]bool{"jetson": true, "claude": true}, Request{Chars: 2000, Time: noon}, "claude", "long-cloud"},
+		{"long text, claude down", map[string]bool{"jetson": true}, Request{Chars: 2000, Time: noon}, "jetson", "long-cloud"},
+		{"long text, local-only key", map[string]bool{"jetson": true, "claude": true}, Request{Chars: 2000, Key: "legal", Time: noon}, "jetson", "long-cloud"},
+		{"short text, jetson down", map[string]bool{"claude": true}, Request{Chars: 100, Time: noon}, "claude", DefaultRule},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRouter(t, rules, tt.up)
+			d, err := r.Route(tt.req)
+			if err != nil {
+				t.Fatalf("Route: %v", err)
+			}
+			if d.Model != tt.wantModel || d.Rule != tt.wantRule {
+				t.Errorf("got %s via %s, want %s via %s", d.Model, d.Rule, tt.wantModel, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestRouteNoRouteForLocalOnlyKey(t *testing.T) {
+	r := newTestRouter(t, nil, map[string]bool{"claude": true})
+	if _, err := r.Route(Request{Key: "legal"}); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("got %v, want ErrNoRoute", err)
+	}
+	if r.Allowed("legal", "claude") {
+		t.Error("legal may use claude, want denied")
+	}
+	if !r.Allowed("alice", "claude") {
+		t.Error("alice may not use claude, want allowed")
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	night := rule{RouteRule: config.RouteRule{Hours: "22:00-06:00"}, from: 22 * 60, to: 6 * 60}
+	tier := rule{RouteRule: config.RouteRule{Tiers: []string{"fast"}, Modes: []string{"polish"}}}
+
+	tests := []struct {
+		name string
+		rule rule
+		req  Request
+		want bool
+	}{
+		{"night at 23:30", night, Request{Time: time.Date(2026, 1, 1, 23, 30, 0, 0, time.UTC)}, true},
+		{"night at 05:59", night, Request{Time: time.Date(2026, 1, 1, 5, 59, 0, 0, time.UTC)}, true},
+		{"night at 12:00", night, Request{Time: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}, false},
+		{"tier and mode", tier, Request{Tier: "fast", Mode: "polish"}, true},
+		{"wrong tier", tier, Request{Tier: "quality", Mode: "polish"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.matches(tt.req); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewValidates(t *testing.T) {
+	adapters := map[string]adapter.LLMAdapter{"jetson": &stubAdapter{}}
+	isRemote := func(string) bool { return false }
+
+	tests := []struct {
+		name  string
+		rules []config.RouteRule
+	}{
+		{"no models", []config.RouteRule{{Name: "empty"}}},
+		{"unknown model", []config.RouteRule{{Models: []string{"gpt"}}}},
+		{"bad hours", []config.RouteRule{{Models: []string{"jetson"}, Hours: "late"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.rules, adapters, nil, isRemote, nil); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestAvailabilityCached(t *testing.T) {
+	r := newTestRouter(t, nil, map[string]bool{"jetson": true})
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	for range 3 {
+		r.Route(Request{})
+	}
+	stub := r.adapters["jetson"].(*stubAdapter)
+	if stub.calls != 1 {
+		t.Errorf("Available calls: got %d, want 1", stub.calls)
+	}
+
+	now = now.Add(availabilityTTL)
+	r.Route(Request{})
+	if stub.calls != 2 {
+		t.Errorf("Available calls after TTL: got %d, want 2", stub.calls)
+	}
+}
@@ -0,0 +1,310 @@
+// Package rules corrects text deterministically: regexp rules from a YAML
+// file for common non-native mistakes, then built-in whitespace,
+// doubled-word, a/an and capitalisation fixes. It is the engine behind the
+// offline "rules" model.
+package rules
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed default.yaml
+var defaultRules []byte
+
+// Rule is one entry of a rules file. With Words, "{words}" in Pattern
+// matches any key and "${word}" in Replace becomes the matched key's value,
+// capitalised like the key was.
+type Rule struct {
+	Name    string            `yaml:"name"`
+	Pattern string            `yaml:"pattern"`
+	Replace string            `yaml:"replace"`
+	Words   map[string]string `yaml:"words"`
+}
+
+type compiled struct {
+	re      *regexp.Regexp
+	replace string
+	words   map[string]string // lower-cased keys
+}
+
+// Set is a compiled list of rules. It is safe for concurrent use.
+type Set struct {
+	rules []compiled
+}
+
+// Default returns the rules shipped with Pollex.
+func Default() *Set {
+	s, err := parse(defaultRules, "default.yaml")
+	if err != nil {
+		panic(err) // the embedded file is covered by tests
+	}
+	return s
+}
+
+// Load reads a rules file; an empty path means Default.
+func Load(path string) (*Set, error) {
+	if path == "" {
+		return Default(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("rules: %w", err)
+	}
+	return parse(data, path)
+}
+
+func parse(data []byte, name string) (*Set, error) {
+	var list []Rule
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("rules: parse %s: %w", name, err)
+	}
+	s, err := New(list)
+	if err != nil {
+		return nil, fmt.Errorf("%w (in %s)", err, name)
+	}
+	return s, nil
+}
+
+// New compiles list.
+func New(list []Rule) (*Set, error) {
+	s := &Set{}
+	for i, r := range list {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		pattern := r.Pattern
+		c := compiled{replace: r.Replace}
+		if len(r.Words) > 0 {
+			if !strings.Contains(pattern, "{words}") {
+				return nil, fmt.Errorf("rules: %s: words set but pattern lacks {words}", r.Name)
+			}
+			c.words = make(map[string]string, len(r.Words))
+			keys := make([]string, 0, len(r.Words))
+			for k, v := range r.Words {
+				c.words[strings.ToLower(k)] = v
+				keys = append(keys, regexp.QuoteMeta(k))
+			}
+			// Longest first so a key isn't shadowed by its own prefix.
+			slices.SortFunc(keys, func(a, b string) int { return len(b) - len(a) })
+			pattern = strings.ReplaceAll(pattern, "{words}", "(?P<word>"+strings.Join(keys, "|")+")")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rules: %s: %w", r.Name, err)
+		}
+		c.re = re
+		s.rules = append(s.rules, c)
+	}
+	return s, nil
+}
+
+// Apply runs every rule and then the built-in fixes.
+func (s *Set) Apply(text string) string {
+	for _, r := range s.rules {
+		text = r.apply(text)
+	}
+	text = fixSpacing(text)
+	text = fixDoubledWords(text)
+	text = fixArticles(text)
+	return fixCapitals(text)
+}
+
+func (r compiled) apply(text string) string {
+	if r.words == nil {
+		return r.re.ReplaceAllString(text, r.replace)
+	}
+	group := r.re.SubexpIndex("word")
+	var b strings.Builder
+	last := 0
+	for _, m := range r.re.FindAllStringSubmatchIndex(text, -1) {
+		word := text[m[2*group]:m[2*group+1]]
+		tmpl := strings.ReplaceAll(r.replace, "${word}", matchCase(word, r.words[strings.ToLower(word)]))
+		b.WriteString(text[last:m[0]])
+		b.Write(r.re.ExpandString(nil, tmpl, text, m))
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// matchCase capitalises repl if like starts with an upper-case letter.
+func matchCase(like, repl string) string {
+	first, _ := utf8.DecodeRuneInString(like)
+	if unicode.IsUpper(first) {
+		return capitalise(repl)
+	}
+	return repl
+}
+
+func capitalise(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[n:]
+}
+
+var (
+	spaceRunRe      = regexp.MustCompile(`[ \t]{2,}`)
+	trailingSpaceRe = regexp.MustCompile(`[ \t]+\n`)
+	spaceBeforeRe   = regexp.MustCompile(`(\S)[ \t]+([,.;:!?])`)
+	commaGlueRe     = regexp.MustCompile(`(\pL),(\pL)`)
+)
+
+// fixSpacing collapses runs of spaces, drops spaces at line ends and before
+// punctuation, and adds a missing space after a comma between words.
+// Leading indentation and line breaks are kept, and so is the space before
+// a dot that starts a word, as in ".NET" or ".env".
+func fixSpacing(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		lines[i] = line[:indent] + spaceRunRe.ReplaceAllString(line[indent:], " ")
+	}
+	text = strings.Join(lines, "\n")
+	text = trailingSpaceRe.ReplaceAllString(text, "\n")
+	text = replaceMatches(spaceBeforeRe, text, func(m []int) (string, bool) {
+		if text[m[4]] == '.' && startsWord(text[m[5]:]) {
+			return "", false
+		}
+		return text[m[2]:m[3]] + text[m[4]:m[5]], true
+	})
+	text = commaGlueRe.ReplaceAllString(text, "$1, $2")
+	return strings.TrimSpace(text)
+}
+
+func startsWord(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// replaceMatches replaces each match of re in text with what fn returns for
+// its submatch indexes, leaving the match as is when fn returns false.
+func replaceMatches(re *regexp.Regexp, text string, fn func(m []int) (string, bool)) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+		repl, ok := fn(m)
+		if !ok {
+			continue
+		}
+		b.WriteString(text[last:m[0]])
+		b.WriteString(repl)
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+var wordRe = regexp.MustCompile(`\pL+(?:'\pL+)?`)
+
+// legitDoubles are words that may correctly appear twice in a row.
+var legitDoubles = map[string]bool{"had": true, "that": true}
+
+// fixDoubledWords removes the second of two identical adjacent words.
+func fixDoubledWords(text string) string {
+	words := wordRe.FindAllStringIndex(text, -1)
+	var b strings.Builder
+	last := 0
+	for i := 1; i < len(words); i++ {
+		prev, cur := words[i-1], words[i]
+		w := text[cur[0]:cur[1]]
+		if text[prev[1]:cur[0]] == " " && strings.EqualFold(text[prev[0]:prev[1]], w) && !legitDoubles[strings.ToLower(w)] {
+			b.WriteString(text[last:prev[1]])
+			last = cur[1]
+		}
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// articleRe matches a lower-case article, or a capitalised one that
+// starts the text or a sentence, before a lower-case word. A capital "A"
+// elsewhere is usually a letter, as in "Option A is better".
+var articleRe = regexp.MustCompile(`(^|[.!?]\s+|[^\pL\pN.])(an?|An?) (\p{Ll}[\pL'-]*)`)
+
+// anExceptions overrides the vowel-letter rule for a/an by word prefix; the
+// longest matching prefix wins, so "unimportant" (un-) takes "an" while
+// "unit" and "unanimous" take "a".
+var anExceptions = map[string]bool{
+	"hour": true, "honest": true, "honor": true, "honour": true, "heir": true,
+	"uni": false, "use": false, "usu": false, "uti": false, "ure": false, "eu": false, "one": false, "once": false,
+	"una": true, "unan": false, "unar": false, "une": true, "unim": true, "unin": true, "uno": true, "unu": true,
+}
+
+// fixArticles picks "a" or "an" from the next word's first letter, with
+// exceptions for silent h and "you"-sounding u.
+func fixArticles(text string) string {
+	return replaceMatches(articleRe, text, func(m []int) (string, bool) {
+		lead, article, next := text[m[2]:m[3]], text[m[4]:m[5]], text[m[6]:m[7]]
+		if article[0] == 'A' && lead != "" && !strings.ContainsAny(lead, ".!?") {
+			return "", false
+		}
+		want := "a"
+		if needsAn(strings.ToLower(next)) {
+			want = "an"
+		}
+		if article[0] == 'A' {
+			want = capitalise(want)
+		}
+		return lead + want + " " + next, true
+	})
+}
+
+func needsAn(word string) bool {
+	for n := len(word); n > 0; n-- {
+		if an, ok := anExceptions[word[:n]]; ok {
+			return an
+		}
+	}
+	return strings.ContainsRune("aeiou", rune(word[0]))
+}
+
+var (
+	lowerIRe        = regexp.MustCompile(`\bi\b('[a-z]+)?`)
+	sentenceStartRe = regexp.MustCompile(`(^|[.!?]\s+)(\p{Ll})`)
+)
+
+// abbreviations end in a dot without ending the sentence.
+var abbreviations = []string{"i.e.", "e.g."}
+
+// fixCapitals capitalises the pronoun "I" and the first letter of the text
+// and of each sentence, skipping "i.e." and "e.g.".
+func fixCapitals(text string) string {
+	text = replaceMatches(lowerIRe, text, func(m []int) (string, bool) {
+		if strings.HasPrefix(text[m[0]:], "i.e.") {
+			return "", false
+		}
+		return "I" + text[m[0]+1:m[1]], true
+	})
+	return replaceMatches(sentenceStartRe, text, func(m []int) (string, bool) {
+		if afterAbbreviation(text[:m[0]+1]) {
+			return "", false
+		}
+		r, _ := utf8.DecodeRuneInString(text[m[4]:])
+		return text[m[2]:m[3]] + string(unicode.ToUpper(r)), true
+	})
+}
+
+// afterAbbreviation reports whether text ends with one of abbreviations as
+// a whole word.
+func afterAbbreviation(text string) bool {
+	lower := strings.ToLower(text)
+	for _, a := range abbreviations {
+		if rest, ok := strings.CutSuffix(lower, a); ok && !startsWord(lastRune(rest)) {
+			return true
+		}
+	}
+	return false
+}
+
+func lastRune(s string) string {
+	_, n := utf8.DecodeLastRuneInString(s)
+	return s[len(s)-n:]
+}
@@ -0,0 +1,100 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyDefault(t *testing.T) {
+	s := Default()
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"clean", "We shipped it.", "We shipped it."},
+		{"spacing", "  we  shipped it , finally .  ", "We shipped it, finally."},
+		{"comma glue", "First,second.", "First, second."},
+		{"keeps line breaks", "one\n  two", "One\n  two"},
+		{"doubled word", "This is the the plan.", "This is the plan."},
+		{"legit double", "He had had enough.", "He had had enough."},
+		{"past after auxiliary", "I didn't saw it.", "I didn't see it."},
+		{"past after curly apostrophe", "She doesn’t knew.", "She doesn’t know."},
+		{"adjective as adverb", "The deploy went smooth.", "The deploy went smoothly."},
+		{"double comparative", "It is more better now.", "It is better now."},
+		{"uncountable", "Thanks for the informations.", "Thanks for the information."},
+		{"keeps case", "Informations are here.", "Information are here."},
+		{"explain to", "Can you explain me the plan?", "Can you explain to me the plan?"},
+		{"depend on", "It depends of the load.", "It depends on the load."},
+		{"discuss about", "We discussed about it.", "We discussed it."},
+		{"a before vowel", "It was a error.", "It was an error."},
+		{"an before consonant", "It is an big deal.", "It is a big deal."},
+		{"an hour", "Wait a hour.", "Wait an hour."},
+		{"a user", "Ask an user.", "Ask a user."},
+		{"acronym left alone", "Use a API key.", "Use a API key."},
+		{"capital article", "An cat sat.", "A cat sat."},
+		{"sentence starts", "hi. how are you? fine!", "Hi. How are you? Fine!"},
+		{"pronoun I", "i think i'm done.", "I think I'm done."},
+		{"letter A", "Option A is better.", "Option A is better."},
+		{"vitamin A", "Take vitamin A daily.", "Take vitamin A daily."},
+		{"dot word", "Move the .NET service and the .env file.", "Move the .NET service and the .env file."},
+		{"un prefix", "It was a unimportant detail.", "It was an unimportant detail."},
+		{"uninstall", "Run a uninstall first.", "Run an uninstall first."},
+		{"unit", "Add an unit test.", "Add a unit test."},
+		{"abbreviations", "an unimportant detail, i.e. nothing, e.g. typos", "An unimportant detail, i.e. nothing, e.g. typos"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Apply(tt.in); got != tt.want {
+				t.Errorf("Apply(%q): got %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWordsCase(t *testing.T) {
+	s, err := New([]Rule{{Pattern: `(?i)\b{words}\b`, Replace: "${word}", Words: map[string]string{"colour": "color"}}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := s.Apply("Colour and colour."); got != "Color and color." {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	if _, err := New([]Rule{{Name: "bad", Pattern: "("}}); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+	if _, err := New([]Rule{{Name: "nowords", Pattern: "x", Words: map[string]string{"a": "b"}}}); err == nil {
+		t.Error("expected error for words without {words}")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	os.WriteFile(path, []byte("- name: colour\n  pattern: \"\\\\bcolour\\\\b\"\n  replace: color\n"), 0600)
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	// A custom file replaces the default rules; built-in fixes still run.
+	if got := s.Apply("the colour went smooth"); got != "The color went smooth" {
+		t.Errorf("got %q", got)
+	}
+
+	if s, err := Load(""); err != nil || s == nil {
+		t.Errorf("empty path: got %v, %v", s, err)
+	}
+	if _, err := Load(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("expected error for missing file")
+	}
+	os.WriteFile(path, []byte("- name: [\n"), 0600)
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for invalid YAML")
+	}
+}
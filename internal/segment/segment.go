@@ -0,0 +1,156 @@
+// Package segment splits text into sentences and caches their polished
+// form, so that re-polishing an edited document only sends what changed.
+package segment
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Segment is one sentence or line. Sep is the whitespace that follows it.
+type Segment struct {
+	Text string
+	Sep  string
+}
+
+// abbreviations end in a period without ending the sentence (lower case,
+// without the final period).
+var abbreviations = map[string]bool{
+	"e.g": true, "i.e": true, "vs": true, "mr": true, "mrs": true, "ms": true, "dr": true,
+	"prof": true, "approx": true, "no": true, "fig": true, "sr": true, "sra": true, "srta": true,
+	"p.ej": true, "ej": true, "aprox": true, "núm": true, "pág": true, "ud": true, "uds": true,
+}
+
+// Split cuts text after sentence-ending punctuation followed by whitespace
+// and a capital, digit or opening mark, and at every line break. Leading
+// whitespace is dropped; otherwise Join(Split(text)) == text.
+func Split(text string) []Segment {
+	text = strings.TrimLeftFunc(text, unicode.IsSpace)
+	var segs []Segment
+	start := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+		end := i
+		for i < len(text) {
+			r, size := utf8.DecodeRuneInString(text[i:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			i += size
+		}
+		sep := text[end:i]
+		if i == len(text) || strings.Contains(sep, "\n") || endsSentence(text[start:end], text[i:]) {
+			segs = append(segs, Segment{Text: text[start:end], Sep: sep})
+			start = i
+		}
+	}
+	if start < len(text) {
+		segs = append(segs, Segment{Text: text[start:]})
+	}
+	return segs
+}
+
+// endsSentence reports whether sentence ends with terminal punctuation
+// (before any closing quotes or brackets) and next starts a new sentence.
+func endsSentence(sentence, next string) bool {
+	s := strings.TrimRight(sentence, `"'”’)]»`)
+	last, _ := utf8.DecodeLastRuneInString(s)
+	switch last {
+	case '!', '?', '…':
+	case '.':
+		word := s[strings.LastIndexFunc(s[:len(s)-1], unicode.IsSpace)+1 : len(s)-1]
+		if abbreviations[strings.ToLower(word)] || utf8.RuneCountInString(word) == 1 {
+			return false // "Dr. Smith", "J. Doe"
+		}
+	default:
+		return false
+	}
+	first, _ := utf8.DecodeRuneInString(next)
+	return unicode.IsUpper(first) || unicode.IsDigit(first) || strings.ContainsRune(`"'“‘(¿¡«`, first)
+}
+
+// Join reassembles segments.
+func Join(segs []Segment) string {
+	var b strings.Builder
+	for _, s := range segs {
+		b.WriteString(s.Text)
+		b.WriteString(s.Sep)
+	}
+	return b.String()
+}
+
+// Normalize collapses whitespace so reflowed sentences share a cache entry.
+func Normalize(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Key identifies sentence within scope, which must cover everything else
+// that affects its polish (caller, model, prompt, context).
+func Key(scope, sentence string) string {
+	sum := sha256.Sum256([]byte(scope + "\x00" + Normalize(sentence)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Cache is a fixed-size LRU map from Key to polished sentence. It is safe
+// for concurrent use.
+type Cache struct {
+	mu    sync.Mutex
+	max   int
+	order *list.List // front is most recently used
+	items map[string]*list.Element
+}
+
+type entry struct {
+	key, value string
+}
+
+// NewCache returns a Cache holding at most max entries.
+func NewCache(max int) *Cache {
+	return &Cache{max: max, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// Get returns the cached value for key.
+func (c *Cache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*entry).value, true
+}
+
+// Put stores value under key, evicting the least recently used entry if
+// the cache is full.
+func (c *Cache) Put(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*entry).value = value
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&entry{key: key, value: value})
+	if c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*entry).key)
+	}
+}
+
+// Len returns the number of cached entries.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,63 @@
+package segment
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"sentences", "We ship Friday. QA signs off Thursday! Any questions?", []string{"We ship Friday.", "QA signs off Thursday!", "Any questions?"}},
+		{"abbreviations", "Ask Dr. Smith, e.g. via chat. J. Doe agrees.", []string{"Ask Dr. Smith, e.g. via chat.", "J. Doe agrees."}},
+		{"lowercase after period", "Version 1.2 is out. see notes.", []string{"Version 1.2 is out. see notes."}},
+		{"lines", "Status:\n- build green\n- docs pending\n\nThanks", []string{"Status:", "- build green", "- docs pending", "Thanks"}},
+		{"quotes and spanish", `He said "done." ¿Lo revisas? Gracias.`, []string{`He said "done."`, "¿Lo revisas?", "Gracias."}},
+		{"no terminal punctuation", "just one line", []string{"just one line"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segs := Split(tt.text)
+			var got []string
+			for _, s := range segs {
+				got = append(got, s.Text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if Join(segs) != tt.text {
+				t.Errorf("Join: got %q", Join(segs))
+			}
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	if Key("s", "We ship  Friday.\n") != Key("s", "We ship Friday.") {
+		t.Error("whitespace changed the key")
+	}
+	if Key("a", "We ship Friday.") == Key("b", "We ship Friday.") {
+		t.Error("scope did not change the key")
+	}
+}
+
+func TestCache(t *testing.T) {
+	c := NewCache(2)
+	c.Put("a", "1")
+	c.Put("b", "2")
+	c.Get("a") // a is now the most recent
+	c.Put("c", "3")
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry was kept")
+	}
+	if v, ok := c.Get("a"); !ok || v != "1" {
+		t.Errorf("a: got %q, %v", v, ok)
+	}
+	c.Put("a", "4")
+	if v, _ := c.Get("a"); v != "4" || c.Len() != 2 {
+		t.Errorf("update: got %q, len %d", v, c.Len())
+	}
+}
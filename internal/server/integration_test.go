@@ -4,16 +4,23 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/mlorentedev/pollex/internal/adapter"
+	"github.com/mlorentedev/pollex/internal/config"
+	"github.com/mlorentedev/pollex/internal/experiment"
+	"github.com/mlorentedev/pollex/internal/history"
+	"github.com/mlorentedev/pollex/internal/router"
+	"github.com/mlorentedev/pollex/pkg/client"
 )
 
 type failingAdapter struct{}
@@ -41,18 +48,34 @@ type healthResponse struct {
 }
 
 type errorResponse struct {
-	Error string `json:"error"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+	Retryable bool   `json:"retryable"`
 }
 
 func newTestServer(t *testing.T, adapters map[string]adapter.LLMAdapter, models []adapter.ModelInfo) *httptest.Server {
 	t.Helper()
-	h := SetupMux(adapters, models, "test system prompt", "", "test")
+	h := SetupMux(Options{
+		Adapters:       adapters,
+		Models:         models,
+		SystemPrompt:   "test system prompt",
+		Version:        "test",
+		RequestTimeout: 5 * time.Second,
+	})
 	return httptest.NewServer(h)
 }
 
 func newTestServerWithAPIKey(t *testing.T, adapters map[string]adapter.LLMAdapter, models []adapter.ModelInfo, apiKey string) *httptest.Server {
 	t.Helper()
-	h := SetupMux(adapters, models, "test system prompt", apiKey, "test")
+	h := SetupMux(Options{
+		Adapters:       adapters,
+		Models:         models,
+		SystemPrompt:   "test system prompt",
+		APIKeys:        map[string]string{"default": apiKey},
+		Version:        "test",
+		RequestTimeout: 5 * time.Second,
+	})
 	return httptest.NewServer(h)
 }
 
@@ -163,8 +186,8 @@ func TestIntegration_OptionsPreflightCORS(t *testing.T) {
 	if resp.StatusCode != http.StatusNoContent {
 		t.Errorf("status: got %d, want %d", resp.StatusCode, http.StatusNoContent)
 	}
-	if got := resp.Header.Get("Access-Control-Allow-Methods"); got != "GET, POST, OPTIONS" {
-		t.Errorf("Allow-Methods: got %q, want %q", got, "GET, POST, OPTIONS")
+	if got := resp.Header.Get("Access-Control-Allow-Methods"); got != "GET, POST, DELETE, OPTIONS" {
+		t.Errorf("Allow-Methods: got %q, want %q", got, "GET, POST, DELETE, OPTIONS")
 	}
 	if got := resp.Header.Get("Access-Control-Allow-Headers"); !strings.Contains(got, "X-API-Key") {
 		t.Errorf("Allow-Headers: got %q, want to contain X-API-Key", got)
@@ -269,8 +292,14 @@ func TestIntegration_AdapterErrorPropagation(t *testing.T) {
 	if err := json.NewDecoder(resp.Body).Decode(&er); err != nil {
 		t.Fatalf("decode: %v", err)
 	}
-	if !strings.Contains(er.Error, "intentional failure") {
-		t.Errorf("error: got %q, want to contain %q", er.Error, "intentional failure")
+	if er.Code != "upstream_error" {
+		t.Errorf("code: got %q, want %q", er.Code, "upstream_error")
+	}
+	if !strings.Contains(er.Message, "intentional failure") {
+		t.Errorf("message: got %q, want to contain %q", er.Message, "intentional failure")
+	}
+	if er.RequestID != resp.Header.Get("X-Request-ID") {
+		t.Errorf("request_id: got %q, want %q", er.RequestID, resp.Header.Get("X-Request-ID"))
 	}
 }
 
@@ -334,8 +363,11 @@ func TestIntegration_TextTooLong(t *testing.T) {
 	if err := json.NewDecoder(resp.Body).Decode(&er); err != nil {
 		t.Fatalf("decode: %v", err)
 	}
-	if !strings.Contains(er.Error, "too long") {
-		t.Errorf("error: got %q, want to contain 'too long'", er.Error)
+	if er.Code != "text_too_long" {
+		t.Errorf("code: got %q, want %q", er.Code, "text_too_long")
+	}
+	if !strings.Contains(er.Message, "too long") {
+		t.Errorf("message: got %q, want to contain 'too long'", er.Message)
 	}
 }
 
@@ -493,3 +525,605 @@ func TestIntegration_MetricsAfterPolish(t *testing.T) {
 		t.Error("missing pollex_input_chars")
 	}
 }
+
+func TestIntegration_RequestDeadline(t *testing.T) {
+	adapters := map[string]adapter.LLMAdapter{
+		"mock": &adapter.MockAdapter{Delay: 5 * time.Second},
+	}
+	models := []adapter.ModelInfo{{ID: "mock", Name: "Mock", Provider: "mock"}}
+	ts := httptest.NewServer(SetupMux(Options{
+		Adapters:       adapters,
+		Models:         models,
+		SystemPrompt:   "test system prompt",
+		Version:        "test",
+		RequestTimeout: 50 * time.Millisecond,
+	}))
+	defer ts.Close()
+
+	body, _ := json.Marshal(polishRequest{Text: "hello", ModelID: "mock"})
+	resp, err := http.Post(ts.URL+"/api/polish", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("status: got %d, want %d", resp.StatusCode, http.StatusGatewayTimeout)
+	}
+
+	var er errorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&er); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if er.Code != "upstream_timeout" {
+		t.Errorf("code: got %q, want %q", er.Code, "upstream_timeout")
+	}
+	if !er.Retryable {
+		t.Error("retryable: got false, want true")
+	}
+}
+
+// routeBodies overrides the default polish body for spec paths that take a
+// different request.
+var routeBodies = map[string]string{
+	"/feedback": `{"request_id":"{id}","rating":"accepted"}`,
+}
+
+func TestIntegration_OpenAPIRoutes(t *testing.T) {
+	store, err := history.Open(filepath.Join(t.TempDir(), "history.jsonl"), 0, 0)
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	defer store.Close()
+
+	adapters := map[string]adapter.LLMAdapter{"mock": &adapter.MockAdapter{}}
+	experiments, err := experiment.New([]config.Experiment{
+		{Name: "e1", Variants: []config.ExperimentVariant{{Name: "control"}}},
+	}, adapters)
+	if err != nil {
+		t.Fatalf("experiments: %v", err)
+	}
+
+	ts := httptest.NewServer(SetupMux(Options{
+		Adapters:       adapters,
+		Models:         []adapter.ModelInfo{{ID: "mock", Name: "Mock (dev)", Provider: "mock"}},
+		SystemPrompt:   "test system prompt",
+		APIKeys:        map[string]string{"default": "secret-key"},
+		Version:        "test",
+		RequestTimeout: 5 * time.Second,
+		History:        store,
+		Experiments:    experiments,
+		AdminKeys:      []string{"default"},
+	}))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/openapi.json")
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status: got %d, want %d (spec must be public)", resp.StatusCode, http.StatusOK)
+	}
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&spec); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(spec.Paths) == 0 {
+		t.Fatal("spec has no paths")
+	}
+
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			if method == "parameters" {
+				continue
+			}
+			for _, prefix := range []string{"/api/v1", "/api"} {
+				// Polish first so path parameters and bodies can name a live history entry.
+				id := polishForID(t, ts.URL, "secret-key")
+				target := prefix + strings.NewReplacer("{id}", id, "{name}", "e1").Replace(path)
+				body, ok := routeBodies[path]
+				if !ok {
+					body = `{"text":"hi","model_id":"mock"}`
+				}
+				req, _ := http.NewRequest(strings.ToUpper(method), ts.URL+target, strings.NewReader(strings.ReplaceAll(body, "{id}", id)))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("X-API-Key", "secret-key")
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatalf("%s %s: %v", method, target, err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode < 200 || resp.StatusCode > 299 {
+					t.Errorf("%s %s: got %d, want 2xx", strings.ToUpper(method), target, resp.StatusCode)
+				}
+			}
+		}
+	}
+}
+
+// polishForID polishes a short text and returns the request ID, which is
+// also the ID of the resulting history entry.
+func polishForID(t *testing.T, baseURL, apiKey string) string {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, baseURL+"/api/v1/polish", strings.NewReader(`{"text":"hi","model_id":"mock"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", apiKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("polish: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("polish: got %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	return resp.Header.Get("X-Request-ID")
+}
+
+func TestIntegration_GoClient(t *testing.T) {
+	ts := newTestServerWithAPIKey(t,
+		map[string]adapter.LLMAdapter{"mock": &adapter.MockAdapter{}},
+		[]adapter.ModelInfo{{ID: "mock", Name: "Mock (dev)", Provider: "mock"}},
+		"secret-key")
+	defer ts.Close()
+
+	c := client.New(ts.URL, client.WithAPIKey("secret-key"))
+	ctx := context.Background()
+
+	models, err := c.Models(ctx)
+	if err != nil {
+		t.Fatalf("Models: %v", err)
+	}
+	if len(models) != 1 || models[0].ID != "mock" {
+		t.Fatalf("models: got %+v", models)
+	}
+
+	pr, err := c.Polish(ctx, client.PolishRequest{Text: "hello world", ModelID: models[0].ID})
+	if err != nil {
+		t.Fatalf("Polish: %v", err)
+	}
+	if pr.Polished != "Hello world" {
+		t.Errorf("polished: got %q, want %q", pr.Polished, "Hello world")
+	}
+
+	_, err = c.Polish(ctx, client.PolishRequest{Text: "hello", ModelID: "nope"})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error: got %T, want *client.Error", err)
+	}
+	if apiErr.Code != "unknown_model" {
+		t.Errorf("code: got %q, want %q", apiErr.Code, "unknown_model")
+	}
+	if apiErr.RequestID == "" {
+		t.Error("request_id: got empty")
+	}
+}
+
+func TestIntegration_LintWithoutModel(t *testing.T) {
+	// The only backend fails, yet lint still answers.
+	ts := newTestServerWithAPIKey(t,
+		map[string]adapter.LLMAdapter{"failing": &failingAdapter{}},
+		[]adapter.ModelInfo{{ID: "failing", Name: "Failing", Provider: "mock"}},
+		"secret-key")
+	defer ts.Close()
+
+	c := client.New(ts.URL, client.WithAPIKey("secret-key"))
+	report, err := c.Lint(context.Background(), "We will leverage the the cache.")
+	if err != nil {
+		t.Fatalf("Lint: %v", err)
+	}
+	var got []string
+	for _, f := range report.Findings {
+		got = append(got, f.Rule)
+	}
+	if strings.Join(got, ",") != "banned_word,repeated_word" {
+		t.Errorf("findings: got %+v", report.Findings)
+	}
+	if report.Stats.Words != 6 || report.Stats.Sentences != 1 {
+		t.Errorf("stats: got %+v", report.Stats)
+	}
+
+	_, err = c.Lint(context.Background(), "")
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != "text_required" {
+		t.Errorf("empty text: got %v", err)
+	}
+}
+
+func TestIntegration_PolishWithLint(t *testing.T) {
+	ts := defaultTestServer(t)
+	defer ts.Close()
+
+	c := client.New(ts.URL)
+	pr, err := c.Polish(context.Background(), client.PolishRequest{Text: "we delve in", ModelID: "mock", Lint: true})
+	if err != nil {
+		t.Fatalf("Polish: %v", err)
+	}
+	if pr.Lint == nil || len(pr.Lint.Input.Findings) != 1 || pr.Lint.Output.Stats.Words != 3 {
+		t.Errorf("lint: got %+v", pr.Lint)
+	}
+
+	pr, _ = c.Polish(context.Background(), client.PolishRequest{Text: "we delve in", ModelID: "mock"})
+	if pr.Lint != nil {
+		t.Errorf("lint without asking: got %+v", pr.Lint)
+	}
+}
+
+func TestIntegration_PolishWithExplain(t *testing.T) {
+	ts := defaultTestServer(t)
+	defer ts.Close()
+
+	// The mock ignores the JSON instructions, so the edits are computed.
+	c := client.New(ts.URL)
+	pr, err := c.Polish(context.Background(), client.PolishRequest{Text: "hello world", ModelID: "mock", Explain: true})
+	if err != nil {
+		t.Fatalf("Polish: %v", err)
+	}
+	want := client.Edit{Start: 0, End: 5, Original: "hello", Replacement: "Hello"}
+	if pr.Explain == nil || pr.Explain.Source != "diff" || len(pr.Explain.Edits) != 1 || pr.Explain.Edits[0] != want {
+		t.Errorf("explain: got %+v", pr.Explain)
+	}
+}
+
+func TestIntegration_HistoryScopedByKey(t *testing.T) {
+	store, err := history.Open(filepath.Join(t.TempDir(), "history.jsonl"), 0, 0)
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	defer store.Close()
+
+	ts := httptest.NewServer(SetupMux(Options{
+		Adapters:       map[string]adapter.LLMAdapter{"mock": &adapter.MockAdapter{}},
+		Models:         []adapter.ModelInfo{{ID: "mock", Name: "Mock (dev)", Provider: "mock"}},
+		SystemPrompt:   "test system prompt",
+		APIKeys:        map[string]string{"alice": "k-alice", "bob": "k-bob"},
+		Version:        "test",
+		RequestTimeout: 5 * time.Second,
+		History:        store,
+	}))
+	defer ts.Close()
+
+	aliceID := polishForID(t, ts.URL, "k-alice")
+	polishForID(t, ts.URL, "k-bob")
+
+	list := func(key, query string) (page struct {
+		Entries []history.Entry `json:"entries"`
+		Total   int             `json:"total"`
+	}) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/history"+query, nil)
+		req.Header.Set("X-API-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("list: got %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return page
+	}
+
+	page := list("k-alice", "")
+	if page.Total != 1 || len(page.Entries) != 1 {
+		t.Fatalf("alice history: got total=%d entries=%d, want 1", page.Total, len(page.Entries))
+	}
+	e := page.Entries[0]
+	if e.ID != aliceID || e.Key != "alice" || e.Input != "hi" || e.Output != "Hi" || e.Mode != "polish" {
+		t.Errorf("entry: got %+v", e)
+	}
+	if got := list("k-alice", "?q=nomatch").Total; got != 0 {
+		t.Errorf("search total: got %d, want 0", got)
+	}
+
+	// Bob cannot see or delete Alice's entry.
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/api/v1/history/"+aliceID, nil)
+	req.Header.Set("X-API-Key", "k-bob")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("cross-key delete: got %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, ts.URL+"/api/v1/history", nil)
+	req.Header.Set("X-API-Key", "k-alice")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	resp.Body.Close()
+
+	if got := list("k-alice", "").Total; got != 0 {
+		t.Errorf("alice after clear: got %d, want 0", got)
+	}
+	if got := list("k-bob", "").Total; got != 1 {
+		t.Errorf("bob after alice clear: got %d, want 1", got)
+	}
+}
+
+func TestIntegration_HistoryDisabled(t *testing.T) {
+	ts := defaultTestServer(t)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/v1/history")
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status: got %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestIntegration_FeedbackAndExport(t *testing.T) {
+	store, err := history.Open(filepath.Join(t.TempDir(), "history.jsonl"), 0, 0)
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	defer store.Close()
+
+	ts := httptest.NewServer(SetupMux(Options{
+		Adapters:       map[string]adapter.LLMAdapter{"mock": &adapter.MockAdapter{}},
+		Models:         []adapter.ModelInfo{{ID: "mock", Name: "Mock (dev)", Provider: "mock"}},
+		SystemPrompt:   "test system prompt",
+		APIKeys:        map[string]string{"alice": "k-alice", "bob": "k-bob"},
+		Version:        "test",
+		RequestTimeout: 5 * time.Second,
+		History:        store,
+	}))
+	defer ts.Close()
+
+	id := polishForID(t, ts.URL, "k-alice")
+	polishForID(t, ts.URL, "k-alice") // unrated, must not be exported
+
+	send := func(key, body string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/feedback", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("feedback: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		body string
+		want int
+	}{
+		{"bad rating", "k-alice", `{"request_id":"` + id + `","rating":"meh"}`, http.StatusBadRequest},
+		{"unknown id", "k-alice", `{"request_id":"nope","rating":"accepted"}`, http.StatusNotFound},
+		{"other key", "k-bob", `{"request_id":"` + id + `","rating":"accepted"}`, http.StatusNotFound},
+		{"corrected", "k-alice", `{"request_id":"` + id + `","rating":"rejected","final":"Hi there.","comment":"too terse"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := send(tt.key, tt.body); got != tt.want {
+				t.Errorf("status: got %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/feedback/export", nil)
+	req.Header.Set("X-API-Key", "k-alice")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("Content-Type: got %q", got)
+	}
+
+	data, _ := io.ReadAll(resp.Body)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("export lines: got %d, want 1:\n%s", len(lines), data)
+	}
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("decode record: %v", err)
+	}
+	want := map[string]any{"id": id, "input": "hi", "output": "Hi", "final": "Hi there.", "rating": "rejected", "model": "mock"}
+	for k, v := range want {
+		if rec[k] != v {
+			t.Errorf("%s: got %v, want %v", k, rec[k], v)
+		}
+	}
+	if rec["prompt"] == "" || rec["prompt"] == nil {
+		t.Error("prompt version missing from export")
+	}
+}
+
+func TestIntegration_Experiments(t *testing.T) {
+	store, err := history.Open(filepath.Join(t.TempDir(), "history.jsonl"), 0, 0)
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	defer store.Close()
+
+	adapters := map[string]adapter.LLMAdapter{"mock": &adapter.MockAdapter{}}
+	experiments, err := experiment.New([]config.Experiment{
+		// A single variant puts every key in it, keeping the test deterministic.
+		{Name: "prompt-v2", Running: true, Variants: []config.ExperimentVariant{{Name: "v2", Weight: 1}}},
+		{Name: "later", Variants: []config.ExperimentVariant{{Name: "control"}}},
+	}, adapters)
+	if err != nil {
+		t.Fatalf("experiments: %v", err)
+	}
+
+	ts := httptest.NewServer(SetupMux(Options{
+		Adapters:       adapters,
+		Models:         []adapter.ModelInfo{{ID: "mock", Name: "Mock (dev)", Provider: "mock"}},
+		SystemPrompt:   "test system prompt",
+		APIKeys:        map[string]string{"admin": "k-admin", "bob": "k-bob"},
+		Version:        "test",
+		RequestTimeout: 5 * time.Second,
+		History:        store,
+		Experiments:    experiments,
+		AdminKeys:      []string{"admin"},
+	}))
+	defer ts.Close()
+
+	do := func(method, path, key, body string, out any) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		if out != nil {
+			json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode
+	}
+
+	var pr client.PolishResponse
+	if code := do(http.MethodPost, "/api/v1/polish", "k-bob", `{"text":"hi","model_id":"mock"}`, &pr); code != http.StatusOK {
+		t.Fatalf("polish: got %d", code)
+	}
+	if pr.Experiment == nil || pr.Experiment.Name != "prompt-v2" || pr.Experiment.Variant != "v2" {
+		t.Fatalf("experiment: got %+v", pr.Experiment)
+	}
+	entries, _ := store.Find(history.Query{Key: "bob"})
+	if len(entries) != 1 || entries[0].Variant != "v2" {
+		t.Fatalf("history: got %+v", entries)
+	}
+	if code := do(http.MethodPost, "/api/v1/feedback", "k-bob", `{"request_id":"`+entries[0].ID+`","rating":"accepted"}`, nil); code != http.StatusOK {
+		t.Fatalf("feedback: got %d", code)
+	}
+
+	if code := do(http.MethodGet, "/api/v1/experiments", "k-bob", "", nil); code != http.StatusForbidden {
+		t.Errorf("non-admin list: got %d, want 403", code)
+	}
+	var list struct {
+		Experiments []struct {
+			Name     string `json:"name"`
+			Running  bool   `json:"running"`
+			Variants []struct {
+				Name           string   `json:"name"`
+				Requests       int      `json:"requests"`
+				Accepted       int      `json:"accepted"`
+				AcceptanceRate *float64 `json:"acceptance_rate"`
+			} `json:"variants"`
+		} `json:"experiments"`
+	}
+	if code := do(http.MethodGet, "/api/v1/experiments", "k-admin", "", &list); code != http.StatusOK {
+		t.Fatalf("list: got %d", code)
+	}
+	if len(list.Experiments) != 2 || !list.Experiments[0].Running {
+		t.Fatalf("list: got %+v", list)
+	}
+	v := list.Experiments[0].Variants[0]
+	if v.Requests != 1 || v.Accepted != 1 || v.AcceptanceRate == nil || *v.AcceptanceRate != 1 {
+		t.Errorf("variant stats: got %+v", v)
+	}
+
+	if code := do(http.MethodPost, "/api/v1/experiments/later/start", "k-admin", "", nil); code != http.StatusConflict {
+		t.Errorf("start while running: got %d, want 409", code)
+	}
+	if code := do(http.MethodPost, "/api/v1/experiments/nope/stop", "k-admin", "", nil); code != http.StatusNotFound {
+		t.Errorf("stop unknown: got %d, want 404", code)
+	}
+	if code := do(http.MethodPost, "/api/v1/experiments/prompt-v2/stop", "k-admin", "", nil); code != http.StatusOK {
+		t.Errorf("stop: got %d, want 200", code)
+	}
+
+	pr = client.PolishResponse{}
+	do(http.MethodPost, "/api/v1/polish", "k-bob", `{"text":"hi","model_id":"mock"}`, &pr)
+	if pr.Experiment != nil {
+		t.Errorf("experiment after stop: got %+v, want none", pr.Experiment)
+	}
+}
+
+func TestIntegration_Routing(t *testing.T) {
+	adapters := map[string]adapter.LLMAdapter{"local": &adapter.MockAdapter{}, "cloud": &adapter.MockAdapter{}}
+	models := []adapter.ModelInfo{{ID: "local", Provider: "mock"}, {ID: "cloud", Provider: "mock"}}
+	rules := []config.RouteRule{
+		{Name: "short-local", MaxChars: 20, Models: []string{"local"}},
+		{Name: "long-cloud", MinChars: 21, Models: []string{"cloud"}},
+	}
+	rt, err := router.New(rules, adapters, models, func(m string) bool { return m == "cloud" }, []string{"legal"})
+	if err != nil {
+		t.Fatalf("router: %v", err)
+	}
+
+	ts := httptest.NewServer(SetupMux(Options{
+		Adapters:       adapters,
+		Models:         models,
+		SystemPrompt:   "test system prompt",
+		APIKeys:        map[string]string{"alice": "k-alice", "legal": "k-legal"},
+		Version:        "test",
+		RequestTimeout: 5 * time.Second,
+		Router:         rt,
+	}))
+	defer ts.Close()
+
+	long := strings.Repeat("long text ", 5)
+	tests := []struct {
+		name       string
+		key        string
+		body       string
+		wantStatus int
+		wantModel  string
+		wantRule   string
+	}{
+		{"short auto", "k-alice", `{"text":"short"}`, http.StatusOK, "local", "short-local"},
+		{"long auto", "k-alice", `{"text":"` + long + `","model_id":"auto"}`, http.StatusOK, "cloud", "long-cloud"},
+		{"explicit model", "k-alice", `{"text":"short","model_id":"cloud"}`, http.StatusOK, "cloud", ""},
+		{"local-only key routed", "k-legal", `{"text":"` + long + `"}`, http.StatusOK, "local", "default"},
+		{"local-only key explicit remote", "k-legal", `{"text":"short","model_id":"cloud"}`, http.StatusForbidden, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/polish", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-API-Key", tt.key)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status: got %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var pr client.PolishResponse
+			if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if pr.Model != tt.wantModel {
+				t.Errorf("model: got %q, want %q", pr.Model, tt.wantModel)
+			}
+			gotRule := ""
+			if pr.Route != nil {
+				gotRule = pr.Route.Rule
+			}
+			if gotRule != tt.wantRule {
+				t.Errorf("rule: got %q, want %q", gotRule, tt.wantRule)
+			}
+		})
+	}
+}
@@ -7,18 +7,119 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/mlorentedev/pollex/internal/adapter"
+	"github.com/mlorentedev/pollex/internal/config"
+	"github.com/mlorentedev/pollex/internal/experiment"
+	"github.com/mlorentedev/pollex/internal/glossary"
 	"github.com/mlorentedev/pollex/internal/handler"
+	"github.com/mlorentedev/pollex/internal/history"
+	"github.com/mlorentedev/pollex/internal/injection"
+	"github.com/mlorentedev/pollex/internal/lint"
 	"github.com/mlorentedev/pollex/internal/middleware"
+	"github.com/mlorentedev/pollex/internal/openapi"
+	"github.com/mlorentedev/pollex/internal/prompt"
+	"github.com/mlorentedev/pollex/internal/rank"
+	"github.com/mlorentedev/pollex/internal/router"
+	"github.com/mlorentedev/pollex/internal/segment"
+	"github.com/mlorentedev/pollex/internal/shadow"
+	"github.com/mlorentedev/pollex/internal/supervisor"
+	"github.com/mlorentedev/pollex/internal/warmup"
 )
 
+// apiPrefixes lists the mount points for the API routes. /api/v1 is canonical;
+// the unversioned /api prefix is kept for clients that predate it.
+var apiPrefixes = []string{"/api/v1", "/api"}
+
+// Options configures SetupMux.
+type Options struct {
+	Adapters       map[string]adapter.LLMAdapter
+	Models         []adapter.ModelInfo
+	SystemPrompt   string
+	Prompts        *prompt.Set       // nil sends SystemPrompt verbatim
+	Glossary       *glossary.Set     // nil disables glossary checks
+	Linter         *lint.Linter      // nil uses the default rules
+	Ranker         *rank.Ranker      // nil ranks alternatives with defaults
+	APIKeys        map[string]string // name → key; empty disables auth
+	Version        string
+	RequestTimeout time.Duration
+	History        *history.Store         // nil disables history and feedback
+	Router         *router.Router         // nil requires model_id on every polish
+	Experiments    *experiment.Manager    // nil disables experiments
+	AdminKeys      []string               // key names allowed on admin endpoints
+	Shadow         *shadow.Mirror         // nil disables shadow traffic
+	Injection      *injection.Detector    // nil disables prompt injection checks
+	Segments       *segment.Cache         // nil disables incremental polishing
+	Supervisor     *supervisor.Supervisor // nil omits process state from health
+	Warmer         *warmup.Warmer         // nil means always ready
+}
+
 // SetupMux wires handlers with the full middleware chain.
-func SetupMux(adapters map[string]adapter.LLMAdapter, models []adapter.ModelInfo, systemPrompt, apiKey, version string) http.Handler {
+func SetupMux(opts Options) http.Handler {
+	linter := opts.Linter
+	if linter == nil {
+		linter = lint.New(lint.Options{})
+	}
+
+	ranker := opts.Ranker
+	if ranker == nil {
+		ranker = rank.New(config.Alternatives{}, linter)
+	}
+
+	polishOpts := []handler.PolishOption{handler.WithLinter(linter), handler.WithRanker(ranker)}
+	if opts.History != nil {
+		polishOpts = append(polishOpts, handler.WithHistory(opts.History))
+	}
+	if opts.Router != nil {
+		polishOpts = append(polishOpts, handler.WithRouter(opts.Router))
+	}
+	if opts.Experiments != nil {
+		polishOpts = append(polishOpts, handler.WithExperiments(opts.Experiments))
+	}
+	if opts.Prompts != nil {
+		polishOpts = append(polishOpts, handler.WithPrompts(opts.Prompts))
+	}
+	if opts.Glossary != nil {
+		polishOpts = append(polishOpts, handler.WithGlossary(opts.Glossary))
+	}
+	if opts.Shadow != nil {
+		polishOpts = append(polishOpts, handler.WithShadow(opts.Shadow))
+	}
+	if opts.Injection != nil {
+		polishOpts = append(polishOpts, handler.WithInjection(opts.Injection))
+	}
+	if opts.Segments != nil {
+		polishOpts = append(polishOpts, handler.WithSegments(opts.Segments))
+	}
+
+	var healthOpts []handler.HealthOption
+	if opts.Supervisor != nil {
+		healthOpts = append(healthOpts, handler.WithSupervisor(opts.Supervisor))
+	}
+	if opts.Warmer != nil {
+		healthOpts = append(healthOpts, handler.WithWarmer(opts.Warmer))
+	}
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/api/health", handler.Health(adapters, version))
-	mux.HandleFunc("/api/models", handler.Models(models))
-	mux.HandleFunc("/api/polish", handler.Polish(adapters, systemPrompt))
+	for _, prefix := range apiPrefixes {
+		mux.HandleFunc(prefix+"/health", handler.Health(opts.Adapters, opts.Version, healthOpts...))
+		mux.HandleFunc(prefix+"/ready", handler.Ready(opts.Warmer))
+		mux.HandleFunc(prefix+"/models", handler.Models(opts.Models))
+		mux.HandleFunc(prefix+"/polish", handler.Polish(opts.Adapters, opts.SystemPrompt, polishOpts...))
+		mux.HandleFunc(prefix+"/lint", handler.Lint(linter))
+		if opts.History != nil {
+			mux.HandleFunc(prefix+"/history", handler.History(opts.History))
+			mux.HandleFunc(prefix+"/history/{id}", handler.HistoryEntry(opts.History))
+			mux.HandleFunc(prefix+"/feedback", handler.Feedback(opts.History))
+			mux.HandleFunc(prefix+"/feedback/export", handler.FeedbackExport(opts.History))
+		}
+		if opts.Experiments != nil {
+			mux.HandleFunc(prefix+"/experiments", handler.Experiments(opts.Experiments, opts.History, opts.AdminKeys))
+			mux.HandleFunc(prefix+"/experiments/{name}/start", handler.ExperimentControl(opts.Experiments, opts.History, opts.AdminKeys, true))
+			mux.HandleFunc(prefix+"/experiments/{name}/stop", handler.ExperimentControl(opts.Experiments, opts.History, opts.AdminKeys, false))
+		}
+	}
+	mux.HandleFunc("/api/openapi.json", openapi.Handler())
 	mux.Handle("/metrics", promhttp.Handler())
 
 	rl := middleware.NewRateLimiter(10, time.Minute)
-	return middleware.Chain(mux, rl, apiKey)
+	return middleware.Chain(mux, rl, opts.APIKeys, opts.RequestTimeout)
 }
@@ -0,0 +1,313 @@
+// Package shadow mirrors a sample of polish requests to a candidate model in
+// the background and records both results side by side, so a model swap can
+// be judged on real traffic before it is made.
+package shadow
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mlorentedev/pollex/internal/adapter"
+	"github.com/mlorentedev/pollex/internal/diff"
+	"github.com/mlorentedev/pollex/internal/metrics"
+)
+
+// Options configures a Mirror.
+type Options struct {
+	Adapter     adapter.LLMAdapter // the candidate
+	Model       string             // candidate label in records and metrics
+	Percent     float64            // share of requests mirrored, 0–100
+	Concurrency int                // shadow calls in flight; extra samples are dropped
+	Timeout     time.Duration      // per shadow call
+	Path        string             // JSONL output
+	MaxEntries  int                // records kept in Path; 0 = unlimited
+	MaxAge      time.Duration      // records older than this are dropped; 0 = forever
+}
+
+// Request is a completed polish offered for mirroring. The candidate gets
+// the same system prompt, examples and reference as the primary did.
+type Request struct {
+	ID           string
+	Key          string
+	Model        string
+	Input        string
+	Output       string
+	ElapsedMs    int64
+	SystemPrompt string
+	Examples     []adapter.Example
+	Reference    adapter.Reference
+}
+
+// Record is one line of the shadow file.
+type Record struct {
+	ID              string    `json:"id"`
+	Time            time.Time `json:"time"`
+	Key             string    `json:"key"`
+	Model           string    `json:"model"`
+	Shadow          string    `json:"shadow"`
+	Input           string    `json:"input"`
+	Output          string    `json:"output"`
+	ShadowOutput    string    `json:"shadow_output,omitempty"`
+	ElapsedMs       int64     `json:"elapsed_ms"`
+	ShadowElapsedMs int64     `json:"shadow_elapsed_ms"`
+	Similarity      *float64  `json:"similarity,omitempty"` // word-level, 0–1; absent on error
+	Error           string    `json:"error,omitempty"`
+}
+
+// Mirror sends sampled requests to the candidate. Submit never blocks on the
+// candidate, so the primary request's latency and result are unaffected.
+//
+// Retention works like the history store's: records past the limits stay in
+// the file as dead lines until they reach a tenth of the live ones, and then
+// the file is rewritten without them.
+type Mirror struct {
+	opts   Options
+	sample func() bool
+	slots  chan struct{}
+	wg     sync.WaitGroup
+	now    func() time.Time
+
+	mu    sync.Mutex
+	file  *os.File
+	times []time.Time // of live records, oldest first
+	dead  int         // leading lines in the file that are no longer live
+}
+
+// New opens (or creates) opts.Path for appending and applies retention to
+// the records already in it.
+func New(opts Options) (*Mirror, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Minute
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.Path), 0750); err != nil {
+		return nil, fmt.Errorf("shadow: create dir: %w", err)
+	}
+	percent := opts.Percent
+	m := &Mirror{
+		opts:   opts,
+		sample: func() bool { return rand.Float64()*100 < percent },
+		slots:  make(chan struct{}, opts.Concurrency),
+		now:    time.Now,
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	m.prune()
+	if err := m.rewrite(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Submit mirrors req to the candidate if it is sampled and a slot is free.
+func (m *Mirror) Submit(req Request) {
+	if !m.sample() {
+		return
+	}
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		metrics.ShadowRequests.WithLabelValues(m.opts.Model, "dropped").Inc()
+		return
+	}
+
+	m.wg.Go(func() {
+		defer func() { <-m.slots }()
+		m.run(req)
+	})
+}
+
+func (m *Mirror) run(req Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.opts.Timeout)
+	defer cancel()
+	ctx = adapter.WithReference(adapter.WithExamples(ctx, req.Examples), req.Reference)
+
+	start := time.Now()
+	out, err := m.opts.Adapter.Polish(ctx, req.Input, req.SystemPrompt)
+	elapsed := time.Since(start)
+
+	rec := Record{
+		ID:              req.ID,
+		Time:            start.UTC(),
+		Key:             req.Key,
+		Model:           req.Model,
+		Shadow:          m.opts.Model,
+		Input:           req.Input,
+		Output:          req.Output,
+		ElapsedMs:       req.ElapsedMs,
+		ShadowElapsedMs: elapsed.Milliseconds(),
+	}
+	if err != nil {
+		rec.Error = err.Error()
+		metrics.ShadowRequests.WithLabelValues(m.opts.Model, "error").Inc()
+		slog.Warn("shadow polish failed", "request_id", req.ID, "shadow", m.opts.Model, "error", err)
+	} else {
+		sim := diff.Similarity(req.Output, out)
+		rec.ShadowOutput = out
+		rec.Similarity = &sim
+		metrics.ShadowRequests.WithLabelValues(m.opts.Model, "ok").Inc()
+		metrics.ShadowSimilarity.WithLabelValues(m.opts.Model).Observe(sim)
+		metrics.ShadowDuration.WithLabelValues(m.opts.Model).Observe(elapsed.Seconds())
+	}
+
+	if err := m.write(rec); err != nil {
+		slog.Error("shadow write failed", "error", err)
+	}
+}
+
+func (m *Mirror) write(rec Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("shadow: marshal: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("shadow: write: %w", err)
+	}
+	m.times = append(m.times, rec.Time)
+	m.prune()
+	if m.dead == 0 || m.dead*10 < len(m.times) {
+		return nil
+	}
+	return m.rewrite()
+}
+
+// load reads the times of the records already in the file.
+func (m *Mirror) load() error {
+	f, err := os.Open(m.opts.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("shadow: open: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		t, ok := recordTime(sc.Bytes())
+		if !ok {
+			// A torn final line after a crash is expected; rewrite drops it.
+			continue
+		}
+		m.times = append(m.times, t)
+	}
+	return sc.Err()
+}
+
+// recordTime returns the time of a record line, or false if it doesn't parse.
+func recordTime(line []byte) (time.Time, bool) {
+	var rec struct {
+		Time time.Time `json:"time"`
+	}
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return time.Time{}, false
+	}
+	return rec.Time, true
+}
+
+// prune marks records beyond the retention limits dead. Caller holds mu
+// (or owns m exclusively).
+func (m *Mirror) prune() {
+	i := 0
+	if m.opts.MaxAge > 0 {
+		cutoff := m.now().Add(-m.opts.MaxAge)
+		for i < len(m.times) && m.times[i].Before(cutoff) {
+			i++
+		}
+	}
+	if n := m.opts.MaxEntries; n > 0 && len(m.times)-i > n {
+		i = len(m.times) - n
+	}
+	m.times = m.times[i:]
+	m.dead += i
+}
+
+// rewrite atomically replaces the file with its live records, dropping the
+// dead leading lines and any that don't parse, and reopens it for
+// appending. Caller holds mu (or owns m exclusively).
+func (m *Mirror) rewrite() error {
+	tmp, err := os.CreateTemp(filepath.Dir(m.opts.Path), ".shadow-*.jsonl")
+	if err != nil {
+		return fmt.Errorf("shadow: rewrite: %w", err)
+	}
+	if err := m.copyLive(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("shadow: rewrite: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("shadow: rewrite: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return fmt.Errorf("shadow: rewrite: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.opts.Path); err != nil {
+		return fmt.Errorf("shadow: rewrite: %w", err)
+	}
+
+	if m.file != nil {
+		m.file.Close()
+	}
+	m.file, err = os.OpenFile(m.opts.Path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("shadow: reopen: %w", err)
+	}
+	m.dead = 0
+	return nil
+}
+
+// copyLive writes the valid lines of the file after the first m.dead to dst.
+func (m *Mirror) copyLive(dst *os.File) error {
+	src, err := os.Open(m.opts.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	w := bufio.NewWriter(dst)
+	sc := bufio.NewScanner(src)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	skip := m.dead
+	for sc.Scan() {
+		if _, ok := recordTime(sc.Bytes()); !ok {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		w.Write(sc.Bytes())
+		w.WriteByte('\n')
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// Close waits for in-flight shadow calls and closes the file. Submit must not
+// be called afterwards.
+func (m *Mirror) Close() error {
+	m.wg.Wait()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.file.Close()
+}
@@ -0,0 +1,184 @@
+package shadow
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mlorentedev/pollex/internal/adapter"
+)
+
+type fakeAdapter struct {
+	out   string
+	err   error
+	block chan struct{} // if set, Polish waits for it
+
+	mu       sync.Mutex // guards the last call's recorded inputs
+	system   string
+	examples []adapter.Example
+	ref      adapter.Reference
+}
+
+func (f *fakeAdapter) Name() string    { return "fake" }
+func (f *fakeAdapter) Available() bool { return true }
+func (f *fakeAdapter) Polish(ctx context.Context, text, systemPrompt string) (string, error) {
+	if f.block != nil {
+		<-f.block
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.system = systemPrompt
+	f.examples = adapter.ExamplesFromContext(ctx)
+	f.ref, _ = adapter.ReferenceFromContext(ctx)
+	return f.out, f.err
+}
+
+func newTestMirror(t *testing.T, a adapter.LLMAdapter, concurrency int) (*Mirror, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "shadow.jsonl")
+	m, err := New(Options{Adapter: a, Model: "candidate", Percent: 100, Concurrency: concurrency, Path: path})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return m, path
+}
+
+func readRecords(t *testing.T, path string) []Record {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	var recs []Record
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r Record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("unmarshal %q: %v", sc.Text(), err)
+		}
+		recs = append(recs, r)
+	}
+	return recs
+}
+
+func TestMirrorRecordsBothOutputs(t *testing.T) {
+	m, path := newTestMirror(t, &fakeAdapter{out: "I went to a store."}, 1)
+	m.Submit(Request{ID: "req-1", Key: "alice", Model: "primary", Input: "i go to store", Output: "I went to the store.", ElapsedMs: 1200})
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	recs := readRecords(t, path)
+	if len(recs) != 1 {
+		t.Fatalf("records: got %d, want 1", len(recs))
+	}
+	r := recs[0]
+	if r.ID != "req-1" || r.Key != "alice" || r.Model != "primary" || r.Shadow != "candidate" {
+		t.Errorf("record identity: got %+v", r)
+	}
+	if r.Output != "I went to the store." || r.ShadowOutput != "I went to a store." || r.ElapsedMs != 1200 {
+		t.Errorf("record outputs: got %+v", r)
+	}
+	if r.Similarity == nil || *r.Similarity != 0.8 {
+		t.Errorf("similarity: got %v, want 0.8", r.Similarity)
+	}
+}
+
+func TestMirrorRecordsError(t *testing.T) {
+	m, path := newTestMirror(t, &fakeAdapter{err: errors.New("connection refused")}, 1)
+	m.Submit(Request{ID: "req-1", Input: "x", Output: "X"})
+	m.Close()
+
+	recs := readRecords(t, path)
+	if len(recs) != 1 || recs[0].Error == "" || recs[0].Similarity != nil {
+		t.Errorf("records: got %+v, want one with error and no similarity", recs)
+	}
+}
+
+func TestMirrorSampling(t *testing.T) {
+	m, path := newTestMirror(t, &fakeAdapter{out: "X"}, 4)
+	n := 0
+	m.sample = func() bool { n++; return n%2 == 0 }
+	for range 4 {
+		m.Submit(Request{Input: "x", Output: "X"})
+	}
+	m.Close()
+
+	if recs := readRecords(t, path); len(recs) != 2 {
+		t.Errorf("records: got %d, want 2", len(recs))
+	}
+}
+
+func TestMirrorDropsWhenBusy(t *testing.T) {
+	block := make(chan struct{})
+	m, path := newTestMirror(t, &fakeAdapter{out: "X", block: block}, 1)
+	m.Submit(Request{ID: "first", Input: "x", Output: "X"})
+	m.Submit(Request{ID: "second", Input: "x", Output: "X"}) // only slot taken
+	close(block)
+	m.Close()
+
+	recs := readRecords(t, path)
+	if len(recs) != 1 || recs[0].ID != "first" {
+		t.Errorf("records: got %+v, want only first", recs)
+	}
+}
+
+func TestMirrorPassesRequestPrompt(t *testing.T) {
+	f := &fakeAdapter{out: "X"}
+	m, _ := newTestMirror(t, f, 1)
+	examples := []adapter.Example{{Input: "i go", Output: "I went"}}
+	ref := adapter.Reference{Audience: "the team"}
+	m.Submit(Request{Input: "x", Output: "X", SystemPrompt: "Keep these terms: Kubernetes.", Examples: examples, Reference: ref})
+	m.Close()
+
+	if f.system != "Keep these terms: Kubernetes." {
+		t.Errorf("system prompt: got %q", f.system)
+	}
+	if len(f.examples) != 1 || f.examples[0] != examples[0] {
+		t.Errorf("examples: got %+v", f.examples)
+	}
+	if f.ref != ref {
+		t.Errorf("reference: got %+v", f.ref)
+	}
+}
+
+func TestMirrorRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shadow.jsonl")
+	now := time.Now().UTC()
+	var lines []string
+	for i, age := range []time.Duration{72 * time.Hour, 3 * time.Hour, 2 * time.Hour, time.Hour} {
+		line, _ := json.Marshal(Record{ID: string(rune('a' + i)), Time: now.Add(-age)})
+		lines = append(lines, string(line))
+	}
+	lines = append(lines, `{"id":"torn`)
+	os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+
+	// Opening drops the record past MaxAge and the torn line.
+	m, err := New(Options{Adapter: &fakeAdapter{out: "X"}, Percent: 100, Path: path, MaxEntries: 10, MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if recs := readRecords(t, path); len(recs) != 3 || recs[0].ID != "b" {
+		t.Fatalf("after open: got %+v, want b, c, d", recs)
+	}
+
+	// Past MaxEntries the oldest records go (with ten live records, a
+	// single dead line is already a tenth of them).
+	for i := range 10 {
+		m.Submit(Request{ID: string(rune('e' + i)), Input: "x", Output: "X"})
+		m.wg.Wait()
+	}
+	m.Close()
+	recs := readRecords(t, path)
+	if len(recs) != 10 || recs[0].ID != "e" || recs[9].ID != "n" {
+		t.Errorf("after submits: got %d records from %q to %q, want 10 from e to n", len(recs), recs[0].ID, recs[len(recs)-1].ID)
+	}
+}
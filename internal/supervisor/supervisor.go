@@ -0,0 +1,306 @@
+// Package supervisor runs llama-server as a child process and restarts it
+// when it crashes or stops answering.
+package supervisor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/mlorentedev/pollex/internal/adapter"
+	"github.com/mlorentedev/pollex/internal/metrics"
+)
+
+// Process states reported in Status.
+const (
+	StateStarting   = "starting"   // launched, waiting for the first good check
+	StateRunning    = "running"    // passing checks
+	StateUnhealthy  = "unhealthy"  // failing checks, not yet restarted
+	StateRestarting = "restarting" // exited or killed, waiting out backoff
+	StateStopped    = "stopped"
+)
+
+// Options configures a Supervisor. Zero durations and counts take the
+// defaults noted on each field.
+type Options struct {
+	Bin  string
+	Args []string
+	URL  string // llama-server base URL used for checks
+
+	CheckInterval time.Duration // 10s
+	HangTimeout   time.Duration // 30s; bound on /health and the 1-token generation
+	MaxFailures   int           // 3 consecutive failed checks trigger a restart
+	StartupGrace  time.Duration // 2m; failed checks are ignored while the model loads
+	MinBackoff    time.Duration // 1s
+	MaxBackoff    time.Duration // 1m
+	StopTimeout   time.Duration // 5s between SIGTERM and SIGKILL
+}
+
+// Status is a snapshot of the supervised process.
+type Status struct {
+	State       string    `json:"state"`
+	PID         int       `json:"pid,omitempty"`
+	Restarts    int       `json:"restarts"`
+	Since       time.Time `json:"since"`
+	LastCheckMs int64     `json:"last_check_ms,omitempty"` // latency of the last 1-token generation
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// Supervisor is safe for concurrent use; Run must be called once.
+type Supervisor struct {
+	opts     Options
+	client   *http.Client
+	inFlight atomic.Int64 // polishes running through adapters from Wrap
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New applies defaults to opts and returns a stopped Supervisor.
+func New(opts Options) *Supervisor {
+	def := func(d *time.Duration, v time.Duration) {
+		if *d <= 0 {
+			*d = v
+		}
+	}
+	def(&opts.CheckInterval, 10*time.Second)
+	def(&opts.HangTimeout, 30*time.Second)
+	def(&opts.StartupGrace, 2*time.Minute)
+	def(&opts.MinBackoff, time.Second)
+	def(&opts.MaxBackoff, time.Minute)
+	def(&opts.StopTimeout, 5*time.Second)
+	if opts.MaxFailures <= 0 {
+		opts.MaxFailures = 3
+	}
+	opts.URL = strings.TrimRight(opts.URL, "/")
+
+	return &Supervisor{
+		opts:   opts,
+		client: &http.Client{},
+		status: Status{State: StateStopped, Since: time.Now()},
+	}
+}
+
+// Status returns the current process state.
+func (s *Supervisor) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+func (s *Supervisor) update(f func(st *Status)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.status.State
+	f(&s.status)
+	if s.status.State != prev {
+		s.status.Since = time.Now()
+		slog.Info("llama-server state", "state", s.status.State, "pid", s.status.PID, "error", s.status.LastError)
+	}
+}
+
+// Wrap returns a that counts its in-flight polishes. While any are running
+// the check skips the generation probe: on a single-slot server the probe
+// would queue behind them and time out although the server is healthy.
+func (s *Supervisor) Wrap(a adapter.LLMAdapter) adapter.LLMAdapter {
+	return &busyAdapter{LLMAdapter: a, inFlight: &s.inFlight}
+}
+
+type busyAdapter struct {
+	adapter.LLMAdapter
+	inFlight *atomic.Int64
+}
+
+func (b *busyAdapter) Polish(ctx context.Context, text, systemPrompt string) (string, error) {
+	b.inFlight.Add(1)
+	defer b.inFlight.Add(-1)
+	return b.LLMAdapter.Polish(ctx, text, systemPrompt)
+}
+
+// Unwrap returns the wrapped adapter.
+func (b *busyAdapter) Unwrap() adapter.LLMAdapter { return b.LLMAdapter }
+
+// Run starts the process and keeps it alive until ctx is cancelled, then
+// stops it and returns.
+func (s *Supervisor) Run(ctx context.Context) {
+	backoff := s.opts.MinBackoff
+	for {
+		healthy := s.runOnce(ctx)
+		if ctx.Err() != nil {
+			s.update(func(st *Status) { st.State, st.PID = StateStopped, 0 })
+			return
+		}
+
+		if healthy {
+			backoff = s.opts.MinBackoff
+		}
+		metrics.SupervisorRestarts.Inc()
+		s.update(func(st *Status) {
+			st.State, st.PID = StateRestarting, 0
+			st.Restarts++
+		})
+		slog.Warn("llama-server restarting", "backoff", backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			s.update(func(st *Status) { st.State = StateStopped })
+			return
+		}
+		backoff = min(2*backoff, s.opts.MaxBackoff)
+	}
+}
+
+// runOnce launches the process and watches it until it exits, is killed for
+// failing checks, or ctx is cancelled. It reports whether the process ever
+// passed a check.
+func (s *Supervisor) runOnce(ctx context.Context) (healthy bool) {
+	procCtx, kill := context.WithCancel(ctx)
+	defer kill()
+
+	cmd := exec.CommandContext(procCtx, s.opts.Bin, s.opts.Args...)
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	// WaitDelay also bounds how long Wait copies output after the process
+	// exits, in case a grandchild holds the pipes open.
+	cmd.WaitDelay = s.opts.StopTimeout
+	stdout, outW := io.Pipe()
+	stderr, errW := io.Pipe()
+	cmd.Stdout, cmd.Stderr = outW, errW
+	go logLines(stdout, "stdout")
+	go logLines(stderr, "stderr")
+
+	if err := cmd.Start(); err != nil {
+		outW.Close()
+		errW.Close()
+		s.update(func(st *Status) { st.LastError = fmt.Sprintf("start: %v", err) })
+		return false
+	}
+	started := time.Now()
+	s.update(func(st *Status) {
+		st.State, st.PID, st.LastError = StateStarting, cmd.Process.Pid, ""
+	})
+
+	exited := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		outW.Close()
+		errW.Close()
+		exited <- err
+	}()
+
+	ticker := time.NewTicker(s.opts.CheckInterval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case err := <-exited:
+			if ctx.Err() == nil {
+				s.update(func(st *Status) { st.LastError = fmt.Sprintf("exited: %v", err) })
+			}
+			return healthy
+
+		case <-ticker.C:
+			latency, err := s.check(procCtx)
+			if err == nil {
+				healthy, failures = true, 0
+				s.update(func(st *Status) {
+					st.State, st.LastError = StateRunning, ""
+					if latency > 0 {
+						st.LastCheckMs = latency.Milliseconds()
+					}
+				})
+				continue
+			}
+			if !healthy && time.Since(started) < s.opts.StartupGrace {
+				continue // still loading the model
+			}
+			failures++
+			s.update(func(st *Status) { st.State, st.LastError = StateUnhealthy, err.Error() })
+			if failures >= s.opts.MaxFailures {
+				slog.Error("llama-server hung, killing", "pid", cmd.Process.Pid, "failures", failures, "error", err)
+				kill()
+				<-exited
+				return healthy
+			}
+		}
+	}
+}
+
+// check requires /health to answer 200 and a 1-token generation to finish
+// within HangTimeout; a wedged server typically passes the first only. The
+// generation is skipped, and its latency reported as 0, while polishes are
+// in flight.
+func (s *Supervisor) check(ctx context.Context) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.HangTimeout)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, s.opts.URL+"/health", nil)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("health: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("health: status %d", resp.StatusCode)
+	}
+
+	if s.inFlight.Load() > 0 {
+		return 0, nil
+	}
+	body := []byte(`{"messages":[{"role":"user","content":"ok"}],"max_tokens":1,"temperature":0}`)
+	req, _ = http.NewRequestWithContext(ctx, http.MethodPost, s.opts.URL+"/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	start := time.Now()
+	resp, err = s.client.Do(req)
+	if err != nil {
+		if s.inFlight.Load() > 0 {
+			return 0, nil // queued behind a polish that arrived meanwhile
+		}
+		return 0, fmt.Errorf("generate: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("generate: status %d", resp.StatusCode)
+	}
+	return time.Since(start), nil
+}
+
+// maxLogLine is the longest llama-server output line logged in full.
+const maxLogLine = 64 * 1024
+
+// logLines logs each line of r, truncating long ones, and reads r to the
+// end: a reader that stopped early would let the pipe fill up and block
+// llama-server, which would then look hung.
+func logLines(r io.Reader, stream string) {
+	br := bufio.NewReaderSize(r, maxLogLine)
+	for {
+		line, more, err := br.ReadLine()
+		if err != nil {
+			if err != io.EOF {
+				slog.Warn("llama-server output", "stream", stream, "error", err)
+				io.Copy(io.Discard, br)
+			}
+			return
+		}
+		text, truncated := string(line), more
+		for more && err == nil {
+			_, more, err = br.ReadLine()
+		}
+		if truncated {
+			slog.Info("llama-server", "stream", stream, "line", text, "truncated", true)
+		} else {
+			slog.Info("llama-server", "stream", stream, "line", text)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mlorentedev/pollex/internal/adapter"
+	"github.com/mlorentedev/pollex/internal/warmup"
+)
+
+// TestMain lets the test binary act as a fake llama-server when
+// FAKE_LLAMA_ADDR is set. FAKE_LLAMA_MODES lists one mode per launch
+// ("ok", "hang", "crash"), tracked in the FAKE_LLAMA_COUNT file.
+func TestMain(m *testing.M) {
+	if addr := os.Getenv("FAKE_LLAMA_ADDR"); addr != "" {
+		fakeLlamaServer(addr)
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func fakeLlamaServer(addr string) {
+	countFile := os.Getenv("FAKE_LLAMA_COUNT")
+	data, _ := os.ReadFile(countFile)
+	n, _ := strconv.Atoi(string(data))
+	os.WriteFile(countFile, []byte(strconv.Itoa(n+1)), 0644)
+
+	modes := filepath.SplitList(os.Getenv("FAKE_LLAMA_MODES"))
+	mode := modes[min(n, len(modes)-1)]
+	fmt.Fprintf(os.Stderr, "fake llama-server launch %d mode %s\n", n, mode)
+
+	if mode == "crash" {
+		os.Exit(3)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ok"}`))
+	})
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		if mode == "hang" {
+			select {} // listening but never answering
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	})
+	http.ListenAndServe(addr, mux)
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func startFake(t *testing.T, modes string) (*Supervisor, context.CancelFunc, chan struct{}) {
+	t.Helper()
+	addr := freeAddr(t)
+	t.Setenv("FAKE_LLAMA_ADDR", addr)
+	t.Setenv("FAKE_LLAMA_MODES", modes)
+	t.Setenv("FAKE_LLAMA_COUNT", filepath.Join(t.TempDir(), "count"))
+
+	s := New(Options{
+		Bin:           os.Args[0],
+		URL:           "http://" + addr,
+		CheckInterval: 20 * time.Millisecond,
+		HangTimeout:   100 * time.Millisecond,
+		MaxFailures:   2,
+		StartupGrace:  500 * time.Millisecond,
+		MinBackoff:    10 * time.Millisecond,
+		StopTimeout:   time.Second,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	return s, cancel, done
+}
+
+func waitFor(t *testing.T, s *Supervisor, desc string, cond func(Status) bool) Status {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if st := s.Status(); cond(st) {
+			return st
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s; status %+v", desc, s.Status())
+	return Status{}
+}
+
+func TestSupervisorRunsAndStops(t *testing.T) {
+	s, cancel, done := startFake(t, "ok")
+
+	st := waitFor(t, s, "running", func(st Status) bool { return st.State == StateRunning })
+	if st.PID == 0 || st.Restarts != 0 {
+		t.Errorf("status: got %+v, want pid set and no restarts", st)
+	}
+
+	cancel()
+	<-done
+	if st := s.Status(); st.State != StateStopped {
+		t.Errorf("after cancel: got %q, want %q", st.State, StateStopped)
+	}
+}
+
+func TestSupervisorRestartsHungServer(t *testing.T) {
+	s, cancel, done := startFake(t, "hang"+string(os.PathListSeparator)+"ok")
+	defer func() { cancel(); <-done }()
+
+	st := waitFor(t, s, "recovery", func(st Status) bool { return st.State == StateRunning && st.Restarts == 1 })
+	if st.LastCheckMs < 0 {
+		t.Errorf("last_check_ms: got %d", st.LastCheckMs)
+	}
+}
+
+// heldAdapter blocks in Polish until release is closed.
+type heldAdapter struct{ release chan struct{} }
+
+func (h *heldAdapter) Name() string    { return "held" }
+func (h *heldAdapter) Available() bool { return true }
+func (h *heldAdapter) Polish(ctx context.Context, text, systemPrompt string) (string, error) {
+	<-h.release
+	return text, nil
+}
+
+func TestSupervisorSkipsProbeWhileBusy(t *testing.T) {
+	s, cancel, done := startFake(t, "hang")
+	defer func() { cancel(); <-done }()
+
+	held := &heldAdapter{release: make(chan struct{})}
+	polished := make(chan struct{})
+	go func() {
+		s.Wrap(held).Polish(context.Background(), "text", "")
+		close(polished)
+	}()
+
+	waitFor(t, s, "running", func(st Status) bool { return st.State == StateRunning })
+	time.Sleep(500 * time.Millisecond) // several hang timeouts
+	if st := s.Status(); st.State != StateRunning || st.Restarts != 0 {
+		t.Errorf("while busy: got %+v, want running without restarts", st)
+	}
+
+	close(held.release)
+	<-polished
+	waitFor(t, s, "restart once idle", func(st Status) bool { return st.Restarts >= 1 })
+}
+
+func TestSupervisorRestartsCrashedServer(t *testing.T) {
+	modes := "crash" + string(os.PathListSeparator) + "crash" + string(os.PathListSeparator) + "ok"
+	s, cancel, done := startFake(t, modes)
+	defer func() { cancel(); <-done }()
+
+	waitFor(t, s, "recovery", func(st Status) bool { return st.State == StateRunning && st.Restarts == 2 })
+}
+
+func TestLogLinesReadsPastLongLines(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(prev)
+
+	r, w := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		logLines(r, "stderr")
+		close(done)
+	}()
+
+	// Past the old 1 MB scanner limit; writes must not block.
+	fmt.Fprintf(w, "%s\nnext line\n", strings.Repeat("x", 2<<20))
+	w.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logLines did not read to the end")
+	}
+	out := buf.String()
+	if !strings.Contains(out, "truncated=true") || !strings.Contains(out, `line="next line"`) {
+		t.Errorf("log: got %.200q…", out)
+	}
+}
+
+// probeAdapter records how many polishes the supervisor saw in flight.
+type probeAdapter struct {
+	s    *Supervisor
+	seen atomic.Int64
+}
+
+func (p *probeAdapter) Name() string    { return "probe" }
+func (p *probeAdapter) Available() bool { return true }
+func (p *probeAdapter) Polish(ctx context.Context, text, systemPrompt string) (string, error) {
+	p.seen.Store(p.s.inFlight.Load())
+	return text, nil
+}
+
+func TestSupervisorTracksPooledWarmup(t *testing.T) {
+	s := New(Options{Bin: "llama-server", URL: "http://127.0.0.1:1"})
+	member := &probeAdapter{s: s}
+	pool := adapter.NewPool("m", "", 0, 0, []*adapter.Endpoint{{URL: "http://127.0.0.1:1", Adapter: member}})
+
+	// As wired in main: the supervisor wraps the pool, the warmer wraps both
+	// and warms each member on its own.
+	w := warmup.New(warmup.Options{Text: "warm", WrapMember: s.Wrap})
+	w.Wrap("m", s.Wrap(pool))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !w.Ready() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !w.Ready() {
+		t.Fatal("pool never warmed")
+	}
+	if got := member.seen.Load(); got != 1 {
+		t.Errorf("in flight during member warmup: got %d, want 1", got)
+	}
+}
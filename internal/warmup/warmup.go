@@ -0,0 +1,233 @@
+// Package warmup primes local models so the first real request after
+// startup, a backend restart or a long idle period isn't the slow one.
+package warmup
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mlorentedev/pollex/internal/adapter"
+	"github.com/mlorentedev/pollex/internal/metrics"
+)
+
+// Reasons recorded in the warmup duration metric.
+const (
+	ReasonStartup   = "startup"
+	ReasonRecovered = "recovered"
+	ReasonKeepWarm  = "keep_warm"
+)
+
+// Options configures a Warmer.
+type Options struct {
+	Text          string        // user text of the warmup generation
+	SystemPrompt  string        // real prompt, so llama-server caches its prefix
+	CheckInterval time.Duration // availability polling period
+	KeepWarm      time.Duration // ping after this much idle time; 0 disables
+	Timeout       time.Duration // per warmup generation
+
+	// WrapMember, if set, wraps each pool member before it is warmed on its
+	// own, so wrappers around the pool as a whole (such as the supervisor's
+	// in-flight tracking) still see the call.
+	WrapMember func(adapter.LLMAdapter) adapter.LLMAdapter
+}
+
+type target struct {
+	inner    adapter.LLMAdapter
+	up       bool
+	warm     bool
+	warmedAt time.Time
+	lastUsed time.Time
+}
+
+// Warmer tracks warmth of a set of models. It is safe for concurrent use.
+type Warmer struct {
+	opts Options
+	now  func() time.Time
+
+	mu      sync.Mutex
+	targets map[string]*target
+	started bool // first pass done
+}
+
+// New returns a Warmer with no models. Register models with Wrap, then Run.
+func New(opts Options) *Warmer {
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = 30 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Minute
+	}
+	return &Warmer{opts: opts, now: time.Now, targets: make(map[string]*target)}
+}
+
+// Wrap registers a as model id and returns an adapter that records each
+// polish as activity for keep-warm.
+func (w *Warmer) Wrap(id string, a adapter.LLMAdapter) adapter.LLMAdapter {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.targets[id] = &target{inner: a}
+	return &trackedAdapter{LLMAdapter: a, touch: func() { w.touch(id) }}
+}
+
+func (w *Warmer) touch(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if t, ok := w.targets[id]; ok {
+		t.lastUsed = w.now()
+	}
+}
+
+// Ready reports whether the first pass has finished and every tracked model
+// is warm. A model that is down is not warm.
+func (w *Warmer) Ready() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.started {
+		return false
+	}
+	for _, t := range w.targets {
+		if !t.warm {
+			return false
+		}
+	}
+	return true
+}
+
+// Warm reports whether id is tracked and, if so, whether it is warm.
+func (w *Warmer) Warm(id string) (warm, tracked bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	t, ok := w.targets[id]
+	if !ok {
+		return false, false
+	}
+	return t.warm, true
+}
+
+// Run checks every tracked model immediately and then every CheckInterval
+// until ctx is cancelled.
+func (w *Warmer) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.CheckInterval)
+	defer ticker.Stop()
+	for {
+		w.checkAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Warmer) checkAll(ctx context.Context) {
+	w.mu.Lock()
+	ids := make([]string, 0, len(w.targets))
+	for id := range w.targets {
+		ids = append(ids, id)
+	}
+	w.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Go(func() { w.check(ctx, id) })
+	}
+	wg.Wait()
+
+	w.mu.Lock()
+	w.started = true
+	w.mu.Unlock()
+}
+
+func (w *Warmer) check(ctx context.Context, id string) {
+	w.mu.Lock()
+	t := w.targets[id]
+	w.mu.Unlock()
+
+	up := t.inner.Available()
+
+	w.mu.Lock()
+	wasUp, warm, first := t.up, t.warm, t.warmedAt.IsZero()
+	t.up = up
+	if !up {
+		t.warm = false
+	}
+	lastActive := t.warmedAt
+	if t.lastUsed.After(lastActive) {
+		lastActive = t.lastUsed
+	}
+	idle := w.now().Sub(lastActive)
+	w.mu.Unlock()
+
+	var reason string
+	switch {
+	case !up:
+		if wasUp {
+			slog.Warn("model went cold", "model", id, "reason", "unavailable")
+		}
+		return
+	case !warm && first:
+		reason = ReasonStartup
+	case !warm:
+		reason = ReasonRecovered
+	case w.opts.KeepWarm > 0 && idle >= w.opts.KeepWarm:
+		reason = ReasonKeepWarm
+	default:
+		return
+	}
+
+	ok := w.warm(ctx, id, t.inner, reason)
+
+	w.mu.Lock()
+	t.warm = ok
+	if ok {
+		t.warmedAt = w.now()
+	}
+	w.mu.Unlock()
+}
+
+// warm runs one generation per backend (each pool member individually).
+func (w *Warmer) warm(ctx context.Context, id string, a adapter.LLMAdapter, reason string) bool {
+	backends := []adapter.LLMAdapter{a}
+	if p, ok := adapter.Base(a).(*adapter.PoolAdapter); ok {
+		backends = p.Members()
+		if w.opts.WrapMember != nil {
+			for i, m := range backends {
+				backends[i] = w.opts.WrapMember(m)
+			}
+		}
+	}
+
+	ok := true
+	for _, b := range backends {
+		ctx, cancel := context.WithTimeout(ctx, w.opts.Timeout)
+		start := time.Now()
+		_, err := b.Polish(ctx, w.opts.Text, w.opts.SystemPrompt)
+		elapsed := time.Since(start)
+		cancel()
+
+		if err != nil {
+			slog.Warn("warmup failed", "model", id, "backend", b.Name(), "reason", reason, "error", err)
+			ok = false
+			continue
+		}
+		metrics.WarmupDuration.WithLabelValues(id, reason).Observe(elapsed.Seconds())
+		slog.Info("model warmed", "model", id, "backend", b.Name(), "reason", reason, "elapsed_ms", elapsed.Milliseconds())
+	}
+	return ok
+}
+
+// trackedAdapter marks its model as recently used on every polish.
+type trackedAdapter struct {
+	adapter.LLMAdapter
+	touch func()
+}
+
+func (t *trackedAdapter) Polish(ctx context.Context, text, systemPrompt string) (string, error) {
+	t.touch()
+	return t.LLMAdapter.Polish(ctx, text, systemPrompt)
+}
+
+// Unwrap returns the wrapped adapter.
+func (t *trackedAdapter) Unwrap() adapter.LLMAdapter { return t.LLMAdapter }
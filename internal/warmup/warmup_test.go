@@ -0,0 +1,144 @@
+package warmup
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeAdapter struct {
+	mu    sync.Mutex
+	up    bool
+	err   error
+	texts []string
+}
+
+func (f *fakeAdapter) Name() string { return "fake" }
+func (f *fakeAdapter) Polish(ctx context.Context, text, systemPrompt string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.texts = append(f.texts, text)
+	return text, f.err
+}
+func (f *fakeAdapter) Available() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.up
+}
+
+func (f *fakeAdapter) set(up bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.up, f.err = up, err
+}
+
+func (f *fakeAdapter) warmups() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, t := range f.texts {
+		if t == "warm" {
+			n++
+		}
+	}
+	return n
+}
+
+func newTestWarmer(keepWarm time.Duration) (*Warmer, *time.Time) {
+	w := New(Options{Text: "warm", KeepWarm: keepWarm})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+	return w, &now
+}
+
+func TestWarmerStartupAndRecovery(t *testing.T) {
+	w, _ := newTestWarmer(0)
+	f := &fakeAdapter{up: true}
+	w.Wrap("local", f)
+	ctx := context.Background()
+
+	if w.Ready() {
+		t.Error("Ready before first pass: got true, want false")
+	}
+	w.checkAll(ctx)
+	w.checkAll(ctx)
+	if got := f.warmups(); got != 1 {
+		t.Errorf("warmups after startup: got %d, want 1", got)
+	}
+	if !w.Ready() {
+		t.Error("Ready after warmup: got false, want true")
+	}
+
+	f.set(false, nil)
+	w.checkAll(ctx)
+	if w.Ready() {
+		t.Error("Ready while backend down: got true, want false")
+	}
+	if warm, tracked := w.Warm("local"); warm || !tracked {
+		t.Errorf("Warm while down: got warm=%v tracked=%v", warm, tracked)
+	}
+
+	f.set(true, nil)
+	w.checkAll(ctx)
+	if got := f.warmups(); got != 2 {
+		t.Errorf("warmups after recovery: got %d, want 2", got)
+	}
+	if !w.Ready() {
+		t.Error("Ready after recovery: got false, want true")
+	}
+}
+
+func TestWarmerFailedWarmupNotReady(t *testing.T) {
+	w, _ := newTestWarmer(0)
+	f := &fakeAdapter{up: true, err: errors.New("out of memory")}
+	w.Wrap("local", f)
+
+	w.checkAll(context.Background())
+	if w.Ready() {
+		t.Error("Ready after failed warmup: got true, want false")
+	}
+
+	f.set(true, nil)
+	w.checkAll(context.Background())
+	if !w.Ready() {
+		t.Error("Ready after retry: got false, want true")
+	}
+}
+
+func TestWarmerKeepWarm(t *testing.T) {
+	w, now := newTestWarmer(10 * time.Minute)
+	f := &fakeAdapter{up: true}
+	a := w.Wrap("local", f)
+	ctx := context.Background()
+
+	w.checkAll(ctx) // startup
+	*now = now.Add(9 * time.Minute)
+	w.checkAll(ctx)
+	if got := f.warmups(); got != 1 {
+		t.Fatalf("warmups before idle interval: got %d, want 1", got)
+	}
+
+	// Real traffic resets the idle clock.
+	a.Polish(ctx, "real text", "")
+	*now = now.Add(5 * time.Minute)
+	w.checkAll(ctx)
+	if got := f.warmups(); got != 1 {
+		t.Errorf("warmups after recent traffic: got %d, want 1", got)
+	}
+
+	*now = now.Add(10 * time.Minute)
+	w.checkAll(ctx)
+	if got := f.warmups(); got != 2 {
+		t.Errorf("warmups after idle interval: got %d, want 2", got)
+	}
+}
+
+func TestWarmerNoModelsIsReady(t *testing.T) {
+	w, _ := newTestWarmer(0)
+	w.checkAll(context.Background())
+	if !w.Ready() {
+		t.Error("Ready with nothing to warm: got false, want true")
+	}
+}
@@ -0,0 +1,198 @@
+// Package client is a Go client for the Pollex HTTP API (/api/v1).
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout = 130 * time.Second
+	defaultBackoff = 500 * time.Millisecond
+)
+
+// Client calls a Pollex server. The zero value is not usable; use New.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithAPIKey sends key in the X-API-Key header on every request.
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithHTTPClient replaces the default http.Client (130s timeout).
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithRetries retries retryable failures up to n extra times, waiting
+// backoff, 2*backoff, 4*backoff... between attempts. A POST is retried only
+// if the server marked the error retryable or the request never reached it,
+// so a polish the server may already have done is not run twice.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = n
+		c.backoff = backoff
+	}
+}
+
+// New returns a Client for the server at baseURL (e.g. "http://localhost:8090").
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		backoff:    defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Health returns the server health and per-adapter availability.
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	var h Health
+	if err := c.do(ctx, http.MethodGet, "/api/v1/health", nil, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// Models lists the models the server can route to.
+func (c *Client) Models(ctx context.Context) ([]ModelInfo, error) {
+	var models []ModelInfo
+	if err := c.do(ctx, http.MethodGet, "/api/v1/models", nil, &models); err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+// Polish sends req to the server and returns the polished text.
+func (c *Client) Polish(ctx context.Context, req PolishRequest) (*PolishResponse, error) {
+	var resp PolishResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/polish", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Lint checks text against the server's style rules. No model is called,
+// so it works while every backend is down.
+func (c *Client) Lint(ctx context.Context, text string) (*LintReport, error) {
+	var report LintReport
+	if err := c.do(ctx, http.MethodPost, "/api/v1/lint", map[string]string{"text": text}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("pollex: marshal request: %w", err)
+		}
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = c.once(ctx, method, path, body, out)
+		if err == nil || attempt >= c.retries || !retryable(method, err) {
+			return err
+		}
+		select {
+		case <-time.After(c.backoff << attempt):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+func (c *Client) once(ctx context.Context, method, path string, body []byte, out any) error {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return fmt.Errorf("pollex: create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return &transportError{err: fmt.Errorf("pollex: request: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return decodeError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("pollex: decode response: %w", err)
+	}
+	return nil
+}
+
+func decodeError(resp *http.Response) error {
+	e := &Error{StatusCode: resp.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err := json.Unmarshal(data, e); err != nil || e.Code == "" {
+		e.Message = strings.TrimSpace(string(data))
+		e.Retryable = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	}
+	e.StatusCode = resp.StatusCode
+	return e
+}
+
+// transportError is a failure to get a response at all.
+type transportError struct{ err error }
+
+func (e *transportError) Error() string { return e.err.Error() }
+func (e *transportError) Unwrap() error { return e.err }
+
+// retryable reports whether err is worth retrying: server-marked retryable
+// API errors, and transport failures where repeating is safe (a GET, or a
+// connection that was never made). Never caller cancellation, and never a
+// response that failed to decode: the server did the work.
+func retryable(method string, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable
+	}
+	var te *transportError
+	if !errors.As(err, &te) {
+		return false
+	}
+	if method == http.MethodGet {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,226 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientPolish(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/polish" {
+			t.Errorf("path: got %q, want /api/v1/polish", r.URL.Path)
+		}
+		if got := r.Header.Get("X-API-Key"); got != "secret" {
+			t.Errorf("X-API-Key: got %q, want %q", got, "secret")
+		}
+		var req PolishRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(PolishResponse{Polished: "Hello.", Model: req.ModelID, ElapsedMs: 12})
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL+"/", WithAPIKey("secret"))
+	resp, err := c.Polish(context.Background(), PolishRequest{Text: "hello", ModelID: "mock"})
+	if err != nil {
+		t.Fatalf("Polish: %v", err)
+	}
+	if resp.Polished != "Hello." {
+		t.Errorf("polished: got %q, want %q", resp.Polished, "Hello.")
+	}
+	if resp.Model != "mock" {
+		t.Errorf("model: got %q, want %q", resp.Model, "mock")
+	}
+}
+
+func TestClientModelsAndHealth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/models":
+			json.NewEncoder(w).Encode([]ModelInfo{{ID: "mock", Name: "Mock", Provider: "mock"}})
+		case "/api/v1/health":
+			json.NewEncoder(w).Encode(Health{Status: "ok", Version: "test", Adapters: map[string]AdapterStatus{"mock": {Available: true}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	models, err := c.Models(context.Background())
+	if err != nil {
+		t.Fatalf("Models: %v", err)
+	}
+	if len(models) != 1 || models[0].ID != "mock" {
+		t.Errorf("models: got %+v", models)
+	}
+
+	h, err := c.Health(context.Background())
+	if err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+	if !h.Adapters["mock"].Available {
+		t.Error("mock adapter: got unavailable")
+	}
+}
+
+func TestClientTypedError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":"unknown_model","message":"unknown model: x","request_id":"abc","retryable":false,"error":"unknown model: x"}`))
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL).Polish(context.Background(), PolishRequest{Text: "hi", ModelID: "x"})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error: got %T (%v), want *Error", err, err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("status: got %d, want %d", apiErr.StatusCode, http.StatusBadRequest)
+	}
+	if apiErr.Code != "unknown_model" {
+		t.Errorf("code: got %q, want %q", apiErr.Code, "unknown_model")
+	}
+	if apiErr.RequestID != "abc" {
+		t.Errorf("request_id: got %q, want %q", apiErr.RequestID, "abc")
+	}
+}
+
+func TestClientNonJSONError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL).Models(context.Background())
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error: got %T, want *Error", err)
+	}
+	if apiErr.Message != "bad gateway" {
+		t.Errorf("message: got %q, want %q", apiErr.Message, "bad gateway")
+	}
+	if !apiErr.Retryable {
+		t.Error("retryable: got false, want true for 502")
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"code":"rate_limited","message":"rate limit exceeded","retryable":true}`))
+			return
+		}
+		json.NewEncoder(w).Encode(PolishResponse{Polished: "ok", Model: "mock"})
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithRetries(2, time.Millisecond))
+	if _, err := c.Polish(context.Background(), PolishRequest{Text: "hi", ModelID: "mock"}); err != nil {
+		t.Fatalf("Polish: %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("calls: got %d, want 3", got)
+	}
+}
+
+func TestClientDoesNotRetryNonRetryable(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":"text_too_long","message":"too long","retryable":false}`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithRetries(3, time.Millisecond))
+	if _, err := c.Polish(context.Background(), PolishRequest{Text: "hi", ModelID: "mock"}); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("calls: got %d, want 1", got)
+	}
+}
+
+func TestClientRetriesOnlyWhenSafe(t *testing.T) {
+	var calls atomic.Int32
+	var mode atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch mode.Load() {
+		case "garbage":
+			w.Write([]byte("not json"))
+		case "drop":
+			// The request arrived, then the connection broke.
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+	c := New(srv.URL, WithRetries(2, time.Millisecond))
+
+	tests := []struct {
+		name, mode string
+		call       func() error
+		want       int32
+	}{
+		{"decode error after 200", "garbage", func() error {
+			_, err := c.Polish(context.Background(), PolishRequest{Text: "hi", ModelID: "mock"})
+			return err
+		}, 1},
+		{"dropped polish", "drop", func() error {
+			_, err := c.Polish(context.Background(), PolishRequest{Text: "hi", ModelID: "mock"})
+			return err
+		}, 1},
+		{"dropped GET", "drop", func() error {
+			_, err := c.Models(context.Background())
+			return err
+		}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			mode.Store(tt.mode)
+			if err := tt.call(); err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if got := calls.Load(); got != tt.want {
+				t.Errorf("calls: got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	dial := &transportError{err: fmt.Errorf("pollex: request: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")})}
+	read := &transportError{err: fmt.Errorf("pollex: request: %w", &net.OpError{Op: "read", Err: errors.New("connection reset")})}
+	tests := []struct {
+		name   string
+		method string
+		err    error
+		want   bool
+	}{
+		{"POST never connected", http.MethodPost, dial, true},
+		{"POST connection reset", http.MethodPost, read, false},
+		{"GET connection reset", http.MethodGet, read, true},
+		{"retryable API error", http.MethodPost, &Error{Retryable: true}, true},
+		{"cancelled", http.MethodGet, &transportError{err: context.Canceled}, false},
+		{"decode error", http.MethodGet, errors.New("pollex: decode response: EOF"), false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.method, tt.err); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
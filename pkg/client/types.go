@@ -0,0 +1,217 @@
+package client
+
+import (
+	"fmt"
+	"time"
+)
+
+// PolishRequest is the body of POST /api/v1/polish.
+// Leave ModelID empty (or "auto") to let the server's routing rules choose.
+type PolishRequest struct {
+	Text    string `json:"text"`
+	ModelID string `json:"model_id,omitempty"`
+	Tier    string `json:"tier,omitempty"`
+
+	// Prompt template variables; empty uses the server's defaults. Tone
+	// and audience must be among the server's configured choices.
+	Tone     string `json:"tone,omitempty"`
+	Audience string `json:"audience,omitempty"`
+
+	Lint    bool `json:"lint,omitempty"`    // ask for style lint reports
+	Explain bool `json:"explain,omitempty"` // ask for the edits with reasons
+	N       int  `json:"n,omitempty"`       // ask for n ranked alternatives
+
+	// TargetLanguage ("en", "es") translates text in another language;
+	// empty polishes in the input's language.
+	TargetLanguage string `json:"target_language,omitempty"`
+
+	Context *PolishContext `json:"context,omitempty"`
+
+	// Incremental re-polishes only the sentences that changed since an
+	// earlier polish of the same document.
+	Incremental bool `json:"incremental,omitempty"`
+}
+
+// PolishContext tells the model what the text replies to. It is background
+// only: the server never polishes it or returns it.
+type PolishContext struct {
+	PreviousMessage string `json:"previous_message,omitempty"`
+	Audience        string `json:"audience,omitempty"`
+	Purpose         string `json:"purpose,omitempty"`
+}
+
+// PolishResponse is returned by POST /api/v1/polish.
+type PolishResponse struct {
+	Polished   string                `json:"polished"`
+	Model      string                `json:"model"`
+	ElapsedMs  int64                 `json:"elapsed_ms"`
+	Route      *Route                `json:"route,omitempty"`
+	Experiment *ExperimentAssignment `json:"experiment,omitempty"`
+
+	GlossaryViolations []GlossaryViolation `json:"glossary_violations,omitempty"`
+	Lint               *PolishLint         `json:"lint,omitempty"`
+	Explain            *Explanation        `json:"explain,omitempty"`
+	Alternatives       []Alternative       `json:"alternatives,omitempty"`
+	Languages          *Languages          `json:"languages,omitempty"`
+	Injection          *Injection          `json:"injection,omitempty"`
+	Segments           *Segments           `json:"segments,omitempty"`
+}
+
+// Segments reports how many sentences of an incremental polish came from
+// the server's cache.
+type Segments struct {
+	Total  int `json:"total"`
+	Reused int `json:"reused"`
+}
+
+// Injection reports a prompt injection check that fired. Action is "flag"
+// or "sanitize"; rejected requests fail with code prompt_injection instead.
+type Injection struct {
+	Action  string   `json:"action"`
+	Matches []string `json:"matches,omitempty"`
+	Leak    bool     `json:"leak"`
+}
+
+// Languages reports detected and requested language codes. A code is empty
+// when detection could not tell.
+type Languages struct {
+	Input      string `json:"input,omitempty"`
+	Target     string `json:"target,omitempty"`
+	Output     string `json:"output,omitempty"`
+	Translated bool   `json:"translated"`
+	Mismatch   bool   `json:"mismatch"`
+}
+
+// Alternative is one candidate rewrite, with scores from 0 (worst) to 1.
+type Alternative struct {
+	Text   string            `json:"text"`
+	Score  float64           `json:"score"`
+	Scores AlternativeScores `json:"scores"`
+}
+
+// AlternativeScores are the components of Alternative.Score.
+type AlternativeScores struct {
+	Similarity  float64 `json:"similarity"`
+	LengthRatio float64 `json:"length_ratio"`
+	Lint        float64 `json:"lint"`
+}
+
+// Explanation lists the edits a polish made, ordered by Start. Source is
+// "model", or "diff" when the edits were computed and have no reasons.
+type Explanation struct {
+	Source string `json:"source"`
+	Edits  []Edit `json:"edits"`
+}
+
+// Edit is one change. Start and End are offsets into the input in Unicode
+// code points, End exclusive. Category is grammar, flow or concision.
+type Edit struct {
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	Original    string `json:"original"`
+	Replacement string `json:"replacement"`
+	Category    string `json:"category,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// PolishLint holds lint reports for a polish's input and output.
+type PolishLint struct {
+	Input  LintReport `json:"input"`
+	Output LintReport `json:"output"`
+}
+
+// LintReport is returned by POST /api/v1/lint.
+type LintReport struct {
+	Findings []LintFinding `json:"findings"`
+	Stats    LintStats     `json:"stats"`
+}
+
+// LintFinding is one style issue. Start and End are offsets in Unicode code
+// points, End exclusive.
+type LintFinding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+// LintStats are readability statistics for the whole text.
+type LintStats struct {
+	Words              int     `json:"words"`
+	Sentences          int     `json:"sentences"`
+	FleschReadingEase  float64 `json:"flesch_reading_ease"`
+	FleschKincaidGrade float64 `json:"flesch_kincaid_grade"`
+}
+
+// GlossaryViolation is a glossary term the polished text has fewer of than
+// the input.
+type GlossaryViolation struct {
+	Term     string `json:"term"`
+	Expected int    `json:"expected"`
+	Found    int    `json:"found"`
+}
+
+// Route explains a server-side model choice.
+type Route struct {
+	Rule string `json:"rule"`
+}
+
+// ExperimentAssignment names the experiment variant that served a request.
+type ExperimentAssignment struct {
+	Name    string `json:"name"`
+	Variant string `json:"variant"`
+}
+
+// ModelInfo describes one entry of GET /api/v1/models.
+type ModelInfo struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+}
+
+// AdapterStatus is the availability of one backend in Health.
+type AdapterStatus struct {
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"`
+	Warm      *bool  `json:"warm,omitempty"` // set only when the server warms this model
+}
+
+// Health is returned by GET /api/v1/health.
+type Health struct {
+	Status   string                   `json:"status"`
+	Version  string                   `json:"version"`
+	Ready    bool                     `json:"ready"`
+	Adapters map[string]AdapterStatus `json:"adapters"`
+	Process  *ProcessStatus           `json:"process,omitempty"`
+}
+
+// ProcessStatus is the supervised llama-server's state, present in Health
+// only when the server runs llama-server itself.
+type ProcessStatus struct {
+	State       string    `json:"state"`
+	PID         int       `json:"pid,omitempty"`
+	Restarts    int       `json:"restarts"`
+	Since       time.Time `json:"since"`
+	LastCheckMs int64     `json:"last_check_ms,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// Error is returned for any non-200 response. Code holds the server's
+// machine-readable error code (e.g. "text_too_long", "rate_limited");
+// see the Error schema in /api/openapi.json for the full list.
+type Error struct {
+	StatusCode int            `json:"-"`
+	Code       string         `json:"code"`
+	Message    string         `json:"message"`
+	RequestID  string         `json:"request_id,omitempty"`
+	Retryable  bool           `json:"retryable"`
+	Details    map[string]any `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("pollex: HTTP %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("pollex: HTTP %d %s: %s", e.StatusCode, e.Code, e.Message)
+}